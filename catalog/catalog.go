@@ -0,0 +1,52 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+
+	"encore.app/prescriptions"
+	"encore.app/procurement"
+	"encore.app/product"
+	"encore.dev/types/uuid"
+)
+
+// DeleteProduct deletes an inactive product that no service refers to.
+// Purchases, supplier price lists and prescriptions name products by ID, so a
+// product they mention must stay deactivated instead. They refuse inactive
+// products, so no new reference appears once the product is deactivated. The
+// product service checks its own batches.
+//
+//encore:api public method=DELETE path=/api/products/:id
+func DeleteProduct(ctx context.Context, id uuid.UUID) (Response, error) {
+	productData, err := product.GetProduct(ctx, id)
+	if err != nil {
+		return Response{Message: "Product not found"}, errors.New("product not found")
+	}
+	if productData.IsActive {
+		return Response{Message: "Product is active, deactivate it first"}, errors.New("only inactive products can be deleted")
+	}
+
+	// Check if procurement references the product
+	procurementRefs, err := procurement.CountProductReferences(ctx, id)
+	if err != nil {
+		return Response{Message: "Failed to check purchase items"}, err
+	}
+	if procurementRefs.PurchaseItems > 0 {
+		return Response{Message: "Product has purchase items, deactivate it instead"}, errors.New("product is referenced by purchase items")
+	}
+	if procurementRefs.SupplierPrices > 0 {
+		return Response{Message: "Product is on supplier price lists, deactivate it instead"}, errors.New("product is referenced by supplier prices")
+	}
+
+	// Check if any prescription references the product
+	prescriptionRefs, err := prescriptions.CountProductReferences(ctx, id)
+	if err != nil {
+		return Response{Message: "Failed to check prescription items"}, err
+	}
+	if prescriptionRefs.PrescriptionItems > 0 {
+		return Response{Message: "Product has prescription items, deactivate it instead"}, errors.New("product is referenced by prescription items")
+	}
+
+	deleted, err := product.DeleteProduct(ctx, id)
+	return Response{Message: deleted.Message}, err
+}
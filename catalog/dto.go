@@ -0,0 +1,5 @@
+package catalog
+
+type Response struct {
+	Message string `json:"message"`
+}
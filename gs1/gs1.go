@@ -0,0 +1,216 @@
+// Package gs1 parses the GS1 DataMatrix and GS1-128 codes printed on
+// medicine packs.
+//
+// A code is a string of application identifiers (AI) and values: the GTIN,
+// the expiry date, the batch number and the serial number. Codes arrive in raw
+// form, with FNC1 group separators terminating variable length values, or in
+// human readable form with the identifiers in brackets.
+package gs1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GS1 application identifiers read from medicine pack codes
+const (
+	aiGTIN           = "01"
+	aiProductionDate = "11"
+	aiBestBefore     = "15"
+	aiExpiry         = "17"
+	aiBatch          = "10"
+	aiSerial         = "21"
+)
+
+// groupSeparator is the FNC1 character terminating variable length fields
+const groupSeparator = '\x1d'
+
+// Data holds the fields of a GS1 DataMatrix or GS1-128 payload
+type Data struct {
+	GTIN        string     `json:"gtin"`
+	Expiry      *time.Time `json:"expiry,omitempty"`
+	BatchNumber string     `json:"batch_number,omitempty"`
+	Serial      string     `json:"serial,omitempty"`
+}
+
+// IsElementString reports whether a scanned code is a GS1 element string rather than a
+// plain EAN/UPC barcode: it carries a symbology identifier, a group separator,
+// bracketed application identifiers, or starts with a GTIN followed by more data
+func IsElementString(code string) bool {
+	switch {
+	case strings.HasPrefix(code, "]d2"), strings.HasPrefix(code, "]C1"), strings.HasPrefix(code, "]Q3"):
+		return true
+	case strings.HasPrefix(code, "(01)"):
+		return true
+	case strings.ContainsRune(code, groupSeparator):
+		return true
+	}
+	return strings.HasPrefix(code, aiGTIN) && len(code) >= 16
+}
+
+// Parse parses a GS1 element string in raw form (FNC1 separated, with or
+// without a symbology identifier) or in human readable form with the
+// application identifiers in brackets. Only the identifiers printed on
+// medicine packs are supported.
+func Parse(code string) (*Data, error) {
+	for _, prefix := range []string{"]d2", "]C1", "]Q3"} {
+		code = strings.TrimPrefix(code, prefix)
+	}
+	if strings.HasPrefix(code, "(") {
+		code = bracketedToRaw(code)
+	}
+	code = strings.TrimLeft(code, string(groupSeparator))
+
+	var data Data
+	for code != "" {
+		if len(code) < 2 {
+			return nil, errors.New("gs1 code is truncated")
+		}
+		ai := code[:2]
+		code = code[2:]
+
+		var value string
+		switch ai {
+		case aiGTIN:
+			value, code = fixedField(code, 14)
+		case aiExpiry, aiProductionDate, aiBestBefore:
+			value, code = fixedField(code, 6)
+		case aiBatch, aiSerial:
+			value, code = variableField(code, 20)
+		default:
+			return nil, fmt.Errorf("unsupported gs1 application identifier (%s)", ai)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("gs1 application identifier (%s) has no value", ai)
+		}
+
+		switch ai {
+		case aiGTIN:
+			if !ValidGTIN(value) {
+				return nil, errors.New("gtin is invalid")
+			}
+			data.GTIN = value
+		case aiExpiry:
+			expiry, err := parseDate(value)
+			if err != nil {
+				return nil, err
+			}
+			data.Expiry = &expiry
+		case aiBatch:
+			data.BatchNumber = value
+		case aiSerial:
+			data.Serial = value
+		}
+	}
+
+	if data.GTIN == "" {
+		return nil, errors.New("gs1 code has no gtin")
+	}
+	return &data, nil
+}
+
+// bracketedToRaw converts "(01)...(10)..." into the raw form, separating every
+// field with FNC1 so variable length values are terminated
+func bracketedToRaw(code string) string {
+	var raw strings.Builder
+	for _, part := range strings.Split(code, "(")[1:] {
+		raw.WriteString(strings.Replace(part, ")", "", 1))
+		raw.WriteRune(groupSeparator)
+	}
+	return raw.String()
+}
+
+// fixedField splits off a fixed length value
+func fixedField(code string, length int) (string, string) {
+	if len(code) < length {
+		return "", ""
+	}
+	return code[:length], strings.TrimLeft(code[length:], string(groupSeparator))
+}
+
+// variableField splits off a value terminated by FNC1 or the end of the code
+func variableField(code string, maxLength int) (string, string) {
+	end := strings.IndexRune(code, groupSeparator)
+	if end < 0 {
+		end = len(code)
+	}
+	if end > maxLength {
+		end = maxLength
+	}
+	return code[:end], strings.TrimLeft(code[end:], string(groupSeparator))
+}
+
+// parseDate parses a YYMMDD date. A day of 00 means the last day of the
+// month, and years are placed within the century window of the GS1 spec.
+func parseDate(value string) (time.Time, error) {
+	if len(value) != 6 || strings.Trim(value, "0123456789") != "" {
+		return time.Time{}, errors.New("gs1 expiry date is invalid")
+	}
+	yy, mm, dd := twoDigits(value[0:2]), twoDigits(value[2:4]), twoDigits(value[4:6])
+	if mm < 1 || mm > 12 {
+		return time.Time{}, errors.New("gs1 expiry date is invalid")
+	}
+
+	currentYear := time.Now().Year()
+	year := currentYear/100*100 + yy
+	switch diff := year - currentYear; {
+	case diff >= 51:
+		year -= 100
+	case diff <= -50:
+		year += 100
+	}
+
+	if dd == 0 {
+		return time.Date(year, time.Month(mm)+1, 0, 0, 0, 0, 0, time.UTC), nil
+	}
+	date := time.Date(year, time.Month(mm), dd, 0, 0, 0, 0, time.UTC)
+	if date.Day() != dd {
+		return time.Time{}, errors.New("gs1 expiry date is invalid")
+	}
+	return date, nil
+}
+
+// twoDigits returns the value of two decimal digits
+func twoDigits(digits string) int {
+	return int(digits[0]-'0')*10 + int(digits[1]-'0')
+}
+
+// ValidGTIN checks the digits and check digit of a GTIN. GTINs are at least
+// 8 digits long.
+func ValidGTIN(gtin string) bool {
+	if len(gtin) < 8 {
+		return false
+	}
+
+	sum := 0
+	for i := len(gtin) - 1; i >= 0; i-- {
+		digit := int(gtin[i] - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+		if i == len(gtin)-1 {
+			continue
+		}
+		// Weights alternate 3, 1, 3, ... starting next to the check digit
+		if (len(gtin)-1-i)%2 == 1 {
+			sum += digit * 3
+		} else {
+			sum += digit
+		}
+	}
+	return (10-sum%10)%10 == int(gtin[len(gtin)-1]-'0')
+}
+
+// GTINCandidates returns the barcodes a GTIN-14 may have been stored as:
+// the GTIN itself and the EAN-13, UPC-12 and EAN-8 forms without leading zeros
+func GTINCandidates(gtin string) []string {
+	candidates := []string{gtin}
+	for _, length := range []int{13, 12, 8} {
+		if strings.Trim(gtin[:len(gtin)-length], "0") == "" {
+			candidates = append(candidates, gtin[len(gtin)-length:])
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,201 @@
+package gs1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIsElementString(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"4006381333931", false},
+		{"012345678905", false},
+		{"]d20109506000134352", true},
+		{"]C10109506000134352", true},
+		{"(01)09506000134352(10)ABC", true},
+		{"0109506000134352\x1d10ABC", true},
+		{"0109506000134352", true},
+		{"010950600013435", false},
+	}
+	for _, tt := range tests {
+		if got := IsElementString(tt.code); got != tt.want {
+			t.Errorf("IsElementString(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	expiry := time.Date(2027, time.March, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		code    string
+		want    *Data
+		wantErr bool
+	}{
+		{
+			name: "raw with symbology identifier",
+			code: "]d2010950600013435217270331\x1d10AB-123\x1d21SN1",
+			want: &Data{GTIN: "09506000134352", Expiry: &expiry, BatchNumber: "AB-123", Serial: "SN1"},
+		},
+		{
+			name: "bracketed",
+			code: "(01)09506000134352(17)270331(10)AB-123",
+			want: &Data{GTIN: "09506000134352", Expiry: &expiry, BatchNumber: "AB-123"},
+		},
+		{
+			name: "batch before expiry is terminated by FNC1",
+			code: "0109506000134352" + "10AB-123\x1d" + "17270331",
+			want: &Data{GTIN: "09506000134352", Expiry: &expiry, BatchNumber: "AB-123"},
+		},
+		{
+			name: "variable field without FNC1 runs to the end",
+			code: "010950600013435210LOT7",
+			want: &Data{GTIN: "09506000134352", BatchNumber: "LOT7"},
+		},
+		{
+			name: "day 00 is the last day of the month",
+			code: "010950600013435217270300",
+			want: &Data{GTIN: "09506000134352", Expiry: &expiry},
+		},
+		{
+			name: "production and best before dates are skipped",
+			code: "01095060001343521126010115270331",
+			want: &Data{GTIN: "09506000134352"},
+		},
+		{name: "wrong check digit", code: "0109506000134353", wantErr: true},
+		{name: "non-digit gtin", code: "01095060001343A2", wantErr: true},
+		{name: "truncated gtin", code: "01095060001343", wantErr: true},
+		{name: "unsupported identifier", code: "010950600013435230100", wantErr: true},
+		{name: "no gtin", code: "(10)AB-123", wantErr: true},
+		{name: "empty batch", code: "(01)09506000134352(10)", wantErr: true},
+		{name: "invalid expiry month", code: "010950600013435217271331", wantErr: true},
+		{name: "invalid expiry day", code: "010950600013435217270230", wantErr: true},
+		{name: "truncated identifier", code: "01095060001343521", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.code)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %+v, want error", tt.code, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.code, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	century := time.Now().Year() / 100 * 100
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "270331", want: time.Date(century+27, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{value: "270200", want: time.Date(century+27, time.February, 28, 0, 0, 0, 0, time.UTC)},
+		{value: "280200", want: time.Date(century+28, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{value: "271200", want: time.Date(century+27, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{value: "270229", wantErr: true},
+		{value: "270431", wantErr: true},
+		{value: "270001", wantErr: true},
+		{value: "271301", wantErr: true},
+		{value: "27AB01", wantErr: true},
+		{value: "27+301", wantErr: true},
+		{value: "2703 1", wantErr: true},
+		{value: "27031", wantErr: true},
+		{value: "2703011", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseDate(%q) = %v, want error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDate(%q) error: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseDateCenturyWindow(t *testing.T) {
+	currentYear := time.Now().Year()
+	tests := []struct {
+		offset int
+		want   int
+	}{
+		{offset: 0, want: currentYear},
+		{offset: 50, want: currentYear + 50},
+		{offset: 51, want: currentYear + 51 - 100},
+		{offset: -49, want: currentYear - 49},
+		{offset: -50, want: currentYear - 50 + 100},
+	}
+	for _, tt := range tests {
+		year := currentYear + tt.offset
+		value := time.Date(year, time.June, 15, 0, 0, 0, 0, time.UTC).Format("060102")
+		got, err := parseDate(value)
+		if err != nil {
+			t.Fatalf("parseDate(%q) error: %v", value, err)
+		}
+		if got.Year() != tt.want {
+			t.Errorf("parseDate(%q) year = %d, want %d", value, got.Year(), tt.want)
+		}
+	}
+}
+
+func TestValidGTIN(t *testing.T) {
+	tests := []struct {
+		gtin string
+		want bool
+	}{
+		{"09506000134352", true},
+		{"04006381333931", true},
+		{"00012345678905", true},
+		{"00000096385074", true},
+		{"4006381333931", true},
+		{"96385074", true},
+		{"09506000134353", false},
+		{"04006381333932", false},
+		{"0950600013435A", false},
+		{"09506 00134352", false},
+		{"0", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidGTIN(tt.gtin); got != tt.want {
+			t.Errorf("ValidGTIN(%q) = %v, want %v", tt.gtin, got, tt.want)
+		}
+	}
+}
+
+func TestGTINCandidates(t *testing.T) {
+	tests := []struct {
+		gtin string
+		want []string
+	}{
+		{"09506000134352", []string{"09506000134352", "9506000134352"}},
+		{"00012345678905", []string{"00012345678905", "0012345678905", "012345678905"}},
+		{"00000096385074", []string{"00000096385074", "0000096385074", "000096385074", "96385074"}},
+		{"19506000134359", []string{"19506000134359"}},
+	}
+	for _, tt := range tests {
+		if got := GTINCandidates(tt.gtin); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GTINCandidates(%q) = %v, want %v", tt.gtin, got, tt.want)
+		}
+	}
+}
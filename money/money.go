@@ -0,0 +1,261 @@
+// Package money implements exact Rupiah amounts for prices and totals.
+//
+// Amounts are kept in sen (hundredths of a Rupiah) as integers, so they map
+// exactly to the NUMERIC(15,2) columns and sums never drift. The rounding
+// rules are:
+//
+//   - prices and amounts entered through the API must have at most two
+//     decimals; they are never rounded silently
+//   - a line total is the unit price multiplied by the quantity, which is
+//     always exact
+//   - discounts and tax are percentages of an exact base and are rounded
+//     half away from zero to the sen, once per amount they apply to
+//   - the tax in a tax-inclusive amount is the amount less its tax base, so
+//     base and tax always add up to the amount
+//   - a unit price derived from a line total (total / quantity) is rounded
+//     the same way
+//   - a proportional share of an amount, e.g. of an invoice discount spread
+//     over the lines, is rounded the same way
+//   - values computed in SQL with more than two decimals are rounded the
+//     same way when scanned
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is an amount of money in sen
+type Amount int64
+
+// Zero is the zero amount
+const Zero Amount = 0
+
+// sen per Rupiah
+const scale = 100
+
+// FromRupiah returns the amount for a whole number of Rupiah
+func FromRupiah(rupiah int64) Amount {
+	return Amount(rupiah * scale)
+}
+
+// Parse parses a decimal amount such as "15000", "15000.5" or "-2500.75".
+// Amounts with more than two decimals are rejected.
+func Parse(s string) (Amount, error) {
+	amount, exact, err := parse(s)
+	if err != nil {
+		return 0, err
+	}
+	if !exact {
+		return 0, fmt.Errorf("amount %s has more than 2 decimal places", s)
+	}
+	return amount, nil
+}
+
+// parse parses a decimal amount, rounding half away from zero to the sen.
+// exact reports whether no rounding was needed.
+func parse(s string) (amount Amount, exact bool, err error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	digits := s
+	if negative || strings.HasPrefix(s, "+") {
+		digits = s[1:]
+	}
+
+	whole, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		whole, fraction = digits[:i], digits[i+1:]
+	}
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return 0, false, fmt.Errorf("invalid amount: %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	rupiah, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || rupiah > (1<<63-1)/scale-1 {
+		return 0, false, fmt.Errorf("amount out of range: %q", s)
+	}
+
+	exact = true
+	sen := int64(0)
+	for i := 0; i < len(fraction); i++ {
+		digit := int64(fraction[i] - '0')
+		switch {
+		case i < 2:
+			sen = sen*10 + digit
+		case i == 2:
+			if digit >= 5 {
+				sen++
+			}
+			exact = exact && digit == 0
+		default:
+			exact = exact && digit == 0
+		}
+	}
+	for i := len(fraction); i < 2; i++ {
+		sen *= 10
+	}
+
+	amount = Amount(rupiah*scale + sen)
+	if negative {
+		amount = -amount
+	}
+	return amount, exact, nil
+}
+
+// isDigits reports whether s consists of ASCII digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount with two decimals, e.g. "15000.50"
+func (a Amount) String() string {
+	sign := ""
+	sen := int64(a)
+	if sen < 0 {
+		sign = "-"
+		sen = -sen
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, sen/scale, sen%scale)
+}
+
+// Mul returns the amount multiplied by a quantity, e.g. a line total
+func (a Amount) Mul(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// Div returns the amount divided by a quantity, rounded half away from zero,
+// e.g. the unit price of a line total
+func (a Amount) Div(quantity int) Amount {
+	if quantity == 0 {
+		return 0
+	}
+	return Amount(divRound(int64(a), int64(quantity)))
+}
+
+// Percent returns the given percentage of the amount, expressed in basis
+// points (1100 is 11%), rounded half away from zero to the sen
+func (a Amount) Percent(basisPoints int64) Amount {
+	return Amount(divRound(int64(a)*basisPoints, 10000))
+}
+
+// ExcludePercent returns the amount before a percentage in basis points was
+// added to it, rounded half away from zero, e.g. the tax base of a
+// tax-inclusive price
+func (a Amount) ExcludePercent(basisPoints int64) Amount {
+	return Amount(divRound(int64(a)*10000, 10000+basisPoints))
+}
+
+// MulRatio returns the amount multiplied by num/den, rounded half away from
+// zero, e.g. the part of a line total left after its share of an invoice
+// discount. The product is computed without overflow.
+func (a Amount) MulRatio(num, den Amount) Amount {
+	if den == 0 {
+		return 0
+	}
+	n := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(num)))
+	d := big.NewInt(int64(den))
+	if d.Sign() < 0 {
+		n.Neg(n)
+		d.Neg(d)
+	}
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Abs(r).Lsh(r, 1).Cmp(d) >= 0 {
+		if n.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return Amount(q.Int64())
+}
+
+// divRound divides rounding half away from zero
+func divRound(n, d int64) int64 {
+	if d < 0 {
+		n, d = -n, -d
+	}
+	q, r := n/d, n%d
+	if r < 0 {
+		r = -r
+	}
+	if 2*r >= d {
+		if n < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return q
+}
+
+// MarshalJSON encodes the amount as a JSON number with two decimals
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or string with at most two decimals
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	// Accept exponent notation as produced by some clients for whole numbers
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount: %s", s)
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// Scan implements the database/sql Scanner interface for NUMERIC columns.
+// Computed values with more than two decimals are rounded to the sen.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case string:
+		amount, _, err := parse(v)
+		if err != nil {
+			return err
+		}
+		*a = amount
+		return nil
+	case []byte:
+		return a.Scan(string(v))
+	case int64:
+		*a = FromRupiah(v)
+		return nil
+	case float64:
+		return a.Scan(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return fmt.Errorf("cannot scan amount from %T", src)
+}
+
+// Value implements the database/sql/driver Valuer interface
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// SkipUnderlyingTypePlan makes the database driver use Scan and Value
+// instead of treating the amount as a plain integer
+func (Amount) SkipUnderlyingTypePlan() {}
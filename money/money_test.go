@@ -0,0 +1,227 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{in: "15000", want: 1500000},
+		{in: "15000.5", want: 1500050},
+		{in: "15000.50", want: 1500050},
+		{in: "15000.500", want: 1500050},
+		{in: "-2500.75", want: -250075},
+		{in: "+12.01", want: 1201},
+		{in: ".5", want: 50},
+		{in: "5.", want: 500},
+		{in: " 7.25 ", want: 725},
+		{in: "0", want: 0},
+		{in: "92233720368547756.00", want: 9223372036854775600},
+		{in: "15000.505", wantErr: true},
+		{in: "0.001", wantErr: true},
+		{in: "", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "-+5", wantErr: true},
+		{in: "--5", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "1,50", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "92233720368547758", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScanRounds(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want Amount
+	}{
+		{src: "10.004", want: 1000},
+		{src: "10.005", want: 1001},
+		{src: "10.0049", want: 1000},
+		{src: "-10.005", want: -1001},
+		{src: "0.995", want: 100},
+		{src: []byte("3333.333333"), want: 333333},
+		{src: int64(42), want: 4200},
+		{src: float64(12.5), want: 1250},
+		{src: nil, want: 0},
+	}
+	for _, tt := range tests {
+		var got Amount
+		if err := got.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%v) error: %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Scan(%v) = %d, want %d", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1500050, "15000.50"},
+		{-5, "-0.05"},
+		{-250075, "-2500.75"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{in: `15000.5`, want: 1500050},
+		{in: `"15000.50"`, want: 1500050},
+		{in: `1.5e4`, want: 1500000},
+		{in: `null`, want: 0},
+		{in: `0.125`, wantErr: true},
+		{in: `""`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got Amount
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	data, err := json.Marshal(Amount(1500050))
+	if err != nil || string(data) != "15000.50" {
+		t.Errorf("Marshal = %s, %v, want 15000.50", data, err)
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		quantity int
+		want     Amount
+	}{
+		{1000, 3, 333},
+		{2000, 3, 667},
+		{1000, 8, 125},
+		{1, 2, 1},
+		{-1, 2, -1},
+		{-2000, 3, -667},
+		{1000, -3, -333},
+		{1000, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Div(tt.quantity); got != tt.want {
+			t.Errorf("Amount(%d).Div(%d) = %d, want %d", tt.amount, tt.quantity, got, tt.want)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount      Amount
+		basisPoints int64
+		want        Amount
+	}{
+		{1000000, 1100, 110000},
+		{4545, 1100, 500},
+		{4540, 1100, 499},
+		{50, 1000, 5},
+		{45, 1000, 5},
+		{44, 1000, 4},
+		{-45, 1000, -5},
+		{12345, 0, 0},
+		{12345, 10000, 12345},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Percent(tt.basisPoints); got != tt.want {
+			t.Errorf("Amount(%d).Percent(%d) = %d, want %d", tt.amount, tt.basisPoints, got, tt.want)
+		}
+	}
+}
+
+func TestExcludePercent(t *testing.T) {
+	tests := []struct {
+		amount      Amount
+		basisPoints int64
+		want        Amount
+	}{
+		{1110000, 1100, 1000000},
+		{111, 1100, 100},
+		{100, 1100, 90},
+		{1, 1100, 1},
+		{-111, 1100, -100},
+		{12345, 0, 12345},
+	}
+	for _, tt := range tests {
+		got := tt.amount.ExcludePercent(tt.basisPoints)
+		if got != tt.want {
+			t.Errorf("Amount(%d).ExcludePercent(%d) = %d, want %d", tt.amount, tt.basisPoints, got, tt.want)
+		}
+		// The tax base plus tax on it must come back to the amount within a sen
+		if diff := got + got.Percent(tt.basisPoints) - tt.amount; diff < -1 || diff > 1 {
+			t.Errorf("Amount(%d).ExcludePercent(%d) = %d does not add back up", tt.amount, tt.basisPoints, got)
+		}
+	}
+}
+
+func TestMulRatio(t *testing.T) {
+	tests := []struct {
+		amount, num, den Amount
+		want             Amount
+	}{
+		{1000, 1, 3, 333},
+		{1000, 2, 3, 667},
+		{300000, 270000, 300000, 270000},
+		{1, 1, 2, 1},
+		{-1, 1, 2, -1},
+		{1000, 2, -3, -667},
+		{1000, 1, 0, 0},
+		// The product overflows int64 but the result does not
+		{900000000000000000, 900000000000000000, 1000000000000000000, 810000000000000000},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.MulRatio(tt.num, tt.den); got != tt.want {
+			t.Errorf("Amount(%d).MulRatio(%d, %d) = %d, want %d", tt.amount, tt.num, tt.den, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package pagination implements the cursor, page size, sort and filter
+// parameter handling shared by the list endpoints.
+//
+// Pages are keyset based: a cursor holds the sort value and ID of the last row
+// of a page, and the next page selects the rows that sort after it. Sort
+// values travel as text and are cast back to the column type in SQL, so they
+// compare exactly like the column itself.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"encore.dev/types/uuid"
+)
+
+// Page size limits
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Cursor points at the last row of a page
+type Cursor struct {
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// Encode returns the opaque cursor string for a row
+func Encode(value string, id uuid.UUID) string {
+	data, _ := json.Marshal(Cursor{Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a cursor string. An empty string decodes to a nil cursor,
+// i.e. the first page.
+func Decode(cursor string) (*Cursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("cursor is invalid")
+	}
+	var c Cursor
+	if err = json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil {
+		return nil, errors.New("cursor is invalid")
+	}
+	return &c, nil
+}
+
+// Limit returns the page size for a requested limit, using the default when none is given
+func Limit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	return limit
+}
+
+// ValidateLimit checks a requested page size
+func ValidateLimit(limit int) error {
+	if limit < 0 || limit > MaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	}
+	return nil
+}
+
+// Column is a column a list can be sorted by: the SQL expression and the SQL
+// type its cursor value is cast back to
+type Column struct {
+	Expr string
+	Type string
+}
+
+// Sort is a validated sort order
+type Sort struct {
+	Column Column
+	Desc   bool
+}
+
+// ParseSort parses a sort parameter like "name" or "-created_at" (descending)
+// against the allowed columns. An empty parameter selects the default.
+func ParseSort(param string, columns map[string]Column, defaultSort string) (Sort, error) {
+	if param == "" {
+		param = defaultSort
+	}
+	desc := strings.HasPrefix(param, "-")
+	column, ok := columns[strings.TrimPrefix(param, "-")]
+	if !ok {
+		names := make([]string, 0, len(columns))
+		for name := range columns {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return Sort{}, fmt.Errorf("sort must be one of: %s (prefix with - for descending)", strings.Join(names, ", "))
+	}
+	return Sort{Column: column, Desc: desc}, nil
+}
+
+// OrderBy returns the ORDER BY expression list, with the ID as tie breaker
+func (s Sort) OrderBy(idExpr string) string {
+	direction := "ASC"
+	if s.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("%s %s, %s %s", s.Column.Expr, direction, idExpr, direction)
+}
+
+// After returns the condition selecting the rows after a cursor. The cursor
+// value and ID are bound to the given parameter positions; a NULL cursor value
+// selects every row.
+func (s Sort) After(idExpr string, valueParam, idParam int) string {
+	op := ">"
+	if s.Desc {
+		op = "<"
+	}
+	return fmt.Sprintf("($%d::text IS NULL OR (%s, %s) %s ($%d::text::%s, $%d::uuid))",
+		valueParam, s.Column.Expr, idExpr, op, valueParam, s.Column.Type, idParam)
+}
+
+// Args returns the cursor value and ID to bind to the parameters used in After
+func Args(cursor *Cursor) (*string, *uuid.UUID) {
+	if cursor == nil {
+		return nil, nil
+	}
+	return &cursor.Value, &cursor.ID
+}
+
+// SearchPattern turns a free-text search into an ILIKE pattern matching the
+// text anywhere. An empty search returns nil so the filter is skipped.
+func SearchPattern(search string) *string {
+	search = strings.TrimSpace(search)
+	if search == "" {
+		return nil
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(search)
+	pattern := "%" + escaped + "%"
+	return &pattern
+}
+
+// OptionalBool parses an optional boolean filter. An empty value returns nil
+// so the filter is skipped.
+func OptionalBool(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
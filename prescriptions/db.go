@@ -0,0 +1,7 @@
+package prescriptions
+
+import "encore.dev/storage/sqldb"
+
+var db = sqldb.NewDatabase("prescriptions", sqldb.DatabaseConfig{
+	Migrations: "./migrations",
+})
@@ -0,0 +1,212 @@
+package prescriptions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.dev/types/uuid"
+)
+
+type Response struct {
+	Message string `json:"message"`
+}
+
+type PrescriptionItemRequest struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Dosage    string    `json:"dosage"`
+	Quantity  int       `json:"quantity"`
+}
+
+type CreatePrescriptionRequest struct {
+	PrescriptionNumber      string                    `json:"prescription_number"`
+	PrescriberName          string                    `json:"prescriber_name"`
+	PrescriberLicenseNumber string                    `json:"prescriber_license_number"`
+	PatientName             string                    `json:"patient_name"`
+	PatientBirthDate        *time.Time                `json:"patient_birth_date"`
+	PatientAddress          string                    `json:"patient_address"`
+	IssuedDate              time.Time                 `json:"issued_date"`
+	ValidUntil              *time.Time                `json:"valid_until"`
+	RefillsAllowed          int                       `json:"refills_allowed"`
+	Notes                   string                    `json:"notes"`
+	Items                   []PrescriptionItemRequest `json:"items"`
+	CreatedBy               uuid.UUID                 `json:"created_by"`
+}
+
+func (p *CreatePrescriptionRequest) Validate() error {
+	if p.PrescriptionNumber == "" {
+		return errors.New("prescription_number is required")
+	}
+	if len(p.PrescriptionNumber) > 50 {
+		return errors.New("prescription_number must be less than 50 characters")
+	}
+	if p.PrescriberName == "" {
+		return errors.New("prescriber_name is required")
+	}
+	if len(p.PrescriberName) > 200 {
+		return errors.New("prescriber_name must be less than 200 characters")
+	}
+	if len(p.PrescriberLicenseNumber) > 100 {
+		return errors.New("prescriber_license_number must be less than 100 characters")
+	}
+	if p.PatientName == "" {
+		return errors.New("patient_name is required")
+	}
+	if len(p.PatientName) > 200 {
+		return errors.New("patient_name must be less than 200 characters")
+	}
+	if p.IssuedDate.IsZero() {
+		return errors.New("issued_date is required")
+	}
+	if p.ValidUntil != nil && p.ValidUntil.Before(p.IssuedDate) {
+		return errors.New("valid_until must not be before issued_date")
+	}
+	if p.RefillsAllowed < 0 {
+		return errors.New("refills_allowed must be non-negative")
+	}
+	if len(p.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+	if p.CreatedBy == uuid.Nil {
+		return errors.New("created_by is required")
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for i, item := range p.Items {
+		itemNum := i + 1
+		if item.ProductID == uuid.Nil {
+			return fmt.Errorf("product_id is required for item %d", itemNum)
+		}
+		if seen[item.ProductID] {
+			return fmt.Errorf("product_id is duplicated for item %d", itemNum)
+		}
+		seen[item.ProductID] = true
+		if item.Dosage == "" {
+			return fmt.Errorf("dosage is required for item %d", itemNum)
+		}
+		if len(item.Dosage) > 255 {
+			return fmt.Errorf("dosage must be less than 255 characters for item %d", itemNum)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0 for item %d", itemNum)
+		}
+	}
+
+	return nil
+}
+
+type PrescriptionItemDetail struct {
+	ID                uuid.UUID `json:"id"`
+	ProductID         uuid.UUID `json:"product_id"`
+	ProductName       string    `json:"product_name"`
+	Dosage            string    `json:"dosage"`
+	Quantity          int       `json:"quantity"`
+	DispensedQuantity int       `json:"dispensed_quantity"`
+	RemainingQuantity int       `json:"remaining_quantity"`
+}
+
+type PrescriptionFillItem struct {
+	PrescriptionItemID uuid.UUID `json:"prescription_item_id"`
+	ProductID          uuid.UUID `json:"product_id"`
+	Quantity           int       `json:"quantity"`
+	RefillNumber       int       `json:"refill_number"`
+}
+
+type PrescriptionFillDetail struct {
+	ID          uuid.UUID              `json:"id"`
+	SaleID      *uuid.UUID             `json:"sale_id,omitempty"`
+	DispensedBy uuid.UUID              `json:"dispensed_by"`
+	FilledAt    time.Time              `json:"filled_at"`
+	ReversedAt  *time.Time             `json:"reversed_at,omitempty"`
+	Items       []PrescriptionFillItem `json:"items"`
+}
+
+type PrescriptionDetail struct {
+	ID                      uuid.UUID                `json:"id"`
+	PrescriptionNumber      string                   `json:"prescription_number"`
+	PrescriberName          string                   `json:"prescriber_name"`
+	PrescriberLicenseNumber string                   `json:"prescriber_license_number"`
+	PatientName             string                   `json:"patient_name"`
+	PatientBirthDate        *time.Time               `json:"patient_birth_date,omitempty"`
+	PatientAddress          string                   `json:"patient_address"`
+	IssuedDate              time.Time                `json:"issued_date"`
+	ValidUntil              *time.Time               `json:"valid_until,omitempty"`
+	RefillsAllowed          int                      `json:"refills_allowed"`
+	RefillsUsed             int                      `json:"refills_used"`
+	Status                  string                   `json:"status"`
+	IsExpired               bool                     `json:"is_expired"`
+	Notes                   string                   `json:"notes"`
+	Items                   []PrescriptionItemDetail `json:"items"`
+	Fills                   []PrescriptionFillDetail `json:"fills"`
+}
+
+type PrescriptionResponse struct {
+	Message string              `json:"message"`
+	Data    *PrescriptionDetail `json:"data,omitempty"`
+}
+
+type PrescriptionListItem struct {
+	ID                 uuid.UUID  `json:"id"`
+	PrescriptionNumber string     `json:"prescription_number"`
+	PrescriberName     string     `json:"prescriber_name"`
+	PatientName        string     `json:"patient_name"`
+	IssuedDate         time.Time  `json:"issued_date"`
+	ValidUntil         *time.Time `json:"valid_until,omitempty"`
+	RefillsAllowed     int        `json:"refills_allowed"`
+	RefillsUsed        int        `json:"refills_used"`
+	Status             string     `json:"status"`
+	TotalItem          int        `json:"total_item"`
+}
+
+type ListPrescriptionsResponse struct {
+	Message string                 `json:"message"`
+	Data    []PrescriptionListItem `json:"data"`
+}
+
+type FillItem struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+}
+
+type FillPrescriptionRequest struct {
+	SaleID      *uuid.UUID `json:"sale_id"`
+	DispensedBy uuid.UUID  `json:"dispensed_by"`
+	Items       []FillItem `json:"items"`
+}
+
+func (f *FillPrescriptionRequest) Validate() error {
+	if f.DispensedBy == uuid.Nil {
+		return errors.New("dispensed_by is required")
+	}
+	if len(f.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for i, item := range f.Items {
+		itemNum := i + 1
+		if item.ProductID == uuid.Nil {
+			return fmt.Errorf("product_id is required for item %d", itemNum)
+		}
+		if seen[item.ProductID] {
+			return fmt.Errorf("product_id is duplicated for item %d", itemNum)
+		}
+		seen[item.ProductID] = true
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0 for item %d", itemNum)
+		}
+	}
+
+	return nil
+}
+
+type FillPrescriptionResponse struct {
+	Message string     `json:"message"`
+	FillID  *uuid.UUID `json:"fill_id,omitempty"`
+	Status  string     `json:"status,omitempty"`
+}
+
+type ProductReferencesResponse struct {
+	Message           string `json:"message"`
+	PrescriptionItems int    `json:"prescription_items"`
+}
@@ -0,0 +1,2 @@
+-- Drop prescriptions table
+DROP TABLE IF EXISTS prescriptions;
@@ -0,0 +1,26 @@
+-- Create prescriptions table
+-- id, prescription_number, prescriber_name, prescriber_license_number, patient_name, patient_birth_date, patient_address,
+-- issued_date, valid_until, refills_allowed, refills_used, status, notes, created_by, created_at, updated_at
+CREATE TABLE prescriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    prescription_number VARCHAR(50) NOT NULL UNIQUE,
+    prescriber_name VARCHAR(200) NOT NULL,
+    prescriber_license_number VARCHAR(100),
+    patient_name VARCHAR(200) NOT NULL,
+    patient_birth_date DATE,
+    patient_address TEXT,
+    issued_date DATE NOT NULL,
+    valid_until DATE,
+    refills_allowed INT NOT NULL DEFAULT 0 CHECK (refills_allowed >= 0),
+    refills_used INT NOT NULL DEFAULT 0 CHECK (refills_used >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'partially_filled', 'filled', 'cancelled')),
+    notes TEXT,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (refills_used <= refills_allowed)
+);
+
+-- Create indexes
+CREATE INDEX idx_prescriptions_patient_name ON prescriptions(patient_name);
+CREATE INDEX idx_prescriptions_status ON prescriptions(status);
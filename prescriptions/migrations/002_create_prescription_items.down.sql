@@ -0,0 +1,2 @@
+-- Drop prescription_items table
+DROP TABLE IF EXISTS prescription_items;
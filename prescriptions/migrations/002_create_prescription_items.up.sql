@@ -0,0 +1,17 @@
+-- Create prescription_items table
+-- quantity is the amount per fill; dispensed_quantity accumulates over the original fill and all refills
+-- id, prescription_id, product_id, dosage, quantity, dispensed_quantity, created_at
+CREATE TABLE prescription_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    prescription_id UUID NOT NULL REFERENCES prescriptions(id),
+    product_id UUID NOT NULL,
+    dosage VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    dispensed_quantity INT NOT NULL DEFAULT 0 CHECK (dispensed_quantity >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (prescription_id, product_id)
+);
+
+-- Create indexes
+CREATE INDEX idx_prescription_items_prescription_id ON prescription_items(prescription_id);
+CREATE INDEX idx_prescription_items_product_id ON prescription_items(product_id);
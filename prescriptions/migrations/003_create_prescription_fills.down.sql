@@ -0,0 +1,3 @@
+-- Drop prescription fill tables
+DROP TABLE IF EXISTS prescription_fill_items;
+DROP TABLE IF EXISTS prescription_fills;
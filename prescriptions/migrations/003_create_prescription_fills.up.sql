@@ -0,0 +1,27 @@
+-- Create prescription_fills table
+-- One row per dispensing event against a prescription
+-- id, prescription_id, sale_id, dispensed_by, filled_at, reversed_at
+CREATE TABLE prescription_fills (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    prescription_id UUID NOT NULL REFERENCES prescriptions(id),
+    sale_id UUID,
+    dispensed_by UUID NOT NULL,
+    filled_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    reversed_at TIMESTAMP
+);
+
+-- Create prescription_fill_items table
+-- refill_number is 0 for the original fill, 1 for the first refill, and so on
+-- id, fill_id, prescription_item_id, quantity, refill_number
+CREATE TABLE prescription_fill_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fill_id UUID NOT NULL REFERENCES prescription_fills(id),
+    prescription_item_id UUID NOT NULL REFERENCES prescription_items(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    refill_number INT NOT NULL CHECK (refill_number >= 0)
+);
+
+-- Create indexes
+CREATE INDEX idx_prescription_fills_prescription_id ON prescription_fills(prescription_id);
+CREATE INDEX idx_prescription_fills_sale_id ON prescription_fills(sale_id);
+CREATE INDEX idx_prescription_fill_items_fill_id ON prescription_fill_items(fill_id);
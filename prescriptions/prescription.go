@@ -0,0 +1,545 @@
+package prescriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/product"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// Prescription model
+type Prescription struct {
+	ID                      uuid.UUID  `json:"id"`
+	PrescriptionNumber      string     `json:"prescription_number"`
+	PrescriberName          string     `json:"prescriber_name"`
+	PrescriberLicenseNumber string     `json:"prescriber_license_number"`
+	PatientName             string     `json:"patient_name"`
+	PatientBirthDate        *time.Time `json:"patient_birth_date,omitempty"`
+	PatientAddress          string     `json:"patient_address"`
+	IssuedDate              time.Time  `json:"issued_date"`
+	ValidUntil              *time.Time `json:"valid_until,omitempty"`
+	RefillsAllowed          int        `json:"refills_allowed"`
+	RefillsUsed             int        `json:"refills_used"`
+	Status                  string     `json:"status"`
+	Notes                   string     `json:"notes"`
+	CreatedBy               uuid.UUID  `json:"created_by"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at"`
+}
+
+// PrescriptionItem model
+type PrescriptionItem struct {
+	ID                uuid.UUID `json:"id"`
+	PrescriptionID    uuid.UUID `json:"prescription_id"`
+	ProductID         uuid.UUID `json:"product_id"`
+	Dosage            string    `json:"dosage"`
+	Quantity          int       `json:"quantity"`
+	DispensedQuantity int       `json:"dispensed_quantity"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// CreatePrescription records a prescription with its prescribed products
+//
+//encore:api public method=POST path=/api/prescriptions
+func CreatePrescription(ctx context.Context, req *CreatePrescriptionRequest) (PrescriptionResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return PrescriptionResponse{Message: "Validation failed"}, err
+	}
+
+	// Check if prescription number already exists
+	var numberExists bool
+	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM prescriptions WHERE prescription_number = $1)", req.PrescriptionNumber).Scan(&numberExists)
+	if err != nil {
+		return PrescriptionResponse{Message: "Failed to check prescription number"}, err
+	}
+	if numberExists {
+		return PrescriptionResponse{Message: "Prescription number already exists"}, errors.New("prescription number already exists")
+	}
+
+	// Validate products exist in product service and are still sold
+	for _, item := range req.Items {
+		productData, err := product.GetProduct(ctx, item.ProductID)
+		if err != nil {
+			return PrescriptionResponse{Message: "Product not found: " + item.ProductID.String()}, errors.New("product not found")
+		}
+		if !productData.IsActive {
+			return PrescriptionResponse{Message: "Product is not active: " + productData.Name}, errors.New("product is not active")
+		}
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return PrescriptionResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	var prescriptionID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO prescriptions (prescription_number, prescriber_name, prescriber_license_number, patient_name, patient_birth_date, patient_address, issued_date, valid_until, refills_allowed, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`, req.PrescriptionNumber, req.PrescriberName, req.PrescriberLicenseNumber, req.PatientName, req.PatientBirthDate, req.PatientAddress, req.IssuedDate, req.ValidUntil, req.RefillsAllowed, req.Notes, req.CreatedBy).Scan(&prescriptionID)
+	if err != nil {
+		return PrescriptionResponse{Message: "Failed to create prescription"}, err
+	}
+
+	for _, item := range req.Items {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO prescription_items (prescription_id, product_id, dosage, quantity)
+			VALUES ($1, $2, $3, $4)
+		`, prescriptionID, item.ProductID, item.Dosage, item.Quantity)
+		if err != nil {
+			return PrescriptionResponse{Message: "Failed to create prescription item"}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return PrescriptionResponse{Message: "Failed to commit prescription"}, err
+	}
+
+	return GetPrescription(ctx, prescriptionID)
+}
+
+// GetAllPrescriptions retrieves all prescriptions, most recently issued first
+//
+//encore:api public method=GET path=/api/prescriptions
+func GetAllPrescriptions(ctx context.Context) (ListPrescriptionsResponse, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			p.id,
+			p.prescription_number,
+			p.prescriber_name,
+			p.patient_name,
+			p.issued_date,
+			p.valid_until,
+			p.refills_allowed,
+			p.refills_used,
+			p.status,
+			COALESCE(COUNT(pi.id), 0) as total_item
+		FROM prescriptions p
+		LEFT JOIN prescription_items pi ON p.id = pi.prescription_id
+		GROUP BY p.id
+		ORDER BY p.issued_date DESC, p.created_at DESC
+	`)
+	if err != nil {
+		return ListPrescriptionsResponse{
+			Message: "Failed to retrieve prescriptions",
+			Data:    []PrescriptionListItem{},
+		}, errors.New("failed to retrieve prescriptions")
+	}
+	defer rows.Close()
+
+	var prescriptions []PrescriptionListItem
+	for rows.Next() {
+		var prescription PrescriptionListItem
+		err = rows.Scan(
+			&prescription.ID,
+			&prescription.PrescriptionNumber,
+			&prescription.PrescriberName,
+			&prescription.PatientName,
+			&prescription.IssuedDate,
+			&prescription.ValidUntil,
+			&prescription.RefillsAllowed,
+			&prescription.RefillsUsed,
+			&prescription.Status,
+			&prescription.TotalItem,
+		)
+		if err != nil {
+			return ListPrescriptionsResponse{Message: "Failed to scan prescription"}, errors.New("failed to scan prescription")
+		}
+		prescriptions = append(prescriptions, prescription)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListPrescriptionsResponse{Message: "Error iterating prescriptions"}, errors.New("error iterating prescriptions: " + err.Error())
+	}
+
+	return ListPrescriptionsResponse{
+		Message: "Prescriptions retrieved successfully",
+		Data:    prescriptions,
+	}, nil
+}
+
+// GetPrescription retrieves a prescription with its items and fill history
+//
+//encore:api public method=GET path=/api/prescriptions/:id
+func GetPrescription(ctx context.Context, id uuid.UUID) (PrescriptionResponse, error) {
+	var detail PrescriptionDetail
+	var licenseNumber, patientAddress, notes *string
+	err := db.QueryRow(ctx, `
+		SELECT
+			id, prescription_number, prescriber_name, prescriber_license_number, patient_name, patient_birth_date, patient_address,
+			issued_date, valid_until, refills_allowed, refills_used, status, notes,
+			COALESCE(valid_until < CURRENT_DATE, FALSE) as is_expired
+		FROM prescriptions
+		WHERE id = $1
+	`, id).Scan(
+		&detail.ID,
+		&detail.PrescriptionNumber,
+		&detail.PrescriberName,
+		&licenseNumber,
+		&detail.PatientName,
+		&detail.PatientBirthDate,
+		&patientAddress,
+		&detail.IssuedDate,
+		&detail.ValidUntil,
+		&detail.RefillsAllowed,
+		&detail.RefillsUsed,
+		&detail.Status,
+		&notes,
+		&detail.IsExpired,
+	)
+	if err != nil {
+		return PrescriptionResponse{Message: "Prescription not found"}, errors.New("prescription not found")
+	}
+	if licenseNumber != nil {
+		detail.PrescriberLicenseNumber = *licenseNumber
+	}
+	if patientAddress != nil {
+		detail.PatientAddress = *patientAddress
+	}
+	if notes != nil {
+		detail.Notes = *notes
+	}
+
+	// Load prescribed items
+	rows, err := db.Query(ctx, `
+		SELECT id, product_id, dosage, quantity, dispensed_quantity
+		FROM prescription_items
+		WHERE prescription_id = $1
+		ORDER BY created_at, id
+	`, id)
+	if err != nil {
+		return PrescriptionResponse{Message: "Failed to retrieve prescription items"}, errors.New("failed to retrieve prescription items")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item PrescriptionItemDetail
+		if err = rows.Scan(&item.ID, &item.ProductID, &item.Dosage, &item.Quantity, &item.DispensedQuantity); err != nil {
+			return PrescriptionResponse{Message: "Failed to scan prescription item"}, errors.New("failed to scan prescription item")
+		}
+		item.RemainingQuantity = item.Quantity*(detail.RefillsAllowed+1) - item.DispensedQuantity
+		detail.Items = append(detail.Items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return PrescriptionResponse{Message: "Error iterating prescription items"}, errors.New("error iterating prescription items: " + err.Error())
+	}
+	rows.Close()
+
+	// Resolve product names from product service
+	for i := range detail.Items {
+		if productData, err := product.GetProduct(ctx, detail.Items[i].ProductID); err == nil {
+			detail.Items[i].ProductName = productData.Name
+		}
+	}
+
+	// Load fill history
+	rows, err = db.Query(ctx, `
+		SELECT f.id, f.sale_id, f.dispensed_by, f.filled_at, f.reversed_at, fi.prescription_item_id, pi.product_id, fi.quantity, fi.refill_number
+		FROM prescription_fills f
+		JOIN prescription_fill_items fi ON fi.fill_id = f.id
+		JOIN prescription_items pi ON pi.id = fi.prescription_item_id
+		WHERE f.prescription_id = $1
+		ORDER BY f.filled_at, f.id
+	`, id)
+	if err != nil {
+		return PrescriptionResponse{Message: "Failed to retrieve prescription fills"}, errors.New("failed to retrieve prescription fills")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fill PrescriptionFillDetail
+		var item PrescriptionFillItem
+		err = rows.Scan(&fill.ID, &fill.SaleID, &fill.DispensedBy, &fill.FilledAt, &fill.ReversedAt, &item.PrescriptionItemID, &item.ProductID, &item.Quantity, &item.RefillNumber)
+		if err != nil {
+			return PrescriptionResponse{Message: "Failed to scan prescription fill"}, errors.New("failed to scan prescription fill")
+		}
+
+		// Rows are ordered by fill, so group consecutive lines together
+		if len(detail.Fills) == 0 || detail.Fills[len(detail.Fills)-1].ID != fill.ID {
+			detail.Fills = append(detail.Fills, fill)
+		}
+		last := &detail.Fills[len(detail.Fills)-1]
+		last.Items = append(last.Items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return PrescriptionResponse{Message: "Error iterating prescription fills"}, errors.New("error iterating prescription fills: " + err.Error())
+	}
+
+	return PrescriptionResponse{
+		Message: "Prescription retrieved successfully",
+		Data:    &detail,
+	}, nil
+}
+
+// CancelPrescription cancels a prescription so it can no longer be filled
+//
+//encore:api public method=PUT path=/api/prescriptions/:id/cancel
+func CancelPrescription(ctx context.Context, id uuid.UUID) (Response, error) {
+	var status string
+	err := db.QueryRow(ctx, "SELECT status FROM prescriptions WHERE id = $1", id).Scan(&status)
+	if err != nil {
+		return Response{Message: "Prescription not found"}, errors.New("prescription not found")
+	}
+	if status == "cancelled" || status == "filled" {
+		return Response{Message: "Prescription cannot be cancelled"}, errors.New("prescription cannot be cancelled, current status: " + status)
+	}
+
+	_, err = db.Exec(ctx, `
+		UPDATE prescriptions
+		SET status = 'cancelled', updated_at = NOW()
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return Response{Message: "Failed to cancel prescription"}, err
+	}
+
+	return Response{Message: "Prescription cancelled successfully"}, nil
+}
+
+// FillPrescription dispenses products against a prescription. A single fill
+// can cover at most the prescribed quantity of the current fill; once it is
+// fully dispensed, the next fill counts as a refill.
+//
+//encore:api private method=POST path=/internal/prescriptions/:id/fill
+func FillPrescription(ctx context.Context, id uuid.UUID, req *FillPrescriptionRequest) (FillPrescriptionResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return FillPrescriptionResponse{Message: "Validation failed"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return FillPrescriptionResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the prescription so concurrent fills are applied one at a time
+	var status string
+	var refillsAllowed int
+	var isExpired bool
+	err = tx.QueryRow(ctx, `
+		SELECT status, refills_allowed, COALESCE(valid_until < CURRENT_DATE, FALSE)
+		FROM prescriptions
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&status, &refillsAllowed, &isExpired)
+	if err != nil {
+		return FillPrescriptionResponse{Message: "Prescription not found"}, errors.New("prescription not found")
+	}
+	if status == "cancelled" || status == "filled" {
+		return FillPrescriptionResponse{Message: "Prescription cannot be filled"}, errors.New("prescription cannot be filled, current status: " + status)
+	}
+	if isExpired {
+		return FillPrescriptionResponse{Message: "Prescription has expired"}, errors.New("prescription has expired")
+	}
+
+	items, err := loadPrescriptionItems(ctx, tx, id)
+	if err != nil {
+		return FillPrescriptionResponse{Message: "Failed to retrieve prescription items"}, err
+	}
+
+	var fillID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO prescription_fills (prescription_id, sale_id, dispensed_by)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, id, req.SaleID, req.DispensedBy).Scan(&fillID)
+	if err != nil {
+		return FillPrescriptionResponse{Message: "Failed to create prescription fill"}, err
+	}
+
+	for _, fillItem := range req.Items {
+		item, ok := items[fillItem.ProductID]
+		if !ok {
+			return FillPrescriptionResponse{Message: "Product is not on the prescription: " + fillItem.ProductID.String()}, errors.New("product is not on the prescription")
+		}
+
+		totalAllowed := item.Quantity * (refillsAllowed + 1)
+		if item.DispensedQuantity >= totalAllowed {
+			return FillPrescriptionResponse{Message: "Prescription item fully dispensed"}, fmt.Errorf("product %s has been fully dispensed", fillItem.ProductID)
+		}
+		refillNumber := item.DispensedQuantity / item.Quantity
+		remainingInFill := item.Quantity - item.DispensedQuantity%item.Quantity
+		if fillItem.Quantity > remainingInFill {
+			return FillPrescriptionResponse{Message: "Quantity exceeds prescription"}, fmt.Errorf("quantity %d exceeds remaining %d for product %s", fillItem.Quantity, remainingInFill, fillItem.ProductID)
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE prescription_items
+			SET dispensed_quantity = dispensed_quantity + $1
+			WHERE id = $2
+		`, fillItem.Quantity, item.ID)
+		if err != nil {
+			return FillPrescriptionResponse{Message: "Failed to update prescription item"}, err
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO prescription_fill_items (fill_id, prescription_item_id, quantity, refill_number)
+			VALUES ($1, $2, $3, $4)
+		`, fillID, item.ID, fillItem.Quantity, refillNumber)
+		if err != nil {
+			return FillPrescriptionResponse{Message: "Failed to create prescription fill item"}, err
+		}
+	}
+
+	newStatus, err := updatePrescriptionProgress(ctx, tx, id, refillsAllowed)
+	if err != nil {
+		return FillPrescriptionResponse{Message: "Failed to update prescription"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return FillPrescriptionResponse{Message: "Failed to commit prescription fill"}, err
+	}
+
+	return FillPrescriptionResponse{
+		Message: "Prescription filled successfully",
+		FillID:  &fillID,
+		Status:  newStatus,
+	}, nil
+}
+
+// ReverseFill undoes a prescription fill whose sale could not be completed
+//
+//encore:api private method=POST path=/internal/prescriptions/fills/:id/reverse
+func ReverseFill(ctx context.Context, id uuid.UUID) (Response, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return Response{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	var prescriptionID uuid.UUID
+	var reversedAt *time.Time
+	err = tx.QueryRow(ctx, "SELECT prescription_id, reversed_at FROM prescription_fills WHERE id = $1 FOR UPDATE", id).Scan(&prescriptionID, &reversedAt)
+	if err != nil {
+		return Response{Message: "Prescription fill not found"}, errors.New("prescription fill not found")
+	}
+	if reversedAt != nil {
+		return Response{Message: "Prescription fill already reversed"}, errors.New("prescription fill already reversed")
+	}
+
+	var status string
+	var refillsAllowed int
+	err = tx.QueryRow(ctx, "SELECT status, refills_allowed FROM prescriptions WHERE id = $1 FOR UPDATE", prescriptionID).Scan(&status, &refillsAllowed)
+	if err != nil {
+		return Response{Message: "Prescription not found"}, errors.New("prescription not found")
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE prescription_items pi
+		SET dispensed_quantity = pi.dispensed_quantity - fi.quantity
+		FROM prescription_fill_items fi
+		WHERE fi.fill_id = $1 AND fi.prescription_item_id = pi.id
+	`, id)
+	if err != nil {
+		return Response{Message: "Failed to update prescription items"}, err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE prescription_fills SET reversed_at = NOW() WHERE id = $1", id)
+	if err != nil {
+		return Response{Message: "Failed to reverse prescription fill"}, err
+	}
+
+	// A cancelled prescription stays cancelled
+	if status != "cancelled" {
+		if _, err = updatePrescriptionProgress(ctx, tx, prescriptionID, refillsAllowed); err != nil {
+			return Response{Message: "Failed to update prescription"}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Response{Message: "Failed to commit prescription fill reversal"}, err
+	}
+
+	return Response{Message: "Prescription fill reversed successfully"}, nil
+}
+
+// loadPrescriptionItems loads the items of a prescription keyed by product ID
+func loadPrescriptionItems(ctx context.Context, tx *sqldb.Tx, prescriptionID uuid.UUID) (map[uuid.UUID]PrescriptionItem, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id, product_id, dosage, quantity, dispensed_quantity
+		FROM prescription_items
+		WHERE prescription_id = $1
+	`, prescriptionID)
+	if err != nil {
+		return nil, errors.New("failed to retrieve prescription items: " + err.Error())
+	}
+	defer rows.Close()
+
+	items := make(map[uuid.UUID]PrescriptionItem)
+	for rows.Next() {
+		item := PrescriptionItem{PrescriptionID: prescriptionID}
+		if err = rows.Scan(&item.ID, &item.ProductID, &item.Dosage, &item.Quantity, &item.DispensedQuantity); err != nil {
+			return nil, errors.New("failed to scan prescription item: " + err.Error())
+		}
+		items[item.ProductID] = item
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("error iterating prescription items: " + err.Error())
+	}
+	return items, nil
+}
+
+// updatePrescriptionProgress recomputes the refills used and the status of a
+// prescription from the dispensed quantities of its items
+func updatePrescriptionProgress(ctx context.Context, tx *sqldb.Tx, prescriptionID uuid.UUID, refillsAllowed int) (string, error) {
+	items, err := loadPrescriptionItems(ctx, tx, prescriptionID)
+	if err != nil {
+		return "", err
+	}
+
+	refillsUsed := 0
+	anyDispensed := false
+	allDispensed := true
+	for _, item := range items {
+		if item.DispensedQuantity > 0 {
+			anyDispensed = true
+			// Every fill started beyond the original one is a refill
+			fillsStarted := (item.DispensedQuantity + item.Quantity - 1) / item.Quantity
+			if fillsStarted-1 > refillsUsed {
+				refillsUsed = fillsStarted - 1
+			}
+		}
+		if item.DispensedQuantity < item.Quantity*(refillsAllowed+1) {
+			allDispensed = false
+		}
+	}
+
+	status := "active"
+	if allDispensed {
+		status = "filled"
+	} else if anyDispensed {
+		status = "partially_filled"
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE prescriptions
+		SET status = $1, refills_used = $2, updated_at = NOW()
+		WHERE id = $3
+	`, status, refillsUsed, prescriptionID)
+	if err != nil {
+		return "", errors.New("failed to update prescription: " + err.Error())
+	}
+	return status, nil
+}
+
+// CountProductReferences counts the prescription items that name a product,
+// so the product is not deleted while prescriptions still refer to it
+//
+//encore:api private method=GET path=/internal/prescriptions/products/:id/references
+func CountProductReferences(ctx context.Context, id uuid.UUID) (ProductReferencesResponse, error) {
+	var references ProductReferencesResponse
+	err := db.QueryRow(ctx, "SELECT COUNT(*) FROM prescription_items WHERE product_id = $1", id).Scan(&references.PrescriptionItems)
+	if err != nil {
+		return ProductReferencesResponse{Message: "Failed to count prescription items"}, err
+	}
+	references.Message = "Product references counted successfully"
+	return references, nil
+}
@@ -0,0 +1,85 @@
+// Package pricing calculates the totals of a purchase invoice and the unit
+// cost of the goods received against it.
+//
+// Line discounts are applied first, then the invoice discount, then tax.
+// Purchases priced with tax included carry the tax inside the discounted
+// amount; otherwise tax is added on top of it.
+package pricing
+
+import (
+	"errors"
+
+	"encore.app/money"
+)
+
+// Line is a priced purchase line. Discount and Total are filled in by
+// Calculate.
+type Line struct {
+	UnitPrice           money.Amount
+	Quantity            int
+	DiscountBasisPoints int64
+	Discount            money.Amount
+	Total               money.Amount
+}
+
+// Totals are the amounts stored on a purchase
+type Totals struct {
+	Subtotal money.Amount
+	Discount money.Amount
+	TaxBase  money.Amount
+	Tax      money.Amount
+	Total    money.Amount
+}
+
+// Calculate applies line discounts, then the invoice discount (a percentage
+// or a fixed amount) to the sum of the discounted lines, then tax. With
+// tax-inclusive pricing the discounted amount is the grand total and the tax
+// is the part of it above the tax base; otherwise tax is added on top of the
+// discounted amount.
+func Calculate(lines []Line, discountBasisPoints int64, discountAmount money.Amount, taxBasisPoints int64, pricesIncludeTax bool) (Totals, error) {
+	var totals Totals
+	var net money.Amount
+	for i := range lines {
+		line := &lines[i]
+		gross := line.UnitPrice.Mul(line.Quantity)
+		line.Discount = gross.Percent(line.DiscountBasisPoints)
+		line.Total = gross - line.Discount
+
+		totals.Subtotal += gross
+		totals.Discount += line.Discount
+		net += line.Total
+	}
+
+	invoiceDiscount := discountAmount
+	if discountBasisPoints > 0 {
+		invoiceDiscount = net.Percent(discountBasisPoints)
+	}
+	if invoiceDiscount > net {
+		return Totals{}, errors.New("discount_amount must not exceed the total of the lines")
+	}
+	totals.Discount += invoiceDiscount
+	discounted := net - invoiceDiscount
+
+	if pricesIncludeTax {
+		totals.Total = discounted
+		totals.TaxBase = discounted.ExcludePercent(taxBasisPoints)
+		totals.Tax = totals.Total - totals.TaxBase
+	} else {
+		totals.TaxBase = discounted
+		totals.Tax = discounted.Percent(taxBasisPoints)
+		totals.Total = totals.TaxBase + totals.Tax
+	}
+	return totals, nil
+}
+
+// UnitCost returns the cost of one unit of a purchase line after line and
+// invoice discounts, without tax. The invoice discount is shared over the
+// lines in proportion to their totals after line discounts: lineNet of
+// purchaseNet is left as purchaseDiscounted.
+func UnitCost(lineNet money.Amount, quantity int, purchaseNet, purchaseDiscounted money.Amount, taxBasisPoints int64, pricesIncludeTax bool) money.Amount {
+	cost := lineNet.MulRatio(purchaseDiscounted, purchaseNet)
+	if pricesIncludeTax {
+		cost = cost.ExcludePercent(taxBasisPoints)
+	}
+	return cost.Div(quantity)
+}
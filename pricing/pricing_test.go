@@ -0,0 +1,201 @@
+package pricing
+
+import (
+	"testing"
+
+	"encore.app/money"
+)
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name                string
+		lines               []Line
+		discountBasisPoints int64
+		discountAmount      money.Amount
+		taxBasisPoints      int64
+		pricesIncludeTax    bool
+		want                Totals
+		wantLines           []Line
+		wantErr             bool
+	}{
+		{
+			name:           "tax added on top",
+			lines:          []Line{{UnitPrice: 10000, Quantity: 3}},
+			taxBasisPoints: 1100,
+			want:           Totals{Subtotal: 30000, TaxBase: 30000, Tax: 3300, Total: 33300},
+			wantLines:      []Line{{UnitPrice: 10000, Quantity: 3, Total: 30000}},
+		},
+		{
+			name: "line and invoice percentage discounts",
+			lines: []Line{
+				{UnitPrice: 15000, Quantity: 2, DiscountBasisPoints: 1000},
+				{UnitPrice: 999, Quantity: 5},
+			},
+			discountBasisPoints: 500,
+			taxBasisPoints:      1100,
+			// 31995 after line discounts, 5% of it is 1599.75 and 11% of 30395 is 3343.45
+			want: Totals{Subtotal: 34995, Discount: 4600, TaxBase: 30395, Tax: 3343, Total: 33738},
+			wantLines: []Line{
+				{UnitPrice: 15000, Quantity: 2, DiscountBasisPoints: 1000, Discount: 3000, Total: 27000},
+				{UnitPrice: 999, Quantity: 5, Total: 4995},
+			},
+		},
+		{
+			name:                "percentage discount wins over the amount",
+			lines:               []Line{{UnitPrice: 10000, Quantity: 1}},
+			discountBasisPoints: 1000,
+			discountAmount:      5000,
+			want:                Totals{Subtotal: 10000, Discount: 1000, TaxBase: 9000, Total: 9000},
+			wantLines:           []Line{{UnitPrice: 10000, Quantity: 1, Total: 10000}},
+		},
+		{
+			name:             "fixed discount with tax included",
+			lines:            []Line{{UnitPrice: 2500, Quantity: 4}},
+			discountAmount:   1000,
+			taxBasisPoints:   1100,
+			pricesIncludeTax: true,
+			// 9000 / 1.11 is 8108.11
+			want:      Totals{Subtotal: 10000, Discount: 1000, TaxBase: 8108, Tax: 892, Total: 9000},
+			wantLines: []Line{{UnitPrice: 2500, Quantity: 4, Total: 10000}},
+		},
+		{
+			name:      "line discount rounds half away from zero",
+			lines:     []Line{{UnitPrice: 5, Quantity: 1, DiscountBasisPoints: 1000}},
+			want:      Totals{Subtotal: 5, Discount: 1, TaxBase: 4, Total: 4},
+			wantLines: []Line{{UnitPrice: 5, Quantity: 1, DiscountBasisPoints: 1000, Discount: 1, Total: 4}},
+		},
+		{
+			name:           "discount equal to the lines",
+			lines:          []Line{{UnitPrice: 10000, Quantity: 1}},
+			discountAmount: 10000,
+			taxBasisPoints: 1100,
+			want:           Totals{Subtotal: 10000, Discount: 10000},
+			wantLines:      []Line{{UnitPrice: 10000, Quantity: 1, Total: 10000}},
+		},
+		{
+			name:           "discount above the lines",
+			lines:          []Line{{UnitPrice: 10000, Quantity: 1, DiscountBasisPoints: 1000}},
+			discountAmount: 9001,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := Calculate(tt.lines, tt.discountBasisPoints, tt.discountAmount, tt.taxBasisPoints, tt.pricesIncludeTax)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: Calculate = %+v, want error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Calculate error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Calculate = %+v, want %+v", tt.name, got, tt.want)
+		}
+		for i, line := range tt.lines {
+			if line != tt.wantLines[i] {
+				t.Errorf("%s: line %d = %+v, want %+v", tt.name, i, line, tt.wantLines[i])
+			}
+		}
+	}
+}
+
+func TestUnitCost(t *testing.T) {
+	tests := []struct {
+		name               string
+		lineNet            money.Amount
+		quantity           int
+		purchaseNet        money.Amount
+		purchaseDiscounted money.Amount
+		taxBasisPoints     int64
+		pricesIncludeTax   bool
+		want               money.Amount
+	}{
+		{
+			name:               "no invoice discount",
+			lineNet:            27000,
+			quantity:           2,
+			purchaseNet:        31995,
+			purchaseDiscounted: 31995,
+			taxBasisPoints:     1100,
+			want:               13500,
+		},
+		{
+			name:               "invoice discount shared by line total",
+			lineNet:            27000,
+			quantity:           2,
+			purchaseNet:        31995,
+			purchaseDiscounted: 30395,
+			taxBasisPoints:     1100,
+			// 27000 * 30395 / 31995 is 25649.79
+			want: 12825,
+		},
+		{
+			name:               "smaller line of the same invoice",
+			lineNet:            4995,
+			quantity:           5,
+			purchaseNet:        31995,
+			purchaseDiscounted: 30395,
+			want:               949,
+		},
+		{
+			name:               "tax included",
+			lineNet:            6000,
+			quantity:           4,
+			purchaseNet:        10000,
+			purchaseDiscounted: 9000,
+			taxBasisPoints:     1100,
+			pricesIncludeTax:   true,
+			// 5400 / 1.11 is 4864.86, a quarter of 4865 is 1216.25
+			want: 1216,
+		},
+		{
+			name:               "fully discounted",
+			lineNet:            10000,
+			quantity:           1,
+			purchaseNet:        10000,
+			purchaseDiscounted: 0,
+			want:               0,
+		},
+	}
+	for _, tt := range tests {
+		got := UnitCost(tt.lineNet, tt.quantity, tt.purchaseNet, tt.purchaseDiscounted, tt.taxBasisPoints, tt.pricesIncludeTax)
+		if got != tt.want {
+			t.Errorf("%s: UnitCost = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnitCostAddsUpToTaxBase(t *testing.T) {
+	lines := []Line{
+		{UnitPrice: 12345, Quantity: 7, DiscountBasisPoints: 250},
+		{UnitPrice: 999, Quantity: 13},
+		{UnitPrice: 50000, Quantity: 1, DiscountBasisPoints: 1500},
+	}
+	for _, includeTax := range []bool{false, true} {
+		totals, err := Calculate(lines, 0, 3333, 1100, includeTax)
+		if err != nil {
+			t.Fatalf("Calculate error: %v", err)
+		}
+
+		var net, cost money.Amount
+		for _, line := range lines {
+			net += line.Total
+		}
+		for _, line := range lines {
+			unit := UnitCost(line.Total, line.Quantity, net, totals.TaxBase, 0, false)
+			if includeTax {
+				unit = UnitCost(line.Total, line.Quantity, net, totals.Total, 1100, true)
+			}
+			cost += unit.Mul(line.Quantity)
+		}
+
+		// Every unit is rounded to the sen, so the stock value may be off by
+		// at most half a sen per unit plus a sen per line
+		if diff := cost - totals.TaxBase; diff < -14 || diff > 14 {
+			t.Errorf("includeTax=%v: received stock costs %d, tax base is %d", includeTax, cost, totals.TaxBase)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package procurement
+
+import (
+	"context"
+	"errors"
+
+	"encore.app/money"
+	"encore.app/product"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// Approval decisions
+const (
+	ApprovalDecisionApproved = "approved"
+	ApprovalDecisionRejected = "rejected"
+)
+
+// ApprovePurchase approves a purchase awaiting approval so its goods can be received
+//
+//encore:api public method=POST path=/api/purchases/:id/approve
+func ApprovePurchase(ctx context.Context, id uuid.UUID, req *PurchaseDecisionRequest) (PurchaseDecisionResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return PurchaseDecisionResponse{Message: "Validation failed"}, err
+	}
+
+	return decidePurchase(ctx, id, ApprovalDecisionApproved, req)
+}
+
+// RejectPurchase rejects a purchase awaiting approval. A rejected purchase can
+// be moved back to draft, revised and submitted again, or cancelled.
+//
+//encore:api public method=POST path=/api/purchases/:id/reject
+func RejectPurchase(ctx context.Context, id uuid.UUID, req *PurchaseDecisionRequest) (PurchaseDecisionResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return PurchaseDecisionResponse{Message: "Validation failed"}, err
+	}
+	if req.Comment == "" {
+		return PurchaseDecisionResponse{Message: "Validation failed"}, errors.New("comment is required to reject a purchase")
+	}
+
+	return decidePurchase(ctx, id, ApprovalDecisionRejected, req)
+}
+
+// decidePurchase records an approval decision on a submitted purchase and
+// moves it to the matching status
+func decidePurchase(ctx context.Context, id uuid.UUID, decision string, req *PurchaseDecisionRequest) (PurchaseDecisionResponse, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return PurchaseDecisionResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the purchase so two managers cannot decide at the same time
+	var status string
+	var createdBy uuid.UUID
+	err = tx.QueryRow(ctx, "SELECT status, created_by FROM purchases WHERE id = $1 FOR UPDATE", id).Scan(&status, &createdBy)
+	if err != nil {
+		return PurchaseDecisionResponse{Message: "Purchase not found"}, errors.New("purchase not found")
+	}
+	if status != PurchaseStatusSubmitted {
+		return PurchaseDecisionResponse{Message: "Purchase is not awaiting approval"}, errors.New("purchase is not awaiting approval, current status: " + status)
+	}
+	if req.ApproverID == createdBy {
+		return PurchaseDecisionResponse{Message: "Purchase cannot be decided by its creator"}, errors.New("approver must not be the user who created the purchase")
+	}
+
+	approval := PurchaseApproval{
+		PurchaseID: id,
+		Decision:   decision,
+		Comment:    req.Comment,
+		DecidedBy:  req.ApproverID,
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO purchase_approvals (purchase_id, decision, comment, decided_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, decided_at
+	`, id, decision, req.Comment, req.ApproverID).Scan(&approval.ID, &approval.DecidedAt)
+	if err != nil {
+		return PurchaseDecisionResponse{Message: "Failed to record approval decision"}, err
+	}
+
+	reason := "Approved by manager"
+	if decision == ApprovalDecisionRejected {
+		reason = "Rejected by manager"
+	}
+	if req.Comment != "" {
+		reason += ": " + req.Comment
+	}
+	if err = changePurchaseStatus(ctx, tx, id, status, decision, reason, req.ApproverID); err != nil {
+		return PurchaseDecisionResponse{Message: "Failed to update purchase status"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return PurchaseDecisionResponse{Message: "Failed to commit approval decision"}, err
+	}
+
+	return PurchaseDecisionResponse{
+		Message: "Purchase " + decision + " successfully",
+		Data:    &approval,
+	}, nil
+}
+
+// GetApprovalThresholds retrieves the configured approval thresholds
+//
+//encore:api public method=GET path=/api/approval-thresholds
+func GetApprovalThresholds(ctx context.Context) (ListApprovalThresholdsResponse, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, supplier_id, category_id, amount, created_at, updated_at
+		FROM approval_thresholds
+		ORDER BY supplier_id NULLS FIRST, category_id NULLS FIRST
+	`)
+	if err != nil {
+		return ListApprovalThresholdsResponse{
+			Message: "Failed to retrieve approval thresholds",
+			Data:    []ApprovalThreshold{},
+		}, errors.New("failed to retrieve approval thresholds")
+	}
+	defer rows.Close()
+
+	var thresholds []ApprovalThreshold
+	for rows.Next() {
+		var threshold ApprovalThreshold
+		err = rows.Scan(
+			&threshold.ID,
+			&threshold.SupplierID,
+			&threshold.CategoryID,
+			&threshold.Amount,
+			&threshold.CreatedAt,
+			&threshold.UpdatedAt,
+		)
+		if err != nil {
+			return ListApprovalThresholdsResponse{Message: "Failed to scan approval threshold"}, errors.New("failed to scan approval threshold")
+		}
+		thresholds = append(thresholds, threshold)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListApprovalThresholdsResponse{Message: "Error iterating approval thresholds"}, errors.New("error iterating approval thresholds: " + err.Error())
+	}
+
+	return ListApprovalThresholdsResponse{
+		Message: "Approval thresholds retrieved successfully",
+		Data:    thresholds,
+	}, nil
+}
+
+// SetApprovalThreshold sets the approval threshold for a supplier, a product
+// category, or the default threshold when neither is given. Purchases whose
+// total exceeds the threshold need a manager's approval.
+//
+//encore:api public method=PUT path=/api/approval-thresholds
+func SetApprovalThreshold(ctx context.Context, req *SetApprovalThresholdRequest) (ApprovalThresholdResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return ApprovalThresholdResponse{Message: "Validation failed"}, err
+	}
+
+	// Check if supplier exists
+	if req.SupplierID != nil {
+		var supplierExists bool
+		err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM suppliers WHERE id = $1)", *req.SupplierID).Scan(&supplierExists)
+		if err != nil {
+			return ApprovalThresholdResponse{Message: "Failed to check supplier"}, err
+		}
+		if !supplierExists {
+			return ApprovalThresholdResponse{Message: "Supplier not found"}, errors.New("supplier not found")
+		}
+	}
+
+	// Check if category exists in product service
+	if req.CategoryID != nil {
+		if _, err := product.GetCategory(ctx, *req.CategoryID); err != nil {
+			return ApprovalThresholdResponse{Message: "Category not found"}, errors.New("category not found")
+		}
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return ApprovalThresholdResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	var threshold ApprovalThreshold
+	scan := []interface{}{
+		&threshold.ID,
+		&threshold.SupplierID,
+		&threshold.CategoryID,
+		&threshold.Amount,
+		&threshold.CreatedAt,
+		&threshold.UpdatedAt,
+	}
+	err = tx.QueryRow(ctx, `
+		UPDATE approval_thresholds
+		SET amount = $3, updated_at = NOW()
+		WHERE supplier_id IS NOT DISTINCT FROM $1 AND category_id IS NOT DISTINCT FROM $2
+		RETURNING id, supplier_id, category_id, amount, created_at, updated_at
+	`, req.SupplierID, req.CategoryID, req.Amount).Scan(scan...)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO approval_thresholds (supplier_id, category_id, amount)
+			VALUES ($1, $2, $3)
+			RETURNING id, supplier_id, category_id, amount, created_at, updated_at
+		`, req.SupplierID, req.CategoryID, req.Amount).Scan(scan...)
+	}
+	if err != nil {
+		return ApprovalThresholdResponse{Message: "Failed to set approval threshold"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ApprovalThresholdResponse{Message: "Failed to commit approval threshold"}, err
+	}
+
+	return ApprovalThresholdResponse{
+		Message: "Approval threshold set successfully",
+		Data:    &threshold,
+	}, nil
+}
+
+// DeleteApprovalThreshold removes an approval threshold
+//
+//encore:api public method=DELETE path=/api/approval-thresholds/:id
+func DeleteApprovalThreshold(ctx context.Context, id uuid.UUID) (Response, error) {
+	result, err := db.Exec(ctx, "DELETE FROM approval_thresholds WHERE id = $1", id)
+	if err != nil {
+		return Response{Message: "Failed to delete approval threshold"}, err
+	}
+	if result.RowsAffected() == 0 {
+		return Response{Message: "Approval threshold not found"}, errors.New("approval threshold not found")
+	}
+
+	return Response{Message: "Approval threshold deleted successfully"}, nil
+}
+
+// approvalThreshold returns the threshold that applies to a purchase: the
+// supplier's threshold, otherwise the lowest threshold of the categories of
+// the ordered products, otherwise the default. It returns nil when no
+// threshold is configured.
+func approvalThreshold(ctx context.Context, tx *sqldb.Tx, supplierID uuid.UUID, categoryIDs []uuid.UUID) (*money.Amount, error) {
+	var threshold money.Amount
+	err := tx.QueryRow(ctx, `
+		SELECT amount FROM (
+			SELECT amount, 1 AS priority FROM approval_thresholds WHERE supplier_id = $1
+			UNION ALL
+			SELECT MIN(amount), 2 FROM approval_thresholds WHERE category_id = ANY($2::uuid[]) HAVING COUNT(*) > 0
+			UNION ALL
+			SELECT amount, 3 FROM approval_thresholds WHERE supplier_id IS NULL AND category_id IS NULL
+		) t
+		ORDER BY priority
+		LIMIT 1
+	`, supplierID, categoryIDs).Scan(&threshold)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to look up approval threshold: " + err.Error())
+	}
+	return &threshold, nil
+}
+
+// routeForApproval decides what happens to a purchase that was just
+// submitted: purchases within the approval threshold are approved right
+// away, others stay submitted until a manager decides. It returns the
+// resulting status.
+func routeForApproval(ctx context.Context, tx *sqldb.Tx, purchaseID, supplierID uuid.UUID, categoryIDs []uuid.UUID, total money.Amount, submittedBy uuid.UUID) (string, error) {
+	threshold, err := approvalThreshold(ctx, tx, supplierID, categoryIDs)
+	if err != nil {
+		return "", err
+	}
+
+	if threshold != nil && total > *threshold {
+		_, err = tx.Exec(ctx, "UPDATE purchases SET approval_threshold = $1 WHERE id = $2", *threshold, purchaseID)
+		if err != nil {
+			return "", errors.New("failed to record approval threshold: " + err.Error())
+		}
+		return PurchaseStatusSubmitted, nil
+	}
+
+	reason := "Approved automatically, no approval threshold applies"
+	if threshold != nil {
+		reason = "Approved automatically, total within approval threshold of " + threshold.String()
+	}
+	err = changePurchaseStatus(ctx, tx, purchaseID, PurchaseStatusSubmitted, PurchaseStatusApproved, reason, submittedBy)
+	if err != nil {
+		return "", err
+	}
+	return PurchaseStatusApproved, nil
+}
+
+// purchaseCategoryIDs returns the categories of the products on a purchase
+func purchaseCategoryIDs(ctx context.Context, purchaseID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := db.Query(ctx, "SELECT DISTINCT product_id FROM purchase_items WHERE purchase_id = $1", purchaseID)
+	if err != nil {
+		return nil, errors.New("failed to retrieve purchase items: " + err.Error())
+	}
+	defer rows.Close()
+
+	var productIDs []uuid.UUID
+	for rows.Next() {
+		var productID uuid.UUID
+		if err = rows.Scan(&productID); err != nil {
+			return nil, errors.New("failed to scan purchase item: " + err.Error())
+		}
+		productIDs = append(productIDs, productID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("error iterating purchase items: " + err.Error())
+	}
+
+	var categoryIDs []uuid.UUID
+	for _, productID := range productIDs {
+		productData, err := product.GetProduct(ctx, productID)
+		if err != nil {
+			return nil, errors.New("product not found: " + productID.String())
+		}
+		categoryIDs = append(categoryIDs, productData.CategoryID)
+	}
+	return categoryIDs, nil
+}
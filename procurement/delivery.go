@@ -0,0 +1,113 @@
+package procurement
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"encore.dev/types/uuid"
+)
+
+// GetOverdueDeliveries lists approved purchases that are not fully received
+// after their expected delivery date, grouped by supplier. Suppliers with the
+// longest overdue delivery come first.
+//
+//encore:api public method=GET path=/api/deliveries/overdue
+func GetOverdueDeliveries(ctx context.Context, params *OverdueDeliveriesParams) (OverdueDeliveriesResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return OverdueDeliveriesResponse{Message: "Validation failed"}, err
+	}
+	asOf, _ := parseOptionalDate(params.AsOf)
+	if asOf == nil {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		asOf = &today
+	}
+
+	var supplierID *uuid.UUID
+	if params.SupplierID != uuid.Nil {
+		supplierID = &params.SupplierID
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT
+			s.id,
+			s.name,
+			COALESCE(s.email, ''),
+			COALESCE(s.phone, ''),
+			p.id,
+			p.purchase_number,
+			p.purchase_date,
+			p.expected_delivery,
+			p.status,
+			p.total_amount,
+			COALESCE(SUM(pi.quantity), 0) as ordered_quantity,
+			COALESCE(SUM(pi.received_quantity), 0) as received_quantity,
+			($1::date - p.expected_delivery) as days_overdue,
+			MIN(p.expected_delivery) OVER (PARTITION BY s.id) as oldest_expected_delivery
+		FROM purchases p
+		JOIN suppliers s ON p.supplier_id = s.id
+		LEFT JOIN purchase_items pi ON p.id = pi.purchase_id
+		WHERE p.status IN ($2, $3)
+			AND p.expected_delivery < $1::date
+			AND ($4::uuid IS NULL OR p.supplier_id = $4)
+		GROUP BY s.id, s.name, s.email, s.phone, p.id, p.purchase_number, p.purchase_date, p.expected_delivery, p.status, p.total_amount
+		ORDER BY oldest_expected_delivery, s.name, s.id, p.expected_delivery, p.purchase_number
+	`, *asOf, PurchaseStatusApproved, PurchaseStatusPartiallyReceived, supplierID)
+	if err != nil {
+		return OverdueDeliveriesResponse{
+			Message: "Failed to retrieve overdue deliveries",
+			Data:    []OverdueSupplier{},
+		}, errors.New("failed to retrieve overdue deliveries")
+	}
+	defer rows.Close()
+
+	var suppliers []OverdueSupplier
+	for rows.Next() {
+		var supplier OverdueSupplier
+		var purchase OverduePurchase
+		var oldestExpectedDelivery time.Time
+		err = rows.Scan(
+			&supplier.SupplierID,
+			&supplier.SupplierName,
+			&supplier.Email,
+			&supplier.Phone,
+			&purchase.ID,
+			&purchase.PurchaseNumber,
+			&purchase.OrderDate,
+			&purchase.ExpectedDelivery,
+			&purchase.Status,
+			&purchase.Total,
+			&purchase.OrderedQuantity,
+			&purchase.ReceivedQuantity,
+			&purchase.DaysOverdue,
+			&oldestExpectedDelivery,
+		)
+		if err != nil {
+			return OverdueDeliveriesResponse{Message: "Failed to scan overdue delivery"}, errors.New("failed to scan overdue delivery")
+		}
+		purchase.OutstandingQuantity = purchase.OrderedQuantity - purchase.ReceivedQuantity
+
+		// Rows are ordered by supplier, so a new supplier starts a new group
+		if len(suppliers) == 0 || suppliers[len(suppliers)-1].SupplierID != supplier.SupplierID {
+			suppliers = append(suppliers, supplier)
+		}
+		group := &suppliers[len(suppliers)-1]
+		group.OverduePurchases++
+		group.OutstandingQuantity += purchase.OutstandingQuantity
+		if purchase.DaysOverdue > group.MaxDaysOverdue {
+			group.MaxDaysOverdue = purchase.DaysOverdue
+		}
+		group.Purchases = append(group.Purchases, purchase)
+	}
+
+	if err = rows.Err(); err != nil {
+		return OverdueDeliveriesResponse{Message: "Error iterating overdue deliveries"}, errors.New("error iterating overdue deliveries: " + err.Error())
+	}
+
+	return OverdueDeliveriesResponse{
+		Message: "Overdue deliveries retrieved successfully",
+		AsOf:    *asOf,
+		Data:    suppliers,
+	}, nil
+}
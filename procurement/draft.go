@@ -0,0 +1,168 @@
+package procurement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/product"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// CreateDraftPurchases creates draft purchases for a set of low-stock products,
+// one per supplier. Quantities default to the reorder suggestion and lines are
+// grouped by the product's preferred supplier, falling back to the supplier
+// it was last purchased from. Lines are priced from the supplier's price list,
+// or at the last purchase price when the supplier does not list the product.
+// Purchase numbers are generated automatically. Drafts are created one by
+// one; a request retried with the same Idempotency-Key header gets back the
+// drafts created the first time and only creates the missing ones.
+//
+//encore:api public method=POST path=/api/purchases/drafts
+func CreateDraftPurchases(ctx context.Context, req *CreateDraftPurchasesRequest) (CreateDraftPurchasesResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return CreateDraftPurchasesResponse{Message: "Validation failed"}, err
+	}
+
+	orderDate := req.OrderDate
+	if orderDate.IsZero() {
+		orderDate = time.Now()
+	}
+
+	// Get reorder suggestions from product service
+	suggestions, err := product.GetReorderSuggestions(ctx, &product.ReorderSuggestionsParams{})
+	if err != nil {
+		return CreateDraftPurchasesResponse{Message: "Failed to retrieve reorder suggestions"}, err
+	}
+	suggestionByProduct := make(map[uuid.UUID]product.ReorderSuggestion)
+	for _, suggestion := range suggestions.Data {
+		suggestionByProduct[suggestion.ProductID] = suggestion
+	}
+
+	// Group lines by supplier, keeping the order suppliers first appear in
+	var drafts []DraftPurchase
+	draftIndex := make(map[uuid.UUID]int)
+	for _, item := range req.Items {
+		suggestion, suggested := suggestionByProduct[item.ProductID]
+
+		quantity := item.Quantity
+		if quantity == 0 {
+			if !suggested || suggestion.SuggestedQuantity <= 0 {
+				return CreateDraftPurchasesResponse{Message: "Validation failed"}, fmt.Errorf("product %s is not below its minimum stock, quantity is required", item.ProductID)
+			}
+			quantity = suggestion.SuggestedQuantity
+		}
+
+		supplierID := item.SupplierID
+		if supplierID == uuid.Nil && suggested && suggestion.PreferredSupplierID != nil {
+			supplierID = *suggestion.PreferredSupplierID
+		}
+		if supplierID == uuid.Nil {
+			supplierID, err = lastUsedSupplier(ctx, item.ProductID)
+			if err != nil {
+				return CreateDraftPurchasesResponse{Message: "No supplier known for product: " + item.ProductID.String()}, err
+			}
+		}
+
+		// Products the supplier does not list are priced at their last purchase price
+		unitPrice := item.UnitPrice
+		if unitPrice == nil && suggested && suggestion.LastPurchasePrice > 0 {
+			if _, err = supplierUnitCost(ctx, supplierID, item.ProductID, orderDate, quantity); errors.Is(err, errNoSupplierPrice) {
+				lastPurchasePrice := suggestion.LastPurchasePrice
+				unitPrice = &lastPurchasePrice
+			}
+		}
+
+		i, ok := draftIndex[supplierID]
+		if !ok {
+			i = len(drafts)
+			draftIndex[supplierID] = i
+			drafts = append(drafts, DraftPurchase{SupplierID: supplierID})
+		}
+		drafts[i].Items = append(drafts[i].Items, PurchaseItemRequest{
+			ProductID: item.ProductID,
+			Quantity:  quantity,
+			UnitPrice: unitPrice,
+		})
+	}
+
+	// Resolve supplier names and make sure every supplier exists and is active before creating anything
+	for i := range drafts {
+		var isActive bool
+		err = db.QueryRow(ctx, "SELECT name, COALESCE(is_active, false) FROM suppliers WHERE id = $1", drafts[i].SupplierID).Scan(&drafts[i].Supplier, &isActive)
+		if err != nil {
+			return CreateDraftPurchasesResponse{Message: "Supplier not found: " + drafts[i].SupplierID.String()}, errors.New("supplier not found")
+		}
+		if !isActive {
+			return CreateDraftPurchasesResponse{Message: "Supplier is inactive: " + drafts[i].Supplier}, errors.New("supplier is inactive")
+		}
+	}
+
+	// Create one draft purchase per supplier
+	for i := range drafts {
+		var idempotencyKey string
+		if req.IdempotencyKey != "" {
+			idempotencyKey = req.IdempotencyKey + ":" + drafts[i].SupplierID.String()
+			err = db.QueryRow(ctx, "SELECT purchase_number FROM purchases WHERE idempotency_key = $1", idempotencyKey).Scan(&drafts[i].PurchaseNumber)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, sqldb.ErrNoRows) {
+				return CreateDraftPurchasesResponse{Message: "Failed to check idempotency key", Data: drafts[:i]}, err
+			}
+		}
+
+		purchaseNumber, err := nextPurchaseNumber(ctx, orderDate)
+		if err != nil {
+			return CreateDraftPurchasesResponse{Message: "Failed to generate purchase number", Data: drafts[:i]}, err
+		}
+
+		resp, err := createPurchase(ctx, &CreatePurchaseRequest{
+			SupplierID:     drafts[i].SupplierID,
+			OrderDate:      orderDate,
+			InvoiceNumber:  purchaseNumber,
+			Notes:          req.Notes,
+			Items:          drafts[i].Items,
+			CreatedBy:      req.CreatedBy,
+			IdempotencyKey: idempotencyKey,
+		}, PurchaseStatusDraft)
+		if err != nil {
+			return CreateDraftPurchasesResponse{Message: resp.Message, Data: drafts[:i]}, err
+		}
+		drafts[i].PurchaseNumber = purchaseNumber
+	}
+
+	return CreateDraftPurchasesResponse{
+		Message: "Draft purchases created successfully",
+		Data:    drafts,
+	}, nil
+}
+
+// nextPurchaseNumber generates a purchase number like PO-20240131-0001
+func nextPurchaseNumber(ctx context.Context, orderDate time.Time) (string, error) {
+	var sequence int64
+	if err := db.QueryRow(ctx, "SELECT nextval('purchase_number_seq')").Scan(&sequence); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("PO-%s-%04d", orderDate.Format("20060102"), sequence), nil
+}
+
+// lastUsedSupplier returns the supplier a product was most recently purchased from
+func lastUsedSupplier(ctx context.Context, productID uuid.UUID) (uuid.UUID, error) {
+	var supplierID uuid.UUID
+	err := db.QueryRow(ctx, `
+		SELECT p.supplier_id
+		FROM purchase_items pi
+		JOIN purchases p ON p.id = pi.purchase_id
+		WHERE pi.product_id = $1 AND p.status <> 'cancelled'
+		ORDER BY p.purchase_date DESC, p.created_at DESC
+		LIMIT 1
+	`, productID).Scan(&supplierID)
+	if err != nil {
+		return uuid.Nil, errors.New("no supplier known for product")
+	}
+	return supplierID, nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"encore.app/money"
+	"encore.app/pagination"
 	"encore.dev/types/uuid"
 )
 
@@ -47,16 +49,58 @@ func (s *CreateSupplierRequest) Validate() error {
 	return nil
 }
 
+type UpdateSupplierRequest struct {
+	Name          string `json:"name"`
+	ContactPerson string `json:"contact_person"`
+	Email         string `json:"email"`
+	Phone         string `json:"phone"`
+	Address       string `json:"address"`
+	City          string `json:"city"`
+	Country       string `json:"country"`
+}
+
+func (s *UpdateSupplierRequest) Validate() error {
+	create := CreateSupplierRequest(*s)
+	return create.Validate()
+}
+
+type MergeSupplierRequest struct {
+	DuplicateSupplierID uuid.UUID `json:"duplicate_supplier_id"`
+}
+
+func (m *MergeSupplierRequest) Validate() error {
+	if m.DuplicateSupplierID == uuid.Nil {
+		return errors.New("duplicate_supplier_id is required")
+	}
+	return nil
+}
+
+type SupplierMerge struct {
+	SupplierID          uuid.UUID `json:"supplier_id"`
+	DuplicateSupplierID uuid.UUID `json:"duplicate_supplier_id"`
+	PurchasesMoved      int       `json:"purchases_moved"`
+	PricesMoved         int       `json:"prices_moved"`
+	ReturnsMoved        int       `json:"returns_moved"`
+	InvoicesMoved       int       `json:"invoices_moved"`
+	BatchesMoved        int       `json:"batches_moved"`
+}
+
+type MergeSupplierResponse struct {
+	Message string         `json:"message"`
+	Data    *SupplierMerge `json:"data,omitempty"`
+}
+
 type SupplierListItem struct {
-	ID            uuid.UUID `json:"id"`
-	Name          string    `json:"name"`
-	ContactPerson string    `json:"contact_person"`
-	Email         string    `json:"email"`
-	Phone         string    `json:"phone"`
-	Address       string    `json:"address"`
-	City          string    `json:"city"`
-	Country       string    `json:"country"`
-	IsActive      bool      `json:"is_active"`
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	ContactPerson string     `json:"contact_person"`
+	Email         string     `json:"email"`
+	Phone         string     `json:"phone"`
+	Address       string     `json:"address"`
+	City          string     `json:"city"`
+	Country       string     `json:"country"`
+	IsActive      bool       `json:"is_active"`
+	MergedIntoID  *uuid.UUID `json:"merged_into_id,omitempty"`
 }
 
 type SupplierResponse struct {
@@ -64,14 +108,50 @@ type SupplierResponse struct {
 	Data    *SupplierListItem `json:"data,omitempty"`
 }
 
+// supplierSortColumns are the columns suppliers can be sorted by
+var supplierSortColumns = map[string]pagination.Column{
+	"name":       {Expr: "name", Type: "text"},
+	"city":       {Expr: "COALESCE(city, '')", Type: "text"},
+	"country":    {Expr: "COALESCE(country, '')", Type: "text"},
+	"created_at": {Expr: "created_at", Type: "timestamp"},
+}
+
+type GetAllSuppliersParams struct {
+	Cursor   string `query:"cursor"`
+	Limit    int    `query:"limit"`
+	Search   string `query:"search"`
+	City     string `query:"city"`
+	Country  string `query:"country"`
+	IsActive string `query:"is_active"`
+	Sort     string `query:"sort"`
+}
+
+func (g *GetAllSuppliersParams) Validate() error {
+	if err := pagination.ValidateLimit(g.Limit); err != nil {
+		return err
+	}
+	if _, err := pagination.Decode(g.Cursor); err != nil {
+		return err
+	}
+	if _, err := pagination.OptionalBool(g.IsActive); err != nil {
+		return errors.New("is_active must be true or false")
+	}
+	_, err := pagination.ParseSort(g.Sort, supplierSortColumns, "name")
+	return err
+}
+
 type ListSuppliersResponse struct {
-	Message string             `json:"message"`
-	Data    []SupplierListItem `json:"data"`
+	Message    string             `json:"message"`
+	Data       []SupplierListItem `json:"data"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor,omitempty"`
 }
 
 type PurchaseItemRequest struct {
-	ProductID uuid.UUID `json:"product_id"`
-	Quantity  int       `json:"quantity"`
+	ProductID           uuid.UUID     `json:"product_id"`
+	Quantity            int           `json:"quantity"`
+	UnitPrice           *money.Amount `json:"unit_price,omitempty"`
+	DiscountBasisPoints int64         `json:"discount_basis_points"`
 }
 
 type CreatePurchaseRequest struct {
@@ -82,6 +162,15 @@ type CreatePurchaseRequest struct {
 	Notes            string                `json:"notes"`
 	Items            []PurchaseItemRequest `json:"items"`
 	CreatedBy        uuid.UUID             `json:"created_by"`
+	IdempotencyKey   string                `header:"Idempotency-Key"`
+
+	// Invoice discount, either a percentage in basis points or a fixed amount
+	DiscountBasisPoints int64        `json:"discount_basis_points"`
+	DiscountAmount      money.Amount `json:"discount_amount"`
+
+	// Tax rate to apply, the default rate when empty
+	TaxRateID        *uuid.UUID `json:"tax_rate_id,omitempty"`
+	PricesIncludeTax bool       `json:"prices_include_tax"`
 }
 
 func (p *CreatePurchaseRequest) Validate() error {
@@ -97,12 +186,27 @@ func (p *CreatePurchaseRequest) Validate() error {
 	if p.OrderDate.IsZero() {
 		return errors.New("order_date is required")
 	}
+	if !p.ExpectedDelivery.IsZero() && p.ExpectedDelivery.Before(p.OrderDate) {
+		return errors.New("expected_delivery must not be before order_date")
+	}
 	if len(p.Items) == 0 {
 		return errors.New("at least one item is required")
 	}
 	if p.CreatedBy == uuid.Nil {
 		return errors.New("created_by is required")
 	}
+	if len(p.IdempotencyKey) > 100 {
+		return errors.New("idempotency key must be less than 100 characters")
+	}
+	if p.DiscountBasisPoints < 0 || p.DiscountBasisPoints > 10000 {
+		return errors.New("discount_basis_points must be between 0 and 10000")
+	}
+	if p.DiscountAmount < 0 {
+		return errors.New("discount_amount must not be negative")
+	}
+	if p.DiscountBasisPoints > 0 && p.DiscountAmount > 0 {
+		return errors.New("give either discount_basis_points or discount_amount, not both")
+	}
 
 	for i, item := range p.Items {
 		itemNum := i + 1
@@ -112,42 +216,1025 @@ func (p *CreatePurchaseRequest) Validate() error {
 		if item.Quantity <= 0 {
 			return fmt.Errorf("quantity must be greater than 0 for item %d", itemNum)
 		}
+		if item.UnitPrice != nil && *item.UnitPrice < 0 {
+			return fmt.Errorf("unit_price must not be negative for item %d", itemNum)
+		}
+		if item.DiscountBasisPoints < 0 || item.DiscountBasisPoints > 10000 {
+			return fmt.Errorf("discount_basis_points must be between 0 and 10000 for item %d", itemNum)
+		}
 	}
 
 	return nil
 }
 
+type CreatedPurchase struct {
+	ID               uuid.UUID    `json:"id"`
+	PurchaseNumber   string       `json:"purchase_number"`
+	SupplierID       uuid.UUID    `json:"supplier_id"`
+	OrderDate        time.Time    `json:"order_date"`
+	ExpectedDelivery *time.Time   `json:"expected_delivery,omitempty"`
+	Subtotal         money.Amount `json:"subtotal"`
+	DiscountAmount   money.Amount `json:"discount_amount"`
+	TaxBase          money.Amount `json:"tax_base"`
+	TaxAmount        money.Amount `json:"tax_amount"`
+	TotalAmount      money.Amount `json:"total_amount"`
+	Status           string       `json:"status"`
+	CreatedAt        time.Time    `json:"created_at"`
+}
+
+type CreatePurchaseResponse struct {
+	Message string           `json:"message"`
+	Data    *CreatedPurchase `json:"data,omitempty"`
+}
+
 type PurchaseListItem struct {
-	ID               uuid.UUID  `json:"id"`
-	Invoice          string     `json:"invoice"`
-	Supplier         string     `json:"supplier"`
-	OrderDate        time.Time  `json:"order_date"`
-	ExpectedDelivery *time.Time `json:"expected_delivery,omitempty"`
-	Total            float64    `json:"total"`
-	Status           string     `json:"status"`
-	TotalItem        int        `json:"total_item"`
+	ID                  uuid.UUID    `json:"id"`
+	Invoice             string       `json:"invoice"`
+	Supplier            string       `json:"supplier"`
+	OrderDate           time.Time    `json:"order_date"`
+	ExpectedDelivery    *time.Time   `json:"expected_delivery,omitempty"`
+	Subtotal            money.Amount `json:"subtotal"`
+	Discount            money.Amount `json:"discount"`
+	Tax                 money.Amount `json:"tax"`
+	Total               money.Amount `json:"total"`
+	Status              string       `json:"status"`
+	TotalItem           int          `json:"total_item"`
+	OrderedQuantity     int          `json:"ordered_quantity"`
+	ReceivedQuantity    int          `json:"received_quantity"`
+	OutstandingQuantity int          `json:"outstanding_quantity"`
+}
+
+type PurchaseDetailItem struct {
+	ID                  uuid.UUID    `json:"id"`
+	ProductID           uuid.UUID    `json:"product_id"`
+	ProductName         string       `json:"product_name"`
+	Barcode             string       `json:"barcode"`
+	Quantity            int          `json:"quantity"`
+	ReceivedQuantity    int          `json:"received_quantity"`
+	OutstandingQuantity int          `json:"outstanding_quantity"`
+	UnitPrice           money.Amount `json:"unit_price"`
+	DiscountBasisPoints int64        `json:"discount_basis_points"`
+	DiscountAmount      money.Amount `json:"discount_amount"`
+	TotalPrice          money.Amount `json:"total_price"`
+}
+
+type PurchaseDetail struct {
+	ID                  uuid.UUID              `json:"id"`
+	PurchaseNumber      string                 `json:"purchase_number"`
+	Supplier            *SupplierListItem      `json:"supplier"`
+	OrderDate           time.Time              `json:"order_date"`
+	ExpectedDelivery    *time.Time             `json:"expected_delivery,omitempty"`
+	Subtotal            money.Amount           `json:"subtotal"`
+	DiscountBasisPoints int64                  `json:"discount_basis_points"`
+	DiscountAmount      money.Amount           `json:"discount_amount"`
+	TaxBase             money.Amount           `json:"tax_base"`
+	TaxAmount           money.Amount           `json:"tax_amount"`
+	TotalAmount         money.Amount           `json:"total_amount"`
+	TaxRateCode         string                 `json:"tax_rate_code"`
+	TaxRateBasisPoints  int64                  `json:"tax_rate_basis_points"`
+	PricesIncludeTax    bool                   `json:"prices_include_tax"`
+	Status              string                 `json:"status"`
+	Notes               string                 `json:"notes"`
+	ApprovalThreshold   *money.Amount          `json:"approval_threshold,omitempty"`
+	CreatedBy           uuid.UUID              `json:"created_by"`
+	CreatedAt           time.Time              `json:"created_at"`
+	UpdatedAt           time.Time              `json:"updated_at"`
+	Items               []PurchaseDetailItem   `json:"items"`
+	History             []PurchaseStatusChange `json:"history"`
+}
+
+type PurchaseDetailResponse struct {
+	Message string          `json:"message"`
+	Data    *PurchaseDetail `json:"data,omitempty"`
+}
+
+// purchaseSortColumns are the columns purchases can be sorted by
+var purchaseSortColumns = map[string]pagination.Column{
+	"purchase_date":   {Expr: "purchase_date", Type: "date"},
+	"purchase_number": {Expr: "purchase_number", Type: "text"},
+	"total_amount":    {Expr: "total_amount", Type: "numeric"},
+	"created_at":      {Expr: "created_at", Type: "timestamp"},
+}
+
+type GetAllPurchasesParams struct {
+	Cursor     string    `query:"cursor"`
+	Limit      int       `query:"limit"`
+	Search     string    `query:"search"`
+	Status     string    `query:"status"`
+	SupplierID uuid.UUID `query:"supplier_id"`
+	DateFrom   string    `query:"date_from"`
+	DateTo     string    `query:"date_to"`
+	Sort       string    `query:"sort"`
+}
+
+func (g *GetAllPurchasesParams) Validate() error {
+	if err := pagination.ValidateLimit(g.Limit); err != nil {
+		return err
+	}
+	if _, err := pagination.Decode(g.Cursor); err != nil {
+		return err
+	}
+	validStatuses := map[string]bool{
+		"":                              true,
+		PurchaseStatusDraft:             true,
+		PurchaseStatusSubmitted:         true,
+		PurchaseStatusApproved:          true,
+		PurchaseStatusRejected:          true,
+		PurchaseStatusPartiallyReceived: true,
+		PurchaseStatusReceived:          true,
+		PurchaseStatusClosed:            true,
+		PurchaseStatusCancelled:         true,
+	}
+	if !validStatuses[g.Status] {
+		return errors.New("status must be one of: draft, submitted, approved, rejected, partially_received, received, closed, cancelled")
+	}
+	from, err := g.dateFrom()
+	if err != nil {
+		return errors.New("date_from must be a date in YYYY-MM-DD format")
+	}
+	to, err := g.dateTo()
+	if err != nil {
+		return errors.New("date_to must be a date in YYYY-MM-DD format")
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return errors.New("date_to must not be before date_from")
+	}
+	_, err = pagination.ParseSort(g.Sort, purchaseSortColumns, "-purchase_date")
+	return err
+}
+
+func (g *GetAllPurchasesParams) dateFrom() (*time.Time, error) {
+	return parseOptionalDate(g.DateFrom)
+}
+
+func (g *GetAllPurchasesParams) dateTo() (*time.Time, error) {
+	return parseOptionalDate(g.DateTo)
+}
+
+// parseOptionalDate parses a YYYY-MM-DD date, returning nil when it is empty
+func parseOptionalDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+type OverdueDeliveriesParams struct {
+	AsOf       string    `query:"as_of"`
+	SupplierID uuid.UUID `query:"supplier_id"`
+}
+
+func (o *OverdueDeliveriesParams) Validate() error {
+	if _, err := parseOptionalDate(o.AsOf); err != nil {
+		return errors.New("as_of must be a date in YYYY-MM-DD format")
+	}
+	return nil
+}
+
+type OverduePurchase struct {
+	ID                  uuid.UUID    `json:"id"`
+	PurchaseNumber      string       `json:"purchase_number"`
+	OrderDate           time.Time    `json:"order_date"`
+	ExpectedDelivery    time.Time    `json:"expected_delivery"`
+	DaysOverdue         int          `json:"days_overdue"`
+	Status              string       `json:"status"`
+	Total               money.Amount `json:"total"`
+	OrderedQuantity     int          `json:"ordered_quantity"`
+	ReceivedQuantity    int          `json:"received_quantity"`
+	OutstandingQuantity int          `json:"outstanding_quantity"`
+}
+
+type OverdueSupplier struct {
+	SupplierID          uuid.UUID         `json:"supplier_id"`
+	SupplierName        string            `json:"supplier_name"`
+	Email               string            `json:"email"`
+	Phone               string            `json:"phone"`
+	OverduePurchases    int               `json:"overdue_purchases"`
+	OutstandingQuantity int               `json:"outstanding_quantity"`
+	MaxDaysOverdue      int               `json:"max_days_overdue"`
+	Purchases           []OverduePurchase `json:"purchases"`
+}
+
+type OverdueDeliveriesResponse struct {
+	Message string            `json:"message"`
+	AsOf    time.Time         `json:"as_of"`
+	Data    []OverdueSupplier `json:"data"`
 }
 
 type ListPurchasesResponse struct {
-	Message string             `json:"message"`
-	Data    []PurchaseListItem `json:"data"`
+	Message    string             `json:"message"`
+	Data       []PurchaseListItem `json:"data"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor,omitempty"`
 }
 
 type UpdatePurchaseStatusRequest struct {
-	Status string `json:"status"`
+	Status    string    `json:"status"`
+	Reason    string    `json:"reason"`
+	ChangedBy uuid.UUID `json:"changed_by"`
 }
 
 func (u *UpdatePurchaseStatusRequest) Validate() error {
 	if u.Status == "" {
 		return errors.New("status is required")
 	}
+	// Approval and rejection go through their own endpoints
 	validStatuses := map[string]bool{
-		"pending":   true,
-		"completed": true,
-		"cancelled": true,
+		PurchaseStatusDraft:     true,
+		PurchaseStatusSubmitted: true,
+		PurchaseStatusClosed:    true,
+		PurchaseStatusCancelled: true,
 	}
 	if !validStatuses[u.Status] {
-		return errors.New("status must be one of: pending, completed, cancelled")
+		return errors.New("status must be one of: draft, submitted, closed, cancelled")
+	}
+	if (u.Status == PurchaseStatusCancelled || u.Status == PurchaseStatusClosed) && u.Reason == "" {
+		return errors.New("reason is required to cancel or close a purchase")
+	}
+	if u.ChangedBy == uuid.Nil {
+		return errors.New("changed_by is required")
 	}
 	return nil
 }
+
+type PurchaseStatusChange struct {
+	ID         uuid.UUID `json:"id"`
+	FromStatus *string   `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	ChangedBy  uuid.UUID `json:"changed_by"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+type PurchaseHistoryResponse struct {
+	Message string                 `json:"message"`
+	Data    []PurchaseStatusChange `json:"data"`
+}
+
+type PurchaseDecisionRequest struct {
+	ApproverID uuid.UUID `json:"approver_id"`
+	Comment    string    `json:"comment"`
+}
+
+func (p *PurchaseDecisionRequest) Validate() error {
+	if p.ApproverID == uuid.Nil {
+		return errors.New("approver_id is required")
+	}
+	if len(p.Comment) > 1000 {
+		return errors.New("comment must be less than 1000 characters")
+	}
+	return nil
+}
+
+type PurchaseApproval struct {
+	ID         uuid.UUID `json:"id"`
+	PurchaseID uuid.UUID `json:"purchase_id"`
+	Decision   string    `json:"decision"`
+	Comment    string    `json:"comment"`
+	DecidedBy  uuid.UUID `json:"decided_by"`
+	DecidedAt  time.Time `json:"decided_at"`
+}
+
+type PurchaseDecisionResponse struct {
+	Message string            `json:"message"`
+	Data    *PurchaseApproval `json:"data,omitempty"`
+}
+
+type SetApprovalThresholdRequest struct {
+	SupplierID *uuid.UUID   `json:"supplier_id,omitempty"`
+	CategoryID *uuid.UUID   `json:"category_id,omitempty"`
+	Amount     money.Amount `json:"amount"`
+}
+
+func (s *SetApprovalThresholdRequest) Validate() error {
+	if s.SupplierID != nil && s.CategoryID != nil {
+		return errors.New("a threshold applies to either supplier_id or category_id, not both")
+	}
+	if s.Amount < 0 {
+		return errors.New("amount must not be negative")
+	}
+	return nil
+}
+
+type ApprovalThreshold struct {
+	ID         uuid.UUID    `json:"id"`
+	SupplierID *uuid.UUID   `json:"supplier_id"`
+	CategoryID *uuid.UUID   `json:"category_id"`
+	Amount     money.Amount `json:"amount"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+type ApprovalThresholdResponse struct {
+	Message string             `json:"message"`
+	Data    *ApprovalThreshold `json:"data,omitempty"`
+}
+
+type ListApprovalThresholdsResponse struct {
+	Message string              `json:"message"`
+	Data    []ApprovalThreshold `json:"data"`
+}
+
+type SupplierPriceRequest struct {
+	ProductID        uuid.UUID    `json:"product_id"`
+	UnitCost         money.Amount `json:"unit_cost"`
+	MinOrderQuantity int          `json:"min_order_quantity"`
+	ValidFrom        time.Time    `json:"valid_from"`
+	ValidTo          *time.Time   `json:"valid_to,omitempty"`
+}
+
+func (s *SupplierPriceRequest) Validate() error {
+	if s.ProductID == uuid.Nil {
+		return errors.New("product_id is required")
+	}
+	if s.UnitCost < 0 {
+		return errors.New("unit_cost must not be negative")
+	}
+	if s.MinOrderQuantity < 0 {
+		return errors.New("min_order_quantity must not be negative")
+	}
+	if s.ValidFrom.IsZero() {
+		return errors.New("valid_from is required")
+	}
+	if s.ValidTo != nil && s.ValidTo.Before(s.ValidFrom) {
+		return errors.New("valid_to must not be before valid_from")
+	}
+	return nil
+}
+
+type SupplierPrice struct {
+	ID               uuid.UUID    `json:"id"`
+	SupplierID       uuid.UUID    `json:"supplier_id"`
+	ProductID        uuid.UUID    `json:"product_id"`
+	UnitCost         money.Amount `json:"unit_cost"`
+	MinOrderQuantity int          `json:"min_order_quantity"`
+	ValidFrom        time.Time    `json:"valid_from"`
+	ValidTo          *time.Time   `json:"valid_to"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+type SupplierPriceResponse struct {
+	Message string         `json:"message"`
+	Data    *SupplierPrice `json:"data,omitempty"`
+}
+
+type GetSupplierPricesParams struct {
+	ProductID uuid.UUID `query:"product_id"`
+	ActiveOn  string    `query:"active_on"`
+}
+
+func (g *GetSupplierPricesParams) Validate() error {
+	if _, err := parseOptionalDate(g.ActiveOn); err != nil {
+		return errors.New("active_on must be a date in YYYY-MM-DD format")
+	}
+	return nil
+}
+
+type ListSupplierPricesResponse struct {
+	Message string          `json:"message"`
+	Data    []SupplierPrice `json:"data"`
+}
+
+type TaxRateRequest struct {
+	Code            string `json:"code"`
+	Name            string `json:"name"`
+	RateBasisPoints int64  `json:"rate_basis_points"`
+	IsDefault       bool   `json:"is_default"`
+	IsActive        *bool  `json:"is_active,omitempty"`
+}
+
+func (t *TaxRateRequest) Validate() error {
+	if t.Code == "" {
+		return errors.New("code is required")
+	}
+	if len(t.Code) > 20 {
+		return errors.New("code must be less than 20 characters")
+	}
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(t.Name) > 100 {
+		return errors.New("name must be less than 100 characters")
+	}
+	if t.RateBasisPoints < 0 || t.RateBasisPoints > 10000 {
+		return errors.New("rate_basis_points must be between 0 and 10000")
+	}
+	if t.IsDefault && t.IsActive != nil && !*t.IsActive {
+		return errors.New("the default tax rate must be active")
+	}
+	return nil
+}
+
+type TaxRate struct {
+	ID              uuid.UUID `json:"id"`
+	Code            string    `json:"code"`
+	Name            string    `json:"name"`
+	RateBasisPoints int64     `json:"rate_basis_points"`
+	IsDefault       bool      `json:"is_default"`
+	IsActive        bool      `json:"is_active"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type TaxRateResponse struct {
+	Message string   `json:"message"`
+	Data    *TaxRate `json:"data,omitempty"`
+}
+
+type ListTaxRatesResponse struct {
+	Message string    `json:"message"`
+	Data    []TaxRate `json:"data"`
+}
+
+type SupplierReturnItemRequest struct {
+	BatchID  uuid.UUID `json:"batch_id"`
+	Quantity int       `json:"quantity"`
+}
+
+type CreateSupplierReturnRequest struct {
+	PurchaseID  uuid.UUID                   `json:"purchase_id"`
+	Reason      string                      `json:"reason"`
+	Notes       string                      `json:"notes"`
+	RequestedBy uuid.UUID                   `json:"requested_by"`
+	Items       []SupplierReturnItemRequest `json:"items"`
+}
+
+func (c *CreateSupplierReturnRequest) Validate() error {
+	if c.PurchaseID == uuid.Nil {
+		return errors.New("purchase_id is required")
+	}
+	validReasons := map[string]bool{
+		"damaged":     true,
+		"recalled":    true,
+		"near_expiry": true,
+		"wrong_item":  true,
+		"other":       true,
+	}
+	if !validReasons[c.Reason] {
+		return errors.New("reason must be one of: damaged, recalled, near_expiry, wrong_item, other")
+	}
+	if c.Reason == "other" && c.Notes == "" {
+		return errors.New("notes are required when reason is other")
+	}
+	if c.RequestedBy == uuid.Nil {
+		return errors.New("requested_by is required")
+	}
+	if len(c.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for i, item := range c.Items {
+		itemNum := i + 1
+		if item.BatchID == uuid.Nil {
+			return fmt.Errorf("batch_id is required for item %d", itemNum)
+		}
+		if seen[item.BatchID] {
+			return fmt.Errorf("batch_id is listed more than once for item %d", itemNum)
+		}
+		seen[item.BatchID] = true
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0 for item %d", itemNum)
+		}
+	}
+	return nil
+}
+
+type ShipSupplierReturnRequest struct {
+	ShippedBy uuid.UUID `json:"shipped_by"`
+	ShippedAt time.Time `json:"shipped_at"`
+}
+
+func (s *ShipSupplierReturnRequest) Validate() error {
+	if s.ShippedBy == uuid.Nil {
+		return errors.New("shipped_by is required")
+	}
+	return nil
+}
+
+type CreditSupplierReturnRequest struct {
+	CreditNoteNumber string       `json:"credit_note_number"`
+	CreditAmount     money.Amount `json:"credit_amount"`
+	CreditedBy       uuid.UUID    `json:"credited_by"`
+	CreditedAt       time.Time    `json:"credited_at"`
+}
+
+func (c *CreditSupplierReturnRequest) Validate() error {
+	if c.CreditNoteNumber == "" {
+		return errors.New("credit_note_number is required")
+	}
+	if len(c.CreditNoteNumber) > 50 {
+		return errors.New("credit_note_number must be less than 50 characters")
+	}
+	if c.CreditAmount < 0 {
+		return errors.New("credit_amount must not be negative")
+	}
+	if c.CreditedBy == uuid.Nil {
+		return errors.New("credited_by is required")
+	}
+	return nil
+}
+
+type SupplierReturnItem struct {
+	ID             uuid.UUID    `json:"id"`
+	BatchID        uuid.UUID    `json:"batch_id"`
+	ProductID      uuid.UUID    `json:"product_id"`
+	BatchNumber    string       `json:"batch_number"`
+	ExpirationDate time.Time    `json:"expiration_date"`
+	Quantity       int          `json:"quantity"`
+	UnitCost       money.Amount `json:"unit_cost"`
+	TotalCost      money.Amount `json:"total_cost"`
+}
+
+type SupplierReturn struct {
+	ID               uuid.UUID            `json:"id"`
+	ReturnNumber     string               `json:"return_number"`
+	PurchaseID       uuid.UUID            `json:"purchase_id"`
+	SupplierID       uuid.UUID            `json:"supplier_id"`
+	Reason           string               `json:"reason"`
+	Status           string               `json:"status"`
+	TotalAmount      money.Amount         `json:"total_amount"`
+	Notes            string               `json:"notes"`
+	RequestedBy      uuid.UUID            `json:"requested_by"`
+	RequestedAt      time.Time            `json:"requested_at"`
+	ShippedBy        *uuid.UUID           `json:"shipped_by,omitempty"`
+	ShippedAt        *time.Time           `json:"shipped_at,omitempty"`
+	CreditNoteNumber *string              `json:"credit_note_number,omitempty"`
+	CreditAmount     *money.Amount        `json:"credit_amount,omitempty"`
+	CreditedBy       *uuid.UUID           `json:"credited_by,omitempty"`
+	CreditedAt       *time.Time           `json:"credited_at,omitempty"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+	Items            []SupplierReturnItem `json:"items"`
+}
+
+type SupplierReturnResponse struct {
+	Message string          `json:"message"`
+	Data    *SupplierReturn `json:"data,omitempty"`
+}
+
+type SupplierReturnListItem struct {
+	ID             uuid.UUID     `json:"id"`
+	ReturnNumber   string        `json:"return_number"`
+	PurchaseID     uuid.UUID     `json:"purchase_id"`
+	PurchaseNumber string        `json:"purchase_number"`
+	SupplierID     uuid.UUID     `json:"supplier_id"`
+	SupplierName   string        `json:"supplier_name"`
+	Reason         string        `json:"reason"`
+	Status         string        `json:"status"`
+	TotalAmount    money.Amount  `json:"total_amount"`
+	CreditAmount   *money.Amount `json:"credit_amount,omitempty"`
+	RequestedAt    time.Time     `json:"requested_at"`
+}
+
+// supplierReturnSortColumns are the columns supplier returns can be sorted by
+var supplierReturnSortColumns = map[string]pagination.Column{
+	"created_at":    {Expr: "created_at", Type: "timestamp"},
+	"return_number": {Expr: "return_number", Type: "text"},
+	"total_amount":  {Expr: "total_amount", Type: "numeric"},
+}
+
+type GetAllSupplierReturnsParams struct {
+	Cursor     string    `query:"cursor"`
+	Limit      int       `query:"limit"`
+	SupplierID uuid.UUID `query:"supplier_id"`
+	PurchaseID uuid.UUID `query:"purchase_id"`
+	Status     string    `query:"status"`
+	Sort       string    `query:"sort"`
+}
+
+func (g *GetAllSupplierReturnsParams) Validate() error {
+	if err := pagination.ValidateLimit(g.Limit); err != nil {
+		return err
+	}
+	if _, err := pagination.Decode(g.Cursor); err != nil {
+		return err
+	}
+	validStatuses := map[string]bool{
+		"":                    true,
+		ReturnStatusRequested: true,
+		ReturnStatusShipped:   true,
+		ReturnStatusCredited:  true,
+	}
+	if !validStatuses[g.Status] {
+		return errors.New("status must be one of: requested, shipped, credited")
+	}
+	_, err := pagination.ParseSort(g.Sort, supplierReturnSortColumns, "-created_at")
+	return err
+}
+
+type ListSupplierReturnsResponse struct {
+	Message    string                   `json:"message"`
+	Data       []SupplierReturnListItem `json:"data"`
+	Total      int                      `json:"total"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+type CreateSupplierInvoiceRequest struct {
+	PurchaseID       uuid.UUID    `json:"purchase_id"`
+	InvoiceNumber    string       `json:"invoice_number"`
+	InvoiceDate      time.Time    `json:"invoice_date"`
+	PaymentTermsDays *int         `json:"payment_terms_days,omitempty"`
+	Amount           money.Amount `json:"amount"`
+	Notes            string       `json:"notes"`
+	CreatedBy        uuid.UUID    `json:"created_by"`
+}
+
+func (c *CreateSupplierInvoiceRequest) Validate() error {
+	if c.PurchaseID == uuid.Nil {
+		return errors.New("purchase_id is required")
+	}
+	if c.InvoiceNumber == "" {
+		return errors.New("invoice_number is required")
+	}
+	if len(c.InvoiceNumber) > 50 {
+		return errors.New("invoice_number must be less than 50 characters")
+	}
+	if c.InvoiceDate.IsZero() {
+		return errors.New("invoice_date is required")
+	}
+	if c.PaymentTermsDays != nil && *c.PaymentTermsDays < 0 {
+		return errors.New("payment_terms_days must not be negative")
+	}
+	if c.Amount < 0 {
+		return errors.New("amount must not be negative")
+	}
+	if c.CreatedBy == uuid.Nil {
+		return errors.New("created_by is required")
+	}
+	return nil
+}
+
+type RecordSupplierPaymentRequest struct {
+	Amount      money.Amount `json:"amount"`
+	PaymentDate time.Time    `json:"payment_date"`
+	Method      string       `json:"method"`
+	Reference   string       `json:"reference"`
+	Notes       string       `json:"notes"`
+	CreatedBy   uuid.UUID    `json:"created_by"`
+}
+
+func (r *RecordSupplierPaymentRequest) Validate() error {
+	if r.Amount <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+	if r.PaymentDate.IsZero() {
+		return errors.New("payment_date is required")
+	}
+	validMethods := map[string]bool{
+		"bank_transfer": true,
+		"cash":          true,
+		"giro":          true,
+		"other":         true,
+	}
+	if !validMethods[r.Method] {
+		return errors.New("method must be one of: bank_transfer, cash, giro, other")
+	}
+	if len(r.Reference) > 100 {
+		return errors.New("reference must be less than 100 characters")
+	}
+	if r.CreatedBy == uuid.Nil {
+		return errors.New("created_by is required")
+	}
+	return nil
+}
+
+type RecordSupplierCreditNoteRequest struct {
+	ReturnID         *uuid.UUID   `json:"return_id,omitempty"`
+	CreditNoteNumber string       `json:"credit_note_number"`
+	CreditDate       time.Time    `json:"credit_date"`
+	Amount           money.Amount `json:"amount"`
+	Notes            string       `json:"notes"`
+	CreatedBy        uuid.UUID    `json:"created_by"`
+}
+
+func (r *RecordSupplierCreditNoteRequest) Validate() error {
+	// Number and amount may be taken from the credited supplier return
+	if r.ReturnID == nil && r.CreditNoteNumber == "" {
+		return errors.New("credit_note_number is required")
+	}
+	if len(r.CreditNoteNumber) > 50 {
+		return errors.New("credit_note_number must be less than 50 characters")
+	}
+	if r.CreditDate.IsZero() {
+		return errors.New("credit_date is required")
+	}
+	if r.Amount < 0 || (r.ReturnID == nil && r.Amount == 0) {
+		return errors.New("amount must be greater than 0")
+	}
+	if r.CreatedBy == uuid.Nil {
+		return errors.New("created_by is required")
+	}
+	return nil
+}
+
+type SupplierPayment struct {
+	ID          uuid.UUID    `json:"id"`
+	Amount      money.Amount `json:"amount"`
+	PaymentDate time.Time    `json:"payment_date"`
+	Method      string       `json:"method"`
+	Reference   string       `json:"reference"`
+	Notes       string       `json:"notes"`
+	CreatedBy   uuid.UUID    `json:"created_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type SupplierCreditNote struct {
+	ID               uuid.UUID    `json:"id"`
+	ReturnID         *uuid.UUID   `json:"return_id,omitempty"`
+	CreditNoteNumber string       `json:"credit_note_number"`
+	CreditDate       time.Time    `json:"credit_date"`
+	Amount           money.Amount `json:"amount"`
+	Notes            string       `json:"notes"`
+	CreatedBy        uuid.UUID    `json:"created_by"`
+	CreatedAt        time.Time    `json:"created_at"`
+}
+
+type SupplierInvoice struct {
+	ID               uuid.UUID            `json:"id"`
+	SupplierID       uuid.UUID            `json:"supplier_id"`
+	PurchaseID       uuid.UUID            `json:"purchase_id"`
+	InvoiceNumber    string               `json:"invoice_number"`
+	InvoiceDate      time.Time            `json:"invoice_date"`
+	PaymentTermsDays int                  `json:"payment_terms_days"`
+	DueDate          time.Time            `json:"due_date"`
+	Amount           money.Amount         `json:"amount"`
+	PaidAmount       money.Amount         `json:"paid_amount"`
+	CreditedAmount   money.Amount         `json:"credited_amount"`
+	Balance          money.Amount         `json:"balance"`
+	Status           string               `json:"status"`
+	Notes            string               `json:"notes"`
+	CreatedBy        uuid.UUID            `json:"created_by"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+	Payments         []SupplierPayment    `json:"payments"`
+	CreditNotes      []SupplierCreditNote `json:"credit_notes"`
+}
+
+type SupplierInvoiceResponse struct {
+	Message string           `json:"message"`
+	Data    *SupplierInvoice `json:"data,omitempty"`
+}
+
+type SupplierInvoiceListItem struct {
+	ID             uuid.UUID    `json:"id"`
+	SupplierID     uuid.UUID    `json:"supplier_id"`
+	SupplierName   string       `json:"supplier_name"`
+	PurchaseID     uuid.UUID    `json:"purchase_id"`
+	PurchaseNumber string       `json:"purchase_number"`
+	InvoiceNumber  string       `json:"invoice_number"`
+	InvoiceDate    time.Time    `json:"invoice_date"`
+	DueDate        time.Time    `json:"due_date"`
+	Amount         money.Amount `json:"amount"`
+	Balance        money.Amount `json:"balance"`
+	Status         string       `json:"status"`
+}
+
+// supplierInvoiceSortColumns are the columns supplier invoices can be sorted by
+var supplierInvoiceSortColumns = map[string]pagination.Column{
+	"due_date":       {Expr: "due_date", Type: "date"},
+	"invoice_date":   {Expr: "invoice_date", Type: "date"},
+	"invoice_number": {Expr: "invoice_number", Type: "text"},
+	"balance":        {Expr: "balance", Type: "numeric"},
+	"created_at":     {Expr: "created_at", Type: "timestamp"},
+}
+
+type GetAllSupplierInvoicesParams struct {
+	Cursor     string    `query:"cursor"`
+	Limit      int       `query:"limit"`
+	SupplierID uuid.UUID `query:"supplier_id"`
+	PurchaseID uuid.UUID `query:"purchase_id"`
+	Status     string    `query:"status"`
+	Overdue    string    `query:"overdue"`
+	Sort       string    `query:"sort"`
+}
+
+func (g *GetAllSupplierInvoicesParams) Validate() error {
+	if err := pagination.ValidateLimit(g.Limit); err != nil {
+		return err
+	}
+	if _, err := pagination.Decode(g.Cursor); err != nil {
+		return err
+	}
+	validStatuses := map[string]bool{
+		"":                         true,
+		InvoiceStatusOpen:          true,
+		InvoiceStatusPartiallyPaid: true,
+		InvoiceStatusPaid:          true,
+	}
+	if !validStatuses[g.Status] {
+		return errors.New("status must be one of: open, partially_paid, paid")
+	}
+	if _, err := pagination.OptionalBool(g.Overdue); err != nil {
+		return errors.New("overdue must be true or false")
+	}
+	_, err := pagination.ParseSort(g.Sort, supplierInvoiceSortColumns, "due_date")
+	return err
+}
+
+type ListSupplierInvoicesResponse struct {
+	Message    string                    `json:"message"`
+	Data       []SupplierInvoiceListItem `json:"data"`
+	Total      int                       `json:"total"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+type AgedPayablesParams struct {
+	AsOf       string    `query:"as_of"`
+	SupplierID uuid.UUID `query:"supplier_id"`
+}
+
+func (a *AgedPayablesParams) Validate() error {
+	if _, err := parseOptionalDate(a.AsOf); err != nil {
+		return errors.New("as_of must be a date in YYYY-MM-DD format")
+	}
+	return nil
+}
+
+// AgedPayableBuckets splits an outstanding balance by days past the due date
+type AgedPayableBuckets struct {
+	Current    money.Amount `json:"current"`
+	Days1To30  money.Amount `json:"days_1_30"`
+	Days31To60 money.Amount `json:"days_31_60"`
+	Days61To90 money.Amount `json:"days_61_90"`
+	DaysOver90 money.Amount `json:"days_over_90"`
+	Total      money.Amount `json:"total"`
+}
+
+type AgedPayable struct {
+	SupplierID   uuid.UUID    `json:"supplier_id"`
+	SupplierName string       `json:"supplier_name"`
+	OpenInvoices int          `json:"open_invoices"`
+	Current      money.Amount `json:"current"`
+	Days1To30    money.Amount `json:"days_1_30"`
+	Days31To60   money.Amount `json:"days_31_60"`
+	Days61To90   money.Amount `json:"days_61_90"`
+	DaysOver90   money.Amount `json:"days_over_90"`
+	Total        money.Amount `json:"total"`
+}
+
+type AgedPayablesResponse struct {
+	Message string             `json:"message"`
+	AsOf    time.Time          `json:"as_of"`
+	Data    []AgedPayable      `json:"data"`
+	Totals  AgedPayableBuckets `json:"totals"`
+}
+
+type ReceivePurchaseItemRequest struct {
+	PurchaseItemID   uuid.UUID    `json:"purchase_item_id"`
+	BatchNumber      string       `json:"batch_number"`
+	ExpirationDate   time.Time    `json:"expiration_date"`
+	ReceivedQuantity int          `json:"received_quantity"`
+	SellingPrice     money.Amount `json:"selling_price"`
+}
+
+type ReceivePurchaseRequest struct {
+	DeliveryNoteNumber string                       `json:"delivery_note_number"`
+	ReceivedAt         time.Time                    `json:"received_at"`
+	ReceivedBy         uuid.UUID                    `json:"received_by"`
+	Notes              string                       `json:"notes"`
+	Items              []ReceivePurchaseItemRequest `json:"items"`
+}
+
+func (r *ReceivePurchaseRequest) Validate() error {
+	if r.DeliveryNoteNumber == "" {
+		return errors.New("delivery_note_number is required")
+	}
+	if len(r.DeliveryNoteNumber) > 50 {
+		return errors.New("delivery_note_number must be less than 50 characters")
+	}
+	if r.ReceivedBy == uuid.Nil {
+		return errors.New("received_by is required")
+	}
+	if len(r.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for i, item := range r.Items {
+		itemNum := i + 1
+		if item.PurchaseItemID == uuid.Nil {
+			return fmt.Errorf("purchase_item_id is required for item %d", itemNum)
+		}
+		if seen[item.PurchaseItemID] {
+			return fmt.Errorf("purchase_item_id is duplicated for item %d", itemNum)
+		}
+		seen[item.PurchaseItemID] = true
+		if item.BatchNumber == "" {
+			return fmt.Errorf("batch_number is required for item %d", itemNum)
+		}
+		if len(item.BatchNumber) > 255 {
+			return fmt.Errorf("batch_number must be less than 255 characters for item %d", itemNum)
+		}
+		if item.ExpirationDate.IsZero() {
+			return fmt.Errorf("expiration_date is required for item %d", itemNum)
+		}
+		if item.ReceivedQuantity <= 0 {
+			return fmt.Errorf("received_quantity must be greater than 0 for item %d", itemNum)
+		}
+		if item.SellingPrice < 0 {
+			return fmt.Errorf("selling_price must be non-negative for item %d", itemNum)
+		}
+	}
+
+	return nil
+}
+
+type ReceivePurchaseResponse struct {
+	Message   string      `json:"message"`
+	ReceiptID *uuid.UUID  `json:"receipt_id,omitempty"`
+	Status    string      `json:"status,omitempty"`
+	BatchIDs  []uuid.UUID `json:"batch_ids,omitempty"`
+}
+
+type PurchaseReceiptItem struct {
+	PurchaseItemID uuid.UUID  `json:"purchase_item_id"`
+	ProductID      uuid.UUID  `json:"product_id"`
+	Quantity       int        `json:"quantity"`
+	BatchNumber    string     `json:"batch_number"`
+	ExpirationDate time.Time  `json:"expiration_date"`
+	BatchID        *uuid.UUID `json:"batch_id,omitempty"`
+}
+
+type PurchaseReceiptListItem struct {
+	ID                 uuid.UUID             `json:"id"`
+	DeliveryNoteNumber string                `json:"delivery_note_number"`
+	ReceivedAt         time.Time             `json:"received_at"`
+	ReceivedBy         uuid.UUID             `json:"received_by"`
+	Notes              string                `json:"notes"`
+	StockedAt          *time.Time            `json:"stocked_at"`
+	Items              []PurchaseReceiptItem `json:"items"`
+}
+
+type ListPurchaseReceiptsResponse struct {
+	Message string                    `json:"message"`
+	Data    []PurchaseReceiptListItem `json:"data"`
+}
+
+type DraftPurchaseItemRequest struct {
+	ProductID  uuid.UUID     `json:"product_id"`
+	Quantity   int           `json:"quantity"`
+	SupplierID uuid.UUID     `json:"supplier_id"`
+	UnitPrice  *money.Amount `json:"unit_price,omitempty"`
+}
+
+type CreateDraftPurchasesRequest struct {
+	OrderDate      time.Time                  `json:"order_date"`
+	Notes          string                     `json:"notes"`
+	Items          []DraftPurchaseItemRequest `json:"items"`
+	CreatedBy      uuid.UUID                  `json:"created_by"`
+	IdempotencyKey string                     `header:"Idempotency-Key"`
+}
+
+func (d *CreateDraftPurchasesRequest) Validate() error {
+	if len(d.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+	if d.CreatedBy == uuid.Nil {
+		return errors.New("created_by is required")
+	}
+	// Each draft is keyed by the request key and its supplier ID
+	if len(d.IdempotencyKey) > 60 {
+		return errors.New("idempotency key must be less than 60 characters")
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for i, item := range d.Items {
+		itemNum := i + 1
+		if item.ProductID == uuid.Nil {
+			return fmt.Errorf("product_id is required for item %d", itemNum)
+		}
+		if seen[item.ProductID] {
+			return fmt.Errorf("product_id is duplicated for item %d", itemNum)
+		}
+		seen[item.ProductID] = true
+		if item.Quantity < 0 {
+			return fmt.Errorf("quantity must be non-negative for item %d", itemNum)
+		}
+	}
+
+	return nil
+}
+
+type DraftPurchase struct {
+	PurchaseNumber string                `json:"purchase_number"`
+	SupplierID     uuid.UUID             `json:"supplier_id"`
+	Supplier       string                `json:"supplier"`
+	Items          []PurchaseItemRequest `json:"items"`
+}
+
+type CreateDraftPurchasesResponse struct {
+	Message string          `json:"message"`
+	Data    []DraftPurchase `json:"data,omitempty"`
+}
+
+type ProductReferencesResponse struct {
+	Message        string `json:"message"`
+	PurchaseItems  int    `json:"purchase_items"`
+	SupplierPrices int    `json:"supplier_prices"`
+}
@@ -0,0 +1,12 @@
+-- Drop purchase receipt tables
+DROP TABLE IF EXISTS purchase_receipt_items;
+DROP TABLE IF EXISTS purchase_receipts;
+
+-- Restore the original status constraint
+UPDATE purchases SET status = 'pending' WHERE status = 'partially_received';
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+ALTER TABLE purchases
+    ADD CONSTRAINT purchases_status_check CHECK (status IN ('pending', 'completed', 'cancelled'));
+
+-- Drop received quantity tracking
+ALTER TABLE purchase_items DROP COLUMN IF EXISTS received_quantity;
@@ -0,0 +1,46 @@
+-- Track received quantities per purchase item
+ALTER TABLE purchase_items
+    ADD COLUMN received_quantity INT NOT NULL DEFAULT 0 CHECK (received_quantity >= 0);
+ALTER TABLE purchase_items
+    ADD CONSTRAINT purchase_items_received_quantity_check CHECK (received_quantity <= quantity);
+
+-- Purchases completed before receipt tracking existed are considered fully received
+UPDATE purchase_items pi
+SET received_quantity = pi.quantity
+FROM purchases p
+WHERE pi.purchase_id = p.id AND p.status = 'completed';
+
+-- Allow the partially_received status
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+ALTER TABLE purchases
+    ADD CONSTRAINT purchases_status_check CHECK (status IN ('pending', 'partially_received', 'completed', 'cancelled'));
+
+-- Create purchase_receipts table
+-- id, purchase_id, delivery_note_number, received_at, received_by, notes, created_at
+CREATE TABLE purchase_receipts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    purchase_id UUID NOT NULL REFERENCES purchases(id),
+    delivery_note_number VARCHAR(50) NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    received_by UUID NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create purchase_receipt_items table
+-- id, receipt_id, purchase_item_id, quantity, batch_number, expiration_date, batch_id, created_at
+CREATE TABLE purchase_receipt_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    receipt_id UUID NOT NULL REFERENCES purchase_receipts(id),
+    purchase_item_id UUID NOT NULL REFERENCES purchase_items(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    batch_number VARCHAR(255) NOT NULL,
+    expiration_date DATE NOT NULL,
+    batch_id UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_purchase_receipts_purchase_id ON purchase_receipts(purchase_id);
+CREATE INDEX idx_purchase_receipt_items_receipt_id ON purchase_receipt_items(receipt_id);
+CREATE INDEX idx_purchase_receipt_items_purchase_item_id ON purchase_receipt_items(purchase_item_id);
@@ -0,0 +1,8 @@
+-- Drop purchase number sequence
+DROP SEQUENCE IF EXISTS purchase_number_seq;
+
+-- Restore the status constraint without drafts
+UPDATE purchases SET status = 'pending' WHERE status = 'draft';
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+ALTER TABLE purchases
+    ADD CONSTRAINT purchases_status_check CHECK (status IN ('pending', 'partially_received', 'completed', 'cancelled'));
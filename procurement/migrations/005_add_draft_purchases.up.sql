@@ -0,0 +1,7 @@
+-- Allow draft purchases generated from reorder suggestions
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+ALTER TABLE purchases
+    ADD CONSTRAINT purchases_status_check CHECK (status IN ('draft', 'pending', 'partially_received', 'completed', 'cancelled'));
+
+-- Sequence used to generate purchase numbers
+CREATE SEQUENCE purchase_number_seq;
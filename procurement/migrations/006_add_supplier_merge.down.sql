@@ -0,0 +1,3 @@
+-- Drop supplier merge tracking
+DROP INDEX IF EXISTS idx_suppliers_merged_into_id;
+ALTER TABLE suppliers DROP COLUMN IF EXISTS merged_into_id;
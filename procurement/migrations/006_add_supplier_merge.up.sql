@@ -0,0 +1,4 @@
+-- Track suppliers that were merged into a canonical supplier
+ALTER TABLE suppliers ADD COLUMN merged_into_id UUID REFERENCES suppliers(id);
+
+CREATE INDEX idx_suppliers_merged_into_id ON suppliers(merged_into_id);
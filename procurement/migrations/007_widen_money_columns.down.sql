@@ -0,0 +1,3 @@
+-- Restore the original money column precision
+ALTER TABLE purchase_items ALTER COLUMN total_price TYPE DECIMAL(10,2);
+ALTER TABLE purchases ALTER COLUMN total_amount TYPE DECIMAL(10,2);
@@ -0,0 +1,3 @@
+-- Widen money columns beyond the DECIMAL(10,2) ceiling of about 99 million
+ALTER TABLE purchases ALTER COLUMN total_amount TYPE NUMERIC(15,2);
+ALTER TABLE purchase_items ALTER COLUMN total_price TYPE NUMERIC(15,2);
@@ -0,0 +1,3 @@
+-- Drop purchase idempotency key
+DROP INDEX IF EXISTS idx_purchases_idempotency_key;
+ALTER TABLE purchases DROP COLUMN IF EXISTS idempotency_key;
@@ -0,0 +1,4 @@
+-- Idempotency key sent by clients so retried purchase creation returns the original purchase
+ALTER TABLE purchases ADD COLUMN idempotency_key VARCHAR(100);
+
+CREATE UNIQUE INDEX idx_purchases_idempotency_key ON purchases(idempotency_key) WHERE idempotency_key IS NOT NULL;
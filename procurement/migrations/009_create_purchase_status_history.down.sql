@@ -0,0 +1,10 @@
+-- Drop purchase status history
+DROP TABLE IF EXISTS purchase_status_history;
+
+-- Restore the previous statuses
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+UPDATE purchases SET status = 'pending' WHERE status IN ('submitted', 'approved');
+UPDATE purchases SET status = 'completed' WHERE status IN ('received', 'closed');
+ALTER TABLE purchases
+    ADD CONSTRAINT purchases_status_check CHECK (status IN ('draft', 'pending', 'partially_received', 'completed', 'cancelled'));
+ALTER TABLE purchases ALTER COLUMN status SET DEFAULT 'pending';
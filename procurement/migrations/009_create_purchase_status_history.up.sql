@@ -0,0 +1,29 @@
+-- Purchase order lifecycle: draft -> submitted -> approved -> partially_received -> received -> closed.
+-- Purchases that were pending could already be received, so they become approved;
+-- completed purchases become received.
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+UPDATE purchases SET status = 'approved' WHERE status = 'pending';
+UPDATE purchases SET status = 'received' WHERE status = 'completed';
+ALTER TABLE purchases
+    ADD CONSTRAINT purchases_status_check CHECK (status IN ('draft', 'submitted', 'approved', 'partially_received', 'received', 'closed', 'cancelled'));
+ALTER TABLE purchases ALTER COLUMN status SET DEFAULT 'submitted';
+
+-- Create purchase_status_history table
+-- id, purchase_id, from_status, to_status, reason, changed_by, changed_at
+CREATE TABLE purchase_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    purchase_id UUID NOT NULL REFERENCES purchases(id),
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT,
+    changed_by UUID NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_purchase_status_history_purchase_id ON purchase_status_history(purchase_id, changed_at);
+
+-- Existing purchases start their history in their current status
+INSERT INTO purchase_status_history (purchase_id, from_status, to_status, reason, changed_by, changed_at)
+SELECT id, NULL, status, 'Status before history tracking', created_by, created_at
+FROM purchases;
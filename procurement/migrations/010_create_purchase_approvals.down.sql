@@ -0,0 +1,10 @@
+-- Drop purchase approvals and thresholds
+DROP TABLE IF EXISTS purchase_approvals;
+DROP TABLE IF EXISTS approval_thresholds;
+ALTER TABLE purchases DROP COLUMN IF EXISTS approval_threshold;
+
+-- Restore the status constraint without rejections
+UPDATE purchases SET status = 'draft' WHERE status = 'rejected';
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+ALTER TABLE purchases
+    ADD CONSTRAINT purchases_status_check CHECK (status IN ('draft', 'submitted', 'approved', 'partially_received', 'received', 'closed', 'cancelled'));
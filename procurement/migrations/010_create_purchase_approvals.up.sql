@@ -0,0 +1,39 @@
+-- Purchases above the approval threshold wait in submitted until a manager approves or rejects them
+ALTER TABLE purchases DROP CONSTRAINT IF EXISTS purchases_status_check;
+ALTER TABLE purchases
+    ADD CONSTRAINT purchases_status_check CHECK (status IN ('draft', 'submitted', 'approved', 'rejected', 'partially_received', 'received', 'closed', 'cancelled'));
+
+-- Threshold a purchase exceeded when it was submitted for approval
+ALTER TABLE purchases ADD COLUMN approval_threshold NUMERIC(15,2);
+
+-- Create approval_thresholds table
+-- id, supplier_id, category_id, amount, created_at, updated_at
+-- A threshold applies to a supplier, to a product category, or to all purchases when both are empty
+CREATE TABLE approval_thresholds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    supplier_id UUID REFERENCES suppliers(id),
+    category_id UUID,
+    amount NUMERIC(15,2) NOT NULL CHECK (amount >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (supplier_id IS NULL OR category_id IS NULL)
+);
+
+-- Create indexes
+CREATE UNIQUE INDEX idx_approval_thresholds_supplier_id ON approval_thresholds(supplier_id) WHERE supplier_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_approval_thresholds_category_id ON approval_thresholds(category_id) WHERE category_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_approval_thresholds_default ON approval_thresholds((TRUE)) WHERE supplier_id IS NULL AND category_id IS NULL;
+
+-- Create purchase_approvals table
+-- id, purchase_id, decision, comment, decided_by, decided_at
+CREATE TABLE purchase_approvals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    purchase_id UUID NOT NULL REFERENCES purchases(id),
+    decision VARCHAR(20) NOT NULL CHECK (decision IN ('approved', 'rejected')),
+    comment TEXT,
+    decided_by UUID NOT NULL,
+    decided_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_purchase_approvals_purchase_id ON purchase_approvals(purchase_id);
@@ -0,0 +1,7 @@
+-- Move the expected delivery back into notes
+UPDATE purchases
+SET notes = concat_ws(E'\n', NULLIF(notes, ''), 'Expected delivery: ' || to_char(expected_delivery, 'YYYY-MM-DD'))
+WHERE expected_delivery IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_purchases_expected_delivery;
+ALTER TABLE purchases DROP COLUMN IF EXISTS expected_delivery;
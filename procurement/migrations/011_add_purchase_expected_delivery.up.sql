@@ -0,0 +1,26 @@
+-- Expected delivery date of a purchase, previously kept in notes as "Expected delivery: YYYY-MM-DD"
+ALTER TABLE purchases ADD COLUMN expected_delivery DATE;
+
+-- Backfill from notes and remove the line from notes. Notes with an invalid date are left untouched.
+DO $$
+DECLARE
+    purchase RECORD;
+BEGIN
+    FOR purchase IN
+        SELECT id, notes, substring(notes FROM 'Expected delivery:\s*(\d{4}-\d{2}-\d{2})') AS expected_delivery
+        FROM purchases
+        WHERE notes ~ 'Expected delivery:\s*\d{4}-\d{2}-\d{2}'
+    LOOP
+        BEGIN
+            UPDATE purchases
+            SET expected_delivery = purchase.expected_delivery::date,
+                notes = btrim(regexp_replace(purchase.notes, '\n?Expected delivery:\s*\d{4}-\d{2}-\d{2}( \d{2}:\d{2}:\d{2})?', ''), E' \n')
+            WHERE id = purchase.id;
+        EXCEPTION WHEN invalid_datetime_format OR datetime_field_overflow THEN
+            RAISE NOTICE 'purchase % has an invalid expected delivery in notes', purchase.id;
+        END;
+    END LOOP;
+END $$;
+
+-- Create indexes
+CREATE INDEX idx_purchases_expected_delivery ON purchases(expected_delivery) WHERE status IN ('approved', 'partially_received');
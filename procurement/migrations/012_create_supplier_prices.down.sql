@@ -0,0 +1,2 @@
+ALTER TABLE purchase_items DROP COLUMN IF EXISTS unit_price;
+DROP TABLE IF EXISTS supplier_prices;
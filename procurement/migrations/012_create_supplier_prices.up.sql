@@ -0,0 +1,25 @@
+-- Create supplier_prices table
+-- id, supplier_id, product_id, unit_cost, min_order_quantity, valid_from, valid_to, created_at, updated_at
+-- Entries with a higher minimum order quantity give volume prices for the same product
+CREATE TABLE supplier_prices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    supplier_id UUID NOT NULL REFERENCES suppliers(id),
+    product_id UUID NOT NULL,
+    unit_cost NUMERIC(15,2) NOT NULL CHECK (unit_cost >= 0),
+    min_order_quantity INT NOT NULL DEFAULT 1 CHECK (min_order_quantity > 0),
+    valid_from DATE NOT NULL,
+    valid_to DATE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (valid_to IS NULL OR valid_to >= valid_from)
+);
+
+-- Create indexes
+CREATE INDEX idx_supplier_prices_supplier_product ON supplier_prices(supplier_id, product_id, valid_from);
+CREATE INDEX idx_supplier_prices_product_id ON supplier_prices(product_id);
+
+-- Unit price of purchase lines, previously only derivable from total_price
+ALTER TABLE purchase_items ADD COLUMN unit_price NUMERIC(15,2);
+UPDATE purchase_items SET unit_price = ROUND(total_price / quantity, 2);
+ALTER TABLE purchase_items ALTER COLUMN unit_price SET NOT NULL;
+ALTER TABLE purchase_items ADD CONSTRAINT purchase_items_unit_price_check CHECK (unit_price >= 0);
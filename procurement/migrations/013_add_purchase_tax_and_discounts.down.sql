@@ -0,0 +1,11 @@
+ALTER TABLE purchases DROP COLUMN IF EXISTS prices_include_tax;
+ALTER TABLE purchases DROP COLUMN IF EXISTS tax_rate_basis_points;
+ALTER TABLE purchases DROP COLUMN IF EXISTS tax_rate_id;
+ALTER TABLE purchases DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE purchases DROP COLUMN IF EXISTS tax_base;
+ALTER TABLE purchases DROP COLUMN IF EXISTS discount_amount;
+ALTER TABLE purchases DROP COLUMN IF EXISTS discount_basis_points;
+ALTER TABLE purchases DROP COLUMN IF EXISTS subtotal;
+ALTER TABLE purchase_items DROP COLUMN IF EXISTS discount_amount;
+ALTER TABLE purchase_items DROP COLUMN IF EXISTS discount_basis_points;
+DROP TABLE IF EXISTS tax_rates;
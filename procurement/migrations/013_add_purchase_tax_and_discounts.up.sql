@@ -0,0 +1,40 @@
+-- Create tax_rates table
+-- id, code, name, rate_basis_points, is_default, is_active, created_at, updated_at
+-- Rates are in basis points, 1100 is 11%
+CREATE TABLE tax_rates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    rate_basis_points INT NOT NULL CHECK (rate_basis_points >= 0 AND rate_basis_points <= 10000),
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE UNIQUE INDEX idx_tax_rates_default ON tax_rates(is_default) WHERE is_default;
+
+INSERT INTO tax_rates (code, name, rate_basis_points, is_default) VALUES
+('PPN', 'Pajak Pertambahan Nilai 11%', 1100, TRUE),
+('EXEMPT', 'Tidak dikenakan PPN', 0, FALSE);
+
+-- Line discounts; total_price becomes the line total after its discount
+ALTER TABLE purchase_items ADD COLUMN discount_basis_points INT NOT NULL DEFAULT 0 CHECK (discount_basis_points >= 0 AND discount_basis_points <= 10000);
+ALTER TABLE purchase_items ADD COLUMN discount_amount NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (discount_amount >= 0);
+
+-- Purchase totals: subtotal is before discounts, discount_amount covers line and
+-- invoice discounts, and total_amount is the grand total including tax
+ALTER TABLE purchases ADD COLUMN subtotal NUMERIC(15,2);
+ALTER TABLE purchases ADD COLUMN discount_basis_points INT NOT NULL DEFAULT 0 CHECK (discount_basis_points >= 0 AND discount_basis_points <= 10000);
+ALTER TABLE purchases ADD COLUMN discount_amount NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (discount_amount >= 0);
+ALTER TABLE purchases ADD COLUMN tax_base NUMERIC(15,2);
+ALTER TABLE purchases ADD COLUMN tax_amount NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (tax_amount >= 0);
+ALTER TABLE purchases ADD COLUMN tax_rate_id UUID REFERENCES tax_rates(id);
+ALTER TABLE purchases ADD COLUMN tax_rate_basis_points INT NOT NULL DEFAULT 0;
+ALTER TABLE purchases ADD COLUMN prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Existing purchases were recorded without discounts or tax
+UPDATE purchases SET subtotal = total_amount, tax_base = total_amount;
+ALTER TABLE purchases ALTER COLUMN subtotal SET NOT NULL;
+ALTER TABLE purchases ALTER COLUMN tax_base SET NOT NULL;
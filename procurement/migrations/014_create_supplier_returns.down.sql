@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS supplier_return_items;
+DROP TABLE IF EXISTS supplier_returns;
+DROP SEQUENCE IF EXISTS supplier_return_number_seq;
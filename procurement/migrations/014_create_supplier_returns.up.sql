@@ -0,0 +1,50 @@
+CREATE SEQUENCE supplier_return_number_seq;
+
+-- Create supplier_returns table
+-- id, return_number, purchase_id, supplier_id, reason, status, total_amount, notes,
+-- requested_by, requested_at, shipped_by, shipped_at, credit_note_number, credit_amount, credited_by, credited_at, created_at, updated_at
+CREATE TABLE supplier_returns (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    return_number VARCHAR(50) NOT NULL UNIQUE,
+    purchase_id UUID NOT NULL REFERENCES purchases(id),
+    supplier_id UUID NOT NULL REFERENCES suppliers(id),
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('damaged', 'recalled', 'near_expiry', 'wrong_item', 'other')),
+    status VARCHAR(20) NOT NULL DEFAULT 'requested' CHECK (status IN ('requested', 'shipped', 'credited')),
+    total_amount NUMERIC(15,2) NOT NULL CHECK (total_amount >= 0),
+    notes TEXT,
+    requested_by UUID NOT NULL,
+    requested_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    shipped_by UUID,
+    shipped_at TIMESTAMP,
+    credit_note_number VARCHAR(50),
+    credit_amount NUMERIC(15,2) CHECK (credit_amount >= 0),
+    credited_by UUID,
+    credited_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_supplier_returns_purchase_id ON supplier_returns(purchase_id);
+CREATE INDEX idx_supplier_returns_supplier_id ON supplier_returns(supplier_id);
+CREATE INDEX idx_supplier_returns_status ON supplier_returns(status);
+CREATE UNIQUE INDEX idx_supplier_returns_credit_note ON supplier_returns(supplier_id, credit_note_number) WHERE credit_note_number IS NOT NULL;
+
+-- Create supplier_return_items table
+-- id, return_id, batch_id, product_id, batch_number, expiration_date, quantity, unit_cost, total_cost, created_at
+CREATE TABLE supplier_return_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    return_id UUID NOT NULL REFERENCES supplier_returns(id),
+    batch_id UUID NOT NULL,
+    product_id UUID NOT NULL,
+    batch_number VARCHAR(255) NOT NULL,
+    expiration_date DATE NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_cost NUMERIC(15,2) NOT NULL CHECK (unit_cost >= 0),
+    total_cost NUMERIC(15,2) NOT NULL CHECK (total_cost >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_supplier_return_items_return_id ON supplier_return_items(return_id);
+CREATE INDEX idx_supplier_return_items_batch_id ON supplier_return_items(batch_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS supplier_credit_notes;
+DROP TABLE IF EXISTS supplier_payments;
+DROP TABLE IF EXISTS supplier_invoices;
@@ -0,0 +1,65 @@
+-- Create supplier_invoices table
+-- id, supplier_id, purchase_id, invoice_number, invoice_date, payment_terms_days, due_date, amount,
+-- paid_amount, credited_amount, status, notes, created_by, created_at, updated_at
+CREATE TABLE supplier_invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    supplier_id UUID NOT NULL REFERENCES suppliers(id),
+    purchase_id UUID NOT NULL REFERENCES purchases(id),
+    invoice_number VARCHAR(50) NOT NULL,
+    invoice_date DATE NOT NULL,
+    payment_terms_days INT NOT NULL CHECK (payment_terms_days >= 0),
+    due_date DATE NOT NULL,
+    amount NUMERIC(15,2) NOT NULL CHECK (amount > 0),
+    paid_amount NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (paid_amount >= 0),
+    credited_amount NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (credited_amount >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'partially_paid', 'paid')),
+    notes TEXT,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (supplier_id, invoice_number),
+    CHECK (paid_amount + credited_amount <= amount)
+);
+
+-- Create indexes
+CREATE INDEX idx_supplier_invoices_purchase_id ON supplier_invoices(purchase_id);
+CREATE INDEX idx_supplier_invoices_due_date ON supplier_invoices(due_date) WHERE status <> 'paid';
+
+-- Create supplier_payments table
+-- id, invoice_id, supplier_id, amount, payment_date, method, reference, notes, created_by, created_at
+CREATE TABLE supplier_payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES supplier_invoices(id),
+    supplier_id UUID NOT NULL REFERENCES suppliers(id),
+    amount NUMERIC(15,2) NOT NULL CHECK (amount > 0),
+    payment_date DATE NOT NULL,
+    method VARCHAR(20) NOT NULL CHECK (method IN ('bank_transfer', 'cash', 'giro', 'other')),
+    reference VARCHAR(100),
+    notes TEXT,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_supplier_payments_invoice_id ON supplier_payments(invoice_id);
+
+-- Create supplier_credit_notes table
+-- id, invoice_id, supplier_id, return_id, credit_note_number, credit_date, amount, notes, created_by, created_at
+CREATE TABLE supplier_credit_notes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES supplier_invoices(id),
+    supplier_id UUID NOT NULL REFERENCES suppliers(id),
+    return_id UUID REFERENCES supplier_returns(id),
+    credit_note_number VARCHAR(50) NOT NULL,
+    credit_date DATE NOT NULL,
+    amount NUMERIC(15,2) NOT NULL CHECK (amount > 0),
+    notes TEXT,
+    created_by UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+-- The credit note of a supplier return may be spread over several invoices
+CREATE INDEX idx_supplier_credit_notes_invoice_id ON supplier_credit_notes(invoice_id);
+CREATE INDEX idx_supplier_credit_notes_return_id ON supplier_credit_notes(return_id);
+CREATE UNIQUE INDEX idx_supplier_credit_notes_number ON supplier_credit_notes(supplier_id, credit_note_number) WHERE return_id IS NULL;
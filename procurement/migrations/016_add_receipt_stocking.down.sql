@@ -0,0 +1,5 @@
+-- Drop receipt stocking columns
+ALTER TABLE purchase_receipt_items DROP COLUMN IF EXISTS selling_price;
+ALTER TABLE purchase_receipt_items DROP COLUMN IF EXISTS purchase_price;
+ALTER TABLE purchase_receipt_items DROP COLUMN IF EXISTS line_number;
+ALTER TABLE purchase_receipts DROP COLUMN IF EXISTS stocked_at;
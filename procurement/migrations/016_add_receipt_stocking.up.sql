@@ -0,0 +1,9 @@
+-- Receipts are recorded before their batches are created in the product
+-- service; stocked_at is set once every line is linked to its batch
+ALTER TABLE purchase_receipts ADD COLUMN stocked_at TIMESTAMP;
+UPDATE purchase_receipts SET stocked_at = created_at;
+
+-- Receipt lines keep what is needed to create their batches again on a retry
+ALTER TABLE purchase_receipt_items ADD COLUMN line_number INT;
+ALTER TABLE purchase_receipt_items ADD COLUMN purchase_price NUMERIC(15,2) CHECK (purchase_price >= 0);
+ALTER TABLE purchase_receipt_items ADD COLUMN selling_price NUMERIC(15,2) CHECK (selling_price >= 0);
@@ -0,0 +1,650 @@
+package procurement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/money"
+	"encore.app/pagination"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+	"encore.dev/types/uuid"
+)
+
+// Supplier invoice statuses
+const (
+	InvoiceStatusOpen          = "open"
+	InvoiceStatusPartiallyPaid = "partially_paid"
+	InvoiceStatusPaid          = "paid"
+)
+
+// defaultPaymentTermsDays is used when an invoice gives no payment terms (net 30)
+const defaultPaymentTermsDays = 30
+
+// CreateSupplierInvoice records a supplier invoice against a purchase. The due
+// date follows from the invoice date and the payment terms. The invoices of a
+// purchase must not add up to more than its total.
+//
+//encore:api public method=POST path=/api/supplier-invoices
+func CreateSupplierInvoice(ctx context.Context, req *CreateSupplierInvoiceRequest) (SupplierInvoiceResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SupplierInvoiceResponse{Message: "Validation failed"}, err
+	}
+
+	paymentTermsDays := defaultPaymentTermsDays
+	if req.PaymentTermsDays != nil {
+		paymentTermsDays = *req.PaymentTermsDays
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the purchase so concurrent invoices are checked against each other
+	var supplierID uuid.UUID
+	var status string
+	var purchaseTotal money.Amount
+	err = tx.QueryRow(ctx, "SELECT supplier_id, status, total_amount FROM purchases WHERE id = $1 FOR UPDATE", req.PurchaseID).Scan(&supplierID, &status, &purchaseTotal)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Purchase not found"}, errors.New("purchase not found")
+	}
+	invoiceable := map[string]bool{
+		PurchaseStatusApproved:          true,
+		PurchaseStatusPartiallyReceived: true,
+		PurchaseStatusReceived:          true,
+		PurchaseStatusClosed:            true,
+	}
+	if !invoiceable[status] {
+		return SupplierInvoiceResponse{Message: "Purchase cannot be invoiced"}, errors.New("purchase cannot be invoiced, current status: " + status)
+	}
+
+	amount := req.Amount
+	var invoiced money.Amount
+	err = tx.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM supplier_invoices WHERE purchase_id = $1", req.PurchaseID).Scan(&invoiced)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to check invoiced amount"}, err
+	}
+	if amount == 0 {
+		amount = purchaseTotal - invoiced
+	}
+	if amount <= 0 || invoiced+amount > purchaseTotal {
+		return SupplierInvoiceResponse{Message: "Validation failed"}, fmt.Errorf("invoice amount exceeds the uninvoiced purchase total of %s", (purchaseTotal - invoiced).String())
+	}
+
+	invoice := SupplierInvoice{
+		SupplierID:       supplierID,
+		PurchaseID:       req.PurchaseID,
+		InvoiceNumber:    req.InvoiceNumber,
+		InvoiceDate:      req.InvoiceDate,
+		PaymentTermsDays: paymentTermsDays,
+		DueDate:          req.InvoiceDate.AddDate(0, 0, paymentTermsDays),
+		Amount:           amount,
+		Balance:          amount,
+		Status:           InvoiceStatusOpen,
+		Notes:            req.Notes,
+		CreatedBy:        req.CreatedBy,
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO supplier_invoices (supplier_id, purchase_id, invoice_number, invoice_date, payment_terms_days, due_date, amount, status, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at
+	`, supplierID, req.PurchaseID, req.InvoiceNumber, req.InvoiceDate, paymentTermsDays, invoice.DueDate, amount, InvoiceStatusOpen, req.Notes, req.CreatedBy).Scan(
+		&invoice.ID,
+		&invoice.CreatedAt,
+		&invoice.UpdatedAt,
+	)
+	if sqldb.ErrCode(err) == sqlerr.UniqueViolation {
+		return SupplierInvoiceResponse{Message: "Invoice number already recorded for this supplier"}, errors.New("invoice number already recorded for this supplier")
+	}
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to create supplier invoice"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to commit supplier invoice"}, err
+	}
+
+	return SupplierInvoiceResponse{
+		Message: "Supplier invoice created successfully",
+		Data:    &invoice,
+	}, nil
+}
+
+// GetAllSupplierInvoices retrieves a page of supplier invoices, optionally
+// filtered by supplier, purchase and status, or limited to overdue invoices
+//
+//encore:api public method=GET path=/api/supplier-invoices
+func GetAllSupplierInvoices(ctx context.Context, params *GetAllSupplierInvoicesParams) (ListSupplierInvoicesResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return ListSupplierInvoicesResponse{Message: "Validation failed"}, err
+	}
+	cursor, _ := pagination.Decode(params.Cursor)
+	sort, _ := pagination.ParseSort(params.Sort, supplierInvoiceSortColumns, "due_date")
+	overdue, _ := pagination.OptionalBool(params.Overdue)
+	limit := pagination.Limit(params.Limit)
+
+	var supplierID, purchaseID *uuid.UUID
+	if params.SupplierID != uuid.Nil {
+		supplierID = &params.SupplierID
+	}
+	if params.PurchaseID != uuid.Nil {
+		purchaseID = &params.PurchaseID
+	}
+	filterArgs := []interface{}{supplierID, purchaseID, optionalText(params.Status), overdue}
+
+	base := `
+		WITH invoice_list AS (
+			SELECT
+				i.id,
+				i.supplier_id,
+				s.name as supplier_name,
+				i.purchase_id,
+				p.purchase_number,
+				i.invoice_number,
+				i.invoice_date,
+				i.due_date,
+				i.amount,
+				i.amount - i.paid_amount - i.credited_amount as balance,
+				i.status,
+				i.created_at
+			FROM supplier_invoices i
+			JOIN suppliers s ON s.id = i.supplier_id
+			JOIN purchases p ON p.id = i.purchase_id
+			WHERE ($1::uuid IS NULL OR i.supplier_id = $1)
+				AND ($2::uuid IS NULL OR i.purchase_id = $2)
+				AND ($3::text IS NULL OR i.status = $3)
+				AND ($4::boolean IS NULL OR (i.status <> 'paid' AND i.due_date < CURRENT_DATE) = $4)
+		)
+	`
+
+	var total int
+	err := db.QueryRow(ctx, base+"SELECT COUNT(*) FROM invoice_list", filterArgs...).Scan(&total)
+	if err != nil {
+		return ListSupplierInvoicesResponse{Message: "Failed to count supplier invoices"}, errors.New("failed to count supplier invoices")
+	}
+
+	cursorValue, cursorID := pagination.Args(cursor)
+	query := base + fmt.Sprintf(`
+		SELECT
+			id,
+			supplier_id,
+			supplier_name,
+			purchase_id,
+			purchase_number,
+			invoice_number,
+			invoice_date,
+			due_date,
+			amount,
+			balance,
+			status,
+			(%s)::text as sort_value
+		FROM invoice_list
+		WHERE %s
+		ORDER BY %s
+		LIMIT $7
+	`, sort.Column.Expr, sort.After("id", 5, 6), sort.OrderBy("id"))
+	rows, err := db.Query(ctx, query, append(filterArgs, cursorValue, cursorID, limit+1)...)
+	if err != nil {
+		return ListSupplierInvoicesResponse{
+			Message: "Failed to retrieve supplier invoices",
+			Data:    []SupplierInvoiceListItem{},
+		}, errors.New("failed to retrieve supplier invoices")
+	}
+	defer rows.Close()
+
+	var invoices []SupplierInvoiceListItem
+	var sortValues []string
+	for rows.Next() {
+		var invoice SupplierInvoiceListItem
+		var sortValue string
+		err = rows.Scan(
+			&invoice.ID,
+			&invoice.SupplierID,
+			&invoice.SupplierName,
+			&invoice.PurchaseID,
+			&invoice.PurchaseNumber,
+			&invoice.InvoiceNumber,
+			&invoice.InvoiceDate,
+			&invoice.DueDate,
+			&invoice.Amount,
+			&invoice.Balance,
+			&invoice.Status,
+			&sortValue,
+		)
+		if err != nil {
+			return ListSupplierInvoicesResponse{Message: "Failed to scan supplier invoice"}, errors.New("failed to scan supplier invoice")
+		}
+		invoices = append(invoices, invoice)
+		sortValues = append(sortValues, sortValue)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListSupplierInvoicesResponse{Message: "Error iterating supplier invoices"}, errors.New("error iterating supplier invoices: " + err.Error())
+	}
+
+	// The extra row only tells whether there is a next page
+	var nextCursor string
+	if len(invoices) > limit {
+		invoices = invoices[:limit]
+		nextCursor = pagination.Encode(sortValues[limit-1], invoices[limit-1].ID)
+	}
+
+	return ListSupplierInvoicesResponse{
+		Message:    "Supplier invoices retrieved successfully",
+		Data:       invoices,
+		Total:      total,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// GetSupplierInvoice retrieves a supplier invoice with its payments and credit notes
+//
+//encore:api public method=GET path=/api/supplier-invoices/:id
+func GetSupplierInvoice(ctx context.Context, id uuid.UUID) (SupplierInvoiceResponse, error) {
+	invoice, err := supplierInvoiceByID(ctx, id)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Supplier invoice not found"}, err
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT id, amount, payment_date, method, COALESCE(reference, ''), COALESCE(notes, ''), created_by, created_at
+		FROM supplier_payments
+		WHERE invoice_id = $1
+		ORDER BY payment_date, created_at
+	`, id)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to retrieve supplier payments"}, errors.New("failed to retrieve supplier payments")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payment SupplierPayment
+		err = rows.Scan(
+			&payment.ID,
+			&payment.Amount,
+			&payment.PaymentDate,
+			&payment.Method,
+			&payment.Reference,
+			&payment.Notes,
+			&payment.CreatedBy,
+			&payment.CreatedAt,
+		)
+		if err != nil {
+			return SupplierInvoiceResponse{Message: "Failed to scan supplier payment"}, errors.New("failed to scan supplier payment")
+		}
+		invoice.Payments = append(invoice.Payments, payment)
+	}
+	if err = rows.Err(); err != nil {
+		return SupplierInvoiceResponse{Message: "Error iterating supplier payments"}, errors.New("error iterating supplier payments: " + err.Error())
+	}
+	rows.Close()
+
+	rows, err = db.Query(ctx, `
+		SELECT id, return_id, credit_note_number, credit_date, amount, COALESCE(notes, ''), created_by, created_at
+		FROM supplier_credit_notes
+		WHERE invoice_id = $1
+		ORDER BY credit_date, created_at
+	`, id)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to retrieve supplier credit notes"}, errors.New("failed to retrieve supplier credit notes")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var creditNote SupplierCreditNote
+		err = rows.Scan(
+			&creditNote.ID,
+			&creditNote.ReturnID,
+			&creditNote.CreditNoteNumber,
+			&creditNote.CreditDate,
+			&creditNote.Amount,
+			&creditNote.Notes,
+			&creditNote.CreatedBy,
+			&creditNote.CreatedAt,
+		)
+		if err != nil {
+			return SupplierInvoiceResponse{Message: "Failed to scan supplier credit note"}, errors.New("failed to scan supplier credit note")
+		}
+		invoice.CreditNotes = append(invoice.CreditNotes, creditNote)
+	}
+	if err = rows.Err(); err != nil {
+		return SupplierInvoiceResponse{Message: "Error iterating supplier credit notes"}, errors.New("error iterating supplier credit notes: " + err.Error())
+	}
+
+	return SupplierInvoiceResponse{
+		Message: "Supplier invoice retrieved successfully",
+		Data:    invoice,
+	}, nil
+}
+
+// RecordSupplierPayment records a full or partial payment of a supplier invoice
+//
+//encore:api public method=POST path=/api/supplier-invoices/:id/payments
+func RecordSupplierPayment(ctx context.Context, id uuid.UUID, req *RecordSupplierPaymentRequest) (SupplierInvoiceResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SupplierInvoiceResponse{Message: "Validation failed"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	supplierID, balance, err := lockSupplierInvoice(ctx, tx, id)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Supplier invoice not found"}, err
+	}
+	if req.Amount > balance {
+		return SupplierInvoiceResponse{Message: "Validation failed"}, errors.New("amount exceeds the invoice balance of " + balance.String())
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO supplier_payments (invoice_id, supplier_id, amount, payment_date, method, reference, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, id, supplierID, req.Amount, req.PaymentDate, req.Method, req.Reference, req.Notes, req.CreatedBy)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to record supplier payment"}, err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE supplier_invoices SET paid_amount = paid_amount + $1 WHERE id = $2", req.Amount, id)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to update supplier invoice"}, err
+	}
+	if err = updateInvoiceStatus(ctx, tx, id); err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to update supplier invoice"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to commit supplier payment"}, err
+	}
+
+	return GetSupplierInvoice(ctx, id)
+}
+
+// RecordSupplierCreditNote applies a supplier credit note to an invoice. A
+// credit note issued for a supplier return can be applied by its return ID,
+// spread over several invoices if needed; its number defaults to the one
+// recorded on the return and its amount to the credit not yet applied.
+//
+//encore:api public method=POST path=/api/supplier-invoices/:id/credit-notes
+func RecordSupplierCreditNote(ctx context.Context, id uuid.UUID, req *RecordSupplierCreditNoteRequest) (SupplierInvoiceResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SupplierInvoiceResponse{Message: "Validation failed"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	supplierID, balance, err := lockSupplierInvoice(ctx, tx, id)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Supplier invoice not found"}, err
+	}
+
+	creditNoteNumber := req.CreditNoteNumber
+	amount := req.Amount
+	if req.ReturnID != nil {
+		// Lock the return so concurrent applications cannot overspend its credit
+		var returnSupplierID uuid.UUID
+		var status string
+		var returnCreditNote *string
+		var returnCredit *money.Amount
+		err = tx.QueryRow(ctx, `
+			SELECT supplier_id, status, credit_note_number, credit_amount
+			FROM supplier_returns
+			WHERE id = $1
+			FOR UPDATE
+		`, *req.ReturnID).Scan(&returnSupplierID, &status, &returnCreditNote, &returnCredit)
+		if err != nil {
+			return SupplierInvoiceResponse{Message: "Supplier return not found"}, errors.New("supplier return not found")
+		}
+		if returnSupplierID != supplierID {
+			return SupplierInvoiceResponse{Message: "Validation failed"}, errors.New("supplier return belongs to a different supplier")
+		}
+		if status != ReturnStatusCredited {
+			return SupplierInvoiceResponse{Message: "Validation failed"}, errors.New("supplier return has not been credited, current status: " + status)
+		}
+
+		var applied money.Amount
+		err = tx.QueryRow(ctx, `
+			SELECT COALESCE(SUM(amount), 0)
+			FROM supplier_credit_notes
+			WHERE return_id = $1
+		`, *req.ReturnID).Scan(&applied)
+		if err != nil {
+			return SupplierInvoiceResponse{Message: "Failed to retrieve applied credit"}, err
+		}
+		remaining := *returnCredit - applied
+		if remaining <= 0 {
+			return SupplierInvoiceResponse{Message: "Validation failed"}, errors.New("the credit of the supplier return has been applied in full")
+		}
+
+		if creditNoteNumber == "" {
+			creditNoteNumber = *returnCreditNote
+		}
+		if amount == 0 {
+			amount = remaining
+			if amount > balance {
+				amount = balance
+			}
+		}
+		if amount > remaining {
+			return SupplierInvoiceResponse{Message: "Validation failed"}, errors.New("amount exceeds the unapplied credit of the supplier return " + remaining.String())
+		}
+	}
+	if creditNoteNumber == "" {
+		return SupplierInvoiceResponse{Message: "Validation failed"}, errors.New("credit_note_number is required")
+	}
+	if amount <= 0 {
+		return SupplierInvoiceResponse{Message: "Validation failed"}, errors.New("amount must be greater than 0")
+	}
+	if amount > balance {
+		return SupplierInvoiceResponse{Message: "Validation failed"}, errors.New("amount exceeds the invoice balance of " + balance.String())
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO supplier_credit_notes (invoice_id, supplier_id, return_id, credit_note_number, credit_date, amount, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, id, supplierID, req.ReturnID, creditNoteNumber, req.CreditDate, amount, req.Notes, req.CreatedBy)
+	if sqldb.ErrCode(err) == sqlerr.UniqueViolation {
+		return SupplierInvoiceResponse{Message: "Credit note already applied"}, errors.New("credit note number already applied")
+	}
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to record supplier credit note"}, err
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE supplier_invoices SET credited_amount = credited_amount + $1 WHERE id = $2", amount, id)
+	if err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to update supplier invoice"}, err
+	}
+	if err = updateInvoiceStatus(ctx, tx, id); err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to update supplier invoice"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return SupplierInvoiceResponse{Message: "Failed to commit supplier credit note"}, err
+	}
+
+	return GetSupplierInvoice(ctx, id)
+}
+
+// GetAgedPayables lists the outstanding balance owed to each supplier as of a
+// date, split by how long the invoices are past their due date: current (not
+// yet due), 1-30, 31-60, 61-90 and over 90 days. Payments and credit notes
+// dated after the as-of date are not taken into account.
+//
+//encore:api public method=GET path=/api/payables/aging
+func GetAgedPayables(ctx context.Context, params *AgedPayablesParams) (AgedPayablesResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return AgedPayablesResponse{Message: "Validation failed"}, err
+	}
+	asOf, _ := parseOptionalDate(params.AsOf)
+	if asOf == nil {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		asOf = &today
+	}
+
+	var supplierID *uuid.UUID
+	if params.SupplierID != uuid.Nil {
+		supplierID = &params.SupplierID
+	}
+
+	rows, err := db.Query(ctx, `
+		WITH balances AS (
+			SELECT
+				i.supplier_id,
+				$1::date - i.due_date as days_overdue,
+				i.amount
+					- COALESCE((SELECT SUM(amount) FROM supplier_payments WHERE invoice_id = i.id AND payment_date <= $1::date), 0)
+					- COALESCE((SELECT SUM(amount) FROM supplier_credit_notes WHERE invoice_id = i.id AND credit_date <= $1::date), 0)
+					as balance
+			FROM supplier_invoices i
+			WHERE i.invoice_date <= $1::date
+				AND ($2::uuid IS NULL OR i.supplier_id = $2)
+		)
+		SELECT
+			s.id,
+			s.name,
+			COUNT(*) as open_invoices,
+			COALESCE(SUM(b.balance) FILTER (WHERE b.days_overdue <= 0), 0) as current,
+			COALESCE(SUM(b.balance) FILTER (WHERE b.days_overdue BETWEEN 1 AND 30), 0) as days_1_30,
+			COALESCE(SUM(b.balance) FILTER (WHERE b.days_overdue BETWEEN 31 AND 60), 0) as days_31_60,
+			COALESCE(SUM(b.balance) FILTER (WHERE b.days_overdue BETWEEN 61 AND 90), 0) as days_61_90,
+			COALESCE(SUM(b.balance) FILTER (WHERE b.days_overdue > 90), 0) as days_over_90,
+			SUM(b.balance) as total
+		FROM balances b
+		JOIN suppliers s ON s.id = b.supplier_id
+		WHERE b.balance > 0
+		GROUP BY s.id, s.name
+		ORDER BY total DESC, s.name
+	`, *asOf, supplierID)
+	if err != nil {
+		return AgedPayablesResponse{
+			Message: "Failed to retrieve aged payables",
+			Data:    []AgedPayable{},
+		}, errors.New("failed to retrieve aged payables")
+	}
+	defer rows.Close()
+
+	var payables []AgedPayable
+	var totals AgedPayableBuckets
+	for rows.Next() {
+		var payable AgedPayable
+		err = rows.Scan(
+			&payable.SupplierID,
+			&payable.SupplierName,
+			&payable.OpenInvoices,
+			&payable.Current,
+			&payable.Days1To30,
+			&payable.Days31To60,
+			&payable.Days61To90,
+			&payable.DaysOver90,
+			&payable.Total,
+		)
+		if err != nil {
+			return AgedPayablesResponse{Message: "Failed to scan aged payable"}, errors.New("failed to scan aged payable")
+		}
+		totals.Current += payable.Current
+		totals.Days1To30 += payable.Days1To30
+		totals.Days31To60 += payable.Days31To60
+		totals.Days61To90 += payable.Days61To90
+		totals.DaysOver90 += payable.DaysOver90
+		totals.Total += payable.Total
+		payables = append(payables, payable)
+	}
+
+	if err = rows.Err(); err != nil {
+		return AgedPayablesResponse{Message: "Error iterating aged payables"}, errors.New("error iterating aged payables: " + err.Error())
+	}
+
+	return AgedPayablesResponse{
+		Message: "Aged payables retrieved successfully",
+		AsOf:    *asOf,
+		Data:    payables,
+		Totals:  totals,
+	}, nil
+}
+
+// lockSupplierInvoice locks an invoice for a payment or credit note and
+// returns its supplier and outstanding balance
+func lockSupplierInvoice(ctx context.Context, tx *sqldb.Tx, id uuid.UUID) (uuid.UUID, money.Amount, error) {
+	var supplierID uuid.UUID
+	var balance money.Amount
+	err := tx.QueryRow(ctx, `
+		SELECT supplier_id, amount - paid_amount - credited_amount
+		FROM supplier_invoices
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&supplierID, &balance)
+	if err != nil {
+		return uuid.Nil, 0, errors.New("supplier invoice not found")
+	}
+	if balance == 0 {
+		return uuid.Nil, 0, errors.New("supplier invoice is already paid")
+	}
+	return supplierID, balance, nil
+}
+
+// updateInvoiceStatus derives the status of an invoice from its balance
+func updateInvoiceStatus(ctx context.Context, tx *sqldb.Tx, id uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE supplier_invoices
+		SET status = CASE
+				WHEN paid_amount + credited_amount >= amount THEN $1
+				WHEN paid_amount + credited_amount > 0 THEN $2
+				ELSE $3
+			END,
+			updated_at = NOW()
+		WHERE id = $4
+	`, InvoiceStatusPaid, InvoiceStatusPartiallyPaid, InvoiceStatusOpen, id)
+	if err != nil {
+		return errors.New("failed to update invoice status: " + err.Error())
+	}
+	return nil
+}
+
+// supplierInvoiceByID loads the header of a supplier invoice
+func supplierInvoiceByID(ctx context.Context, id uuid.UUID) (*SupplierInvoice, error) {
+	var invoice SupplierInvoice
+	err := db.QueryRow(ctx, `
+		SELECT
+			id, supplier_id, purchase_id, invoice_number, invoice_date, payment_terms_days, due_date,
+			amount, paid_amount, credited_amount, amount - paid_amount - credited_amount,
+			status, COALESCE(notes, ''), created_by, created_at, updated_at
+		FROM supplier_invoices
+		WHERE id = $1
+	`, id).Scan(
+		&invoice.ID,
+		&invoice.SupplierID,
+		&invoice.PurchaseID,
+		&invoice.InvoiceNumber,
+		&invoice.InvoiceDate,
+		&invoice.PaymentTermsDays,
+		&invoice.DueDate,
+		&invoice.Amount,
+		&invoice.PaidAmount,
+		&invoice.CreditedAmount,
+		&invoice.Balance,
+		&invoice.Status,
+		&invoice.Notes,
+		&invoice.CreatedBy,
+		&invoice.CreatedAt,
+		&invoice.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.New("supplier invoice not found")
+	}
+	return &invoice, nil
+}
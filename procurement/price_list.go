@@ -0,0 +1,260 @@
+package procurement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/money"
+	"encore.app/product"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// GetSupplierPrices retrieves the price list of a supplier, optionally for a
+// single product or limited to the entries valid on a date
+//
+//encore:api public method=GET path=/api/suppliers/:id/prices
+func GetSupplierPrices(ctx context.Context, id uuid.UUID, params *GetSupplierPricesParams) (ListSupplierPricesResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return ListSupplierPricesResponse{Message: "Validation failed"}, err
+	}
+	activeOn, _ := parseOptionalDate(params.ActiveOn)
+
+	var productID *uuid.UUID
+	if params.ProductID != uuid.Nil {
+		productID = &params.ProductID
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT id, supplier_id, product_id, unit_cost, min_order_quantity, valid_from, valid_to, created_at, updated_at
+		FROM supplier_prices
+		WHERE supplier_id = $1
+			AND ($2::uuid IS NULL OR product_id = $2)
+			AND ($3::date IS NULL OR (valid_from <= $3 AND (valid_to IS NULL OR valid_to >= $3)))
+		ORDER BY product_id, min_order_quantity, valid_from
+	`, id, productID, activeOn)
+	if err != nil {
+		return ListSupplierPricesResponse{
+			Message: "Failed to retrieve supplier prices",
+			Data:    []SupplierPrice{},
+		}, errors.New("failed to retrieve supplier prices")
+	}
+	defer rows.Close()
+
+	var prices []SupplierPrice
+	for rows.Next() {
+		var price SupplierPrice
+		err = rows.Scan(
+			&price.ID,
+			&price.SupplierID,
+			&price.ProductID,
+			&price.UnitCost,
+			&price.MinOrderQuantity,
+			&price.ValidFrom,
+			&price.ValidTo,
+			&price.CreatedAt,
+			&price.UpdatedAt,
+		)
+		if err != nil {
+			return ListSupplierPricesResponse{Message: "Failed to scan supplier price"}, errors.New("failed to scan supplier price")
+		}
+		prices = append(prices, price)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListSupplierPricesResponse{Message: "Error iterating supplier prices"}, errors.New("error iterating supplier prices: " + err.Error())
+	}
+
+	return ListSupplierPricesResponse{
+		Message: "Supplier prices retrieved successfully",
+		Data:    prices,
+	}, nil
+}
+
+// CreateSupplierPrice adds a product to the price list of a supplier
+//
+//encore:api public method=POST path=/api/suppliers/:id/prices
+func CreateSupplierPrice(ctx context.Context, id uuid.UUID, req *SupplierPriceRequest) (SupplierPriceResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SupplierPriceResponse{Message: "Validation failed"}, err
+	}
+
+	// Check if product exists in product service
+	productData, err := product.GetProduct(ctx, req.ProductID)
+	if err != nil {
+		return SupplierPriceResponse{Message: "Product not found: " + req.ProductID.String()}, errors.New("product not found")
+	}
+	if !productData.IsActive {
+		return SupplierPriceResponse{Message: "Product is not active: " + productData.Name}, errors.New("product is not active")
+	}
+
+	return saveSupplierPrice(ctx, id, nil, req)
+}
+
+// UpdateSupplierPrice changes the cost, minimum order quantity or validity of
+// a price list entry
+//
+//encore:api public method=PUT path=/api/suppliers/:id/prices/:priceID
+func UpdateSupplierPrice(ctx context.Context, id uuid.UUID, priceID uuid.UUID, req *SupplierPriceRequest) (SupplierPriceResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SupplierPriceResponse{Message: "Validation failed"}, err
+	}
+
+	return saveSupplierPrice(ctx, id, &priceID, req)
+}
+
+// DeleteSupplierPrice removes an entry from the price list of a supplier.
+// Purchases keep the unit price they were created with.
+//
+//encore:api public method=DELETE path=/api/suppliers/:id/prices/:priceID
+func DeleteSupplierPrice(ctx context.Context, id uuid.UUID, priceID uuid.UUID) (Response, error) {
+	result, err := db.Exec(ctx, "DELETE FROM supplier_prices WHERE id = $1 AND supplier_id = $2", priceID, id)
+	if err != nil {
+		return Response{Message: "Failed to delete supplier price"}, err
+	}
+	if result.RowsAffected() == 0 {
+		return Response{Message: "Supplier price not found"}, errors.New("supplier price not found")
+	}
+
+	return Response{Message: "Supplier price deleted successfully"}, nil
+}
+
+// saveSupplierPrice inserts a price list entry, or updates it when priceID is
+// given. Entries for the same product and minimum order quantity must not
+// have overlapping validity periods, so exactly one price applies on any date.
+func saveSupplierPrice(ctx context.Context, supplierID uuid.UUID, priceID *uuid.UUID, req *SupplierPriceRequest) (SupplierPriceResponse, error) {
+	minOrderQuantity := req.MinOrderQuantity
+	if minOrderQuantity == 0 {
+		minOrderQuantity = 1
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return SupplierPriceResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the supplier so concurrent changes to its price list are checked one at a time
+	var supplierExists bool
+	err = tx.QueryRow(ctx, "SELECT true FROM suppliers WHERE id = $1 FOR UPDATE", supplierID).Scan(&supplierExists)
+	if err != nil {
+		return SupplierPriceResponse{Message: "Supplier not found"}, errors.New("supplier not found")
+	}
+
+	var overlaps bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM supplier_prices
+			WHERE supplier_id = $1
+				AND product_id = $2
+				AND min_order_quantity = $3
+				AND daterange(valid_from, valid_to, '[]') && daterange($4::date, $5::date, '[]')
+				AND ($6::uuid IS NULL OR id <> $6)
+		)
+	`, supplierID, req.ProductID, minOrderQuantity, req.ValidFrom, req.ValidTo, priceID).Scan(&overlaps)
+	if err != nil {
+		return SupplierPriceResponse{Message: "Failed to check supplier prices"}, err
+	}
+	if overlaps {
+		return SupplierPriceResponse{Message: "Supplier price overlaps an existing price"}, errors.New("another price for this product and minimum order quantity is valid in the same period")
+	}
+
+	var price SupplierPrice
+	scan := []interface{}{
+		&price.ID,
+		&price.SupplierID,
+		&price.ProductID,
+		&price.UnitCost,
+		&price.MinOrderQuantity,
+		&price.ValidFrom,
+		&price.ValidTo,
+		&price.CreatedAt,
+		&price.UpdatedAt,
+	}
+	if priceID == nil {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO supplier_prices (supplier_id, product_id, unit_cost, min_order_quantity, valid_from, valid_to)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, supplier_id, product_id, unit_cost, min_order_quantity, valid_from, valid_to, created_at, updated_at
+		`, supplierID, req.ProductID, req.UnitCost, minOrderQuantity, req.ValidFrom, req.ValidTo).Scan(scan...)
+		if err != nil {
+			return SupplierPriceResponse{Message: "Failed to create supplier price"}, err
+		}
+	} else {
+		err = tx.QueryRow(ctx, `
+			UPDATE supplier_prices
+			SET unit_cost = $1, min_order_quantity = $2, valid_from = $3, valid_to = $4, updated_at = NOW()
+			WHERE id = $5 AND supplier_id = $6 AND product_id = $7
+			RETURNING id, supplier_id, product_id, unit_cost, min_order_quantity, valid_from, valid_to, created_at, updated_at
+		`, req.UnitCost, minOrderQuantity, req.ValidFrom, req.ValidTo, *priceID, supplierID, req.ProductID).Scan(scan...)
+		if errors.Is(err, sqldb.ErrNoRows) {
+			return SupplierPriceResponse{Message: "Supplier price not found"}, errors.New("supplier price not found")
+		}
+		if err != nil {
+			return SupplierPriceResponse{Message: "Failed to update supplier price"}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return SupplierPriceResponse{Message: "Failed to commit supplier price"}, err
+	}
+
+	message := "Supplier price created successfully"
+	if priceID != nil {
+		message = "Supplier price updated successfully"
+	}
+	return SupplierPriceResponse{
+		Message: message,
+		Data:    &price,
+	}, nil
+}
+
+// errNoSupplierPrice is returned when a supplier does not list a product at all on a date
+var errNoSupplierPrice = errors.New("supplier has no price for product")
+
+// supplierUnitCost returns the unit cost of a product on the supplier's price
+// list valid on the given date. With volume prices the entry with the highest
+// minimum order quantity the ordered quantity reaches applies.
+func supplierUnitCost(ctx context.Context, supplierID, productID uuid.UUID, date time.Time, quantity int) (money.Amount, error) {
+	var unitCost money.Amount
+	err := db.QueryRow(ctx, `
+		SELECT unit_cost
+		FROM supplier_prices
+		WHERE supplier_id = $1
+			AND product_id = $2
+			AND valid_from <= $3::date
+			AND (valid_to IS NULL OR valid_to >= $3::date)
+			AND min_order_quantity <= $4
+		ORDER BY min_order_quantity DESC
+		LIMIT 1
+	`, supplierID, productID, date, quantity).Scan(&unitCost)
+	if err == nil {
+		return unitCost, nil
+	}
+	if !errors.Is(err, sqldb.ErrNoRows) {
+		return 0, errors.New("failed to look up supplier price: " + err.Error())
+	}
+
+	// Tell a quantity below the minimum apart from a product the supplier does not list
+	var minOrderQuantity *int
+	err = db.QueryRow(ctx, `
+		SELECT MIN(min_order_quantity)
+		FROM supplier_prices
+		WHERE supplier_id = $1
+			AND product_id = $2
+			AND valid_from <= $3::date
+			AND (valid_to IS NULL OR valid_to >= $3::date)
+	`, supplierID, productID, date).Scan(&minOrderQuantity)
+	if err != nil {
+		return 0, errors.New("failed to look up supplier price: " + err.Error())
+	}
+	if minOrderQuantity != nil {
+		return 0, fmt.Errorf("quantity of product %s is below the supplier's minimum order quantity of %d", productID, *minOrderQuantity)
+	}
+	return 0, fmt.Errorf("%w %s on %s, unit_price is required", errNoSupplierPrice, productID, date.Format("2006-01-02"))
+}
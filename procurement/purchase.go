@@ -3,146 +3,340 @@ package procurement
 import (
 	"context"
 	"errors"
-	"regexp"
-	"strings"
+	"fmt"
 	"time"
 
+	"encore.app/money"
+	"encore.app/pagination"
+	"encore.app/pricing"
 	"encore.app/product"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
 	"encore.dev/types/uuid"
 )
 
 // Purchase model
 type Purchase struct {
-	ID             uuid.UUID `json:"id"`
-	PurchaseNumber string    `json:"purchase_number"`
-	SupplierID     uuid.UUID `json:"supplier_id"`
-	PurchaseDate   time.Time `json:"purchase_date"`
-	TotalAmount    float64   `json:"total_amount"`
-	Status         string    `json:"status"`
-	Notes          string    `json:"notes"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	CreatedBy      uuid.UUID `json:"created_by"`
+	ID             uuid.UUID    `json:"id"`
+	PurchaseNumber string       `json:"purchase_number"`
+	SupplierID     uuid.UUID    `json:"supplier_id"`
+	PurchaseDate   time.Time    `json:"purchase_date"`
+	TotalAmount    money.Amount `json:"total_amount"`
+	Status         string       `json:"status"`
+	Notes          string       `json:"notes"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	CreatedBy      uuid.UUID    `json:"created_by"`
 }
 
 // PurchaseItem model
 type PurchaseItem struct {
-	ID         uuid.UUID `json:"id"`
-	PurchaseID uuid.UUID `json:"purchase_id"`
-	ProductID  uuid.UUID `json:"product_id"`
-	Quantity   int       `json:"quantity"`
-	TotalPrice float64   `json:"total_price"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID               uuid.UUID    `json:"id"`
+	PurchaseID       uuid.UUID    `json:"purchase_id"`
+	ProductID        uuid.UUID    `json:"product_id"`
+	Quantity         int          `json:"quantity"`
+	ReceivedQuantity int          `json:"received_quantity"`
+	UnitPrice        money.Amount `json:"unit_price"`
+	TotalPrice       money.Amount `json:"total_price"`
+	CreatedAt        time.Time    `json:"created_at"`
 }
 
-// CreatePurchase creates a new purchase order with items
+// CreatePurchase creates a new purchase order with items. Lines are priced from
+// the supplier's price list valid on the order date unless the request gives
+// a unit price. Clients may send an Idempotency-Key header; retrying a request
+// with the same key returns the purchase created by the first request instead
+// of failing. Purchases above the approval threshold stay submitted until a
+// manager approves them; the others are approved right away.
 //
 //encore:api public method=POST path=/api/purchases
-func CreatePurchase(ctx context.Context, req *CreatePurchaseRequest) (Response, error) {
+func CreatePurchase(ctx context.Context, req *CreatePurchaseRequest) (CreatePurchaseResponse, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
-		return Response{Message: "Validation failed"}, err
+		return CreatePurchaseResponse{Message: "Validation failed"}, err
 	}
 
-	// Check if supplier exists
-	var supplierExists bool
-	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM suppliers WHERE id = $1)", req.SupplierID).Scan(&supplierExists)
+	return createPurchase(ctx, req, PurchaseStatusSubmitted)
+}
+
+// createPurchase creates a purchase and its items in a single transaction
+// with the given status from a validated request
+func createPurchase(ctx context.Context, req *CreatePurchaseRequest, status string) (CreatePurchaseResponse, error) {
+	// Return the original purchase when the request is a retry
+	if req.IdempotencyKey != "" {
+		existing, err := purchaseByIdempotencyKey(ctx, req)
+		if err != nil {
+			return CreatePurchaseResponse{Message: "Failed to check idempotency key"}, err
+		}
+		if existing != nil {
+			return CreatePurchaseResponse{Message: "Purchase already created", Data: existing}, nil
+		}
+	}
+
+	// Check if supplier exists and is active
+	var supplierActive bool
+	err := db.QueryRow(ctx, "SELECT COALESCE(is_active, false) FROM suppliers WHERE id = $1", req.SupplierID).Scan(&supplierActive)
 	if err != nil {
-		return Response{Message: "Failed to check supplier"}, err
+		return CreatePurchaseResponse{Message: "Supplier not found"}, errors.New("supplier not found")
 	}
-	if !supplierExists {
-		return Response{Message: "Supplier not found"}, errors.New("supplier not found")
+	if !supplierActive {
+		return CreatePurchaseResponse{Message: "Supplier is inactive"}, errors.New("supplier is inactive")
 	}
 
 	// Check if purchase number already exists
 	var purchaseNumberExists bool
 	err = db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM purchases WHERE purchase_number = $1)", req.InvoiceNumber).Scan(&purchaseNumberExists)
 	if err != nil {
-		return Response{Message: "Failed to check purchase number"}, err
+		return CreatePurchaseResponse{Message: "Failed to check purchase number"}, err
 	}
 	if purchaseNumberExists {
-		return Response{Message: "Purchase number already exists"}, errors.New("purchase number already exists")
+		return CreatePurchaseResponse{Message: "Purchase number already exists"}, errors.New("purchase number already exists")
 	}
 
-	// Fetch product prices from product service and validate products exist
-	productPrices := make(map[uuid.UUID]float64)
-	var totalAmount float64
-	for _, item := range req.Items {
-		// Get product from product service to get base_price
+	// Validate products exist and price every line from the supplier's price
+	// list, unless the request overrides the unit price
+	lines := make([]pricing.Line, len(req.Items))
+	var categoryIDs []uuid.UUID
+	for i, item := range req.Items {
 		productData, err := product.GetProduct(ctx, item.ProductID)
 		if err != nil {
-			return Response{Message: "Product not found: " + item.ProductID.String()}, errors.New("product not found")
+			return CreatePurchaseResponse{Message: "Product not found: " + item.ProductID.String()}, errors.New("product not found")
+		}
+		if !productData.IsActive {
+			return CreatePurchaseResponse{Message: "Product is not active: " + productData.Name}, errors.New("product is not active")
 		}
+		categoryIDs = append(categoryIDs, productData.CategoryID)
 
-		// Store product price for later use
-		productPrices[item.ProductID] = productData.BasePrice
+		lines[i] = pricing.Line{Quantity: item.Quantity, DiscountBasisPoints: item.DiscountBasisPoints}
+		if item.UnitPrice != nil {
+			lines[i].UnitPrice = *item.UnitPrice
+		} else {
+			lines[i].UnitPrice, err = supplierUnitCost(ctx, req.SupplierID, item.ProductID, req.OrderDate, item.Quantity)
+			if err != nil {
+				return CreatePurchaseResponse{Message: "No supplier price for product: " + productData.Name}, err
+			}
+		}
+	}
 
-		// Calculate item total using product's base_price
-		itemTotal := float64(item.Quantity) * productData.BasePrice
-		totalAmount += itemTotal
+	// Apply discounts and tax
+	taxRate, err := purchaseTaxRate(ctx, req.TaxRateID)
+	if err != nil {
+		return CreatePurchaseResponse{Message: "Invalid tax rate"}, err
+	}
+	totals, err := pricing.Calculate(lines, req.DiscountBasisPoints, req.DiscountAmount, taxRate.RateBasisPoints, req.PricesIncludeTax)
+	if err != nil {
+		return CreatePurchaseResponse{Message: "Validation failed"}, err
 	}
 
-	// Build notes with expected delivery if provided
-	notes := req.Notes
+	var expectedDelivery *time.Time
 	if !req.ExpectedDelivery.IsZero() {
-		if notes != "" {
-			notes += "\n"
-		}
-		notes += "Expected delivery: " + req.ExpectedDelivery.Format("2006-01-02")
+		expectedDelivery = &req.ExpectedDelivery
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return CreatePurchaseResponse{Message: "Failed to start transaction"}, err
 	}
+	defer tx.Rollback()
 
 	// Create purchase
-	var purchaseID uuid.UUID
-	err = db.QueryRow(ctx, `
-		INSERT INTO purchases (purchase_number, supplier_id, purchase_date, total_amount, status, notes, created_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id
-	`, req.InvoiceNumber, req.SupplierID, req.OrderDate, totalAmount, "pending", notes, req.CreatedBy).Scan(&purchaseID)
-	if err != nil {
-		return Response{Message: "Failed to create purchase"}, err
-	}
-
-	// Create purchase items using cached product prices
-	for _, item := range req.Items {
-		// Get cached product price
-		unitPrice := productPrices[item.ProductID]
-
-		// Auto-calculate total_price from quantity * product base_price
-		totalPrice := float64(item.Quantity) * unitPrice
-		_, err = db.Exec(ctx, `
-			INSERT INTO purchase_items (purchase_id, product_id, quantity, total_price)
-			VALUES ($1, $2, $3, $4)
-		`, purchaseID, item.ProductID, item.Quantity, totalPrice)
+	var idempotencyKey *string
+	if req.IdempotencyKey != "" {
+		idempotencyKey = &req.IdempotencyKey
+	}
+	created := CreatedPurchase{
+		PurchaseNumber:   req.InvoiceNumber,
+		SupplierID:       req.SupplierID,
+		OrderDate:        req.OrderDate,
+		ExpectedDelivery: expectedDelivery,
+		Subtotal:         totals.Subtotal,
+		DiscountAmount:   totals.Discount,
+		TaxBase:          totals.TaxBase,
+		TaxAmount:        totals.Tax,
+		TotalAmount:      totals.Total,
+		Status:           status,
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO purchases (
+			purchase_number, supplier_id, purchase_date, expected_delivery,
+			subtotal, discount_basis_points, discount_amount, tax_base, tax_amount, total_amount,
+			tax_rate_id, tax_rate_basis_points, prices_include_tax,
+			status, notes, created_by, idempotency_key
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING id, created_at
+	`,
+		req.InvoiceNumber, req.SupplierID, req.OrderDate, expectedDelivery,
+		totals.Subtotal, req.DiscountBasisPoints, totals.Discount, totals.TaxBase, totals.Tax, totals.Total,
+		taxRate.ID, taxRate.RateBasisPoints, req.PricesIncludeTax,
+		status, req.Notes, req.CreatedBy, idempotencyKey,
+	).Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		if sqldb.ErrCode(err) != sqlerr.UniqueViolation {
+			return CreatePurchaseResponse{Message: "Failed to create purchase"}, err
+		}
+		// A concurrent request may have won the race with the same key or purchase number
+		tx.Rollback()
+		if req.IdempotencyKey != "" {
+			existing, err := purchaseByIdempotencyKey(ctx, req)
+			if err != nil {
+				return CreatePurchaseResponse{Message: "Failed to check idempotency key"}, err
+			}
+			if existing != nil {
+				return CreatePurchaseResponse{Message: "Purchase already created", Data: existing}, nil
+			}
+		}
+		return CreatePurchaseResponse{Message: "Purchase number already exists"}, errors.New("purchase number already exists")
+	}
+
+	// Create purchase items with the resolved unit prices and line discounts
+	for i, item := range req.Items {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO purchase_items (purchase_id, product_id, quantity, unit_price, discount_basis_points, discount_amount, total_price)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, created.ID, item.ProductID, item.Quantity, lines[i].UnitPrice, lines[i].DiscountBasisPoints, lines[i].Discount, lines[i].Total)
+		if err != nil {
+			return CreatePurchaseResponse{Message: "Failed to create purchase item"}, err
+		}
+	}
+
+	if err = recordStatusChange(ctx, tx, created.ID, nil, status, "Purchase created", req.CreatedBy); err != nil {
+		return CreatePurchaseResponse{Message: "Failed to record purchase status"}, err
+	}
+
+	// Submitted purchases above the approval threshold wait for a manager
+	if status == PurchaseStatusSubmitted {
+		created.Status, err = routeForApproval(ctx, tx, created.ID, req.SupplierID, categoryIDs, totals.Total, req.CreatedBy)
 		if err != nil {
-			// If item creation fails, try to clean up the purchase
-			db.Exec(ctx, "DELETE FROM purchases WHERE id = $1", purchaseID)
-			return Response{Message: "Failed to create purchase item"}, err
+			return CreatePurchaseResponse{Message: "Failed to route purchase for approval"}, err
 		}
 	}
 
-	return Response{Message: "Purchase created successfully"}, nil
+	if err = tx.Commit(); err != nil {
+		return CreatePurchaseResponse{Message: "Failed to commit purchase"}, err
+	}
+
+	return CreatePurchaseResponse{
+		Message: "Purchase created successfully",
+		Data:    &created,
+	}, nil
+}
+
+// purchaseByIdempotencyKey looks up the purchase created earlier with the
+// request's idempotency key. It returns nil when the key has not been used and
+// an error when it was used for a different purchase.
+func purchaseByIdempotencyKey(ctx context.Context, req *CreatePurchaseRequest) (*CreatedPurchase, error) {
+	var purchase CreatedPurchase
+	err := db.QueryRow(ctx, `
+		SELECT id, purchase_number, supplier_id, purchase_date, expected_delivery, subtotal, discount_amount, tax_base, tax_amount, total_amount, status, created_at
+		FROM purchases
+		WHERE idempotency_key = $1
+	`, req.IdempotencyKey).Scan(
+		&purchase.ID,
+		&purchase.PurchaseNumber,
+		&purchase.SupplierID,
+		&purchase.OrderDate,
+		&purchase.ExpectedDelivery,
+		&purchase.Subtotal,
+		&purchase.DiscountAmount,
+		&purchase.TaxBase,
+		&purchase.TaxAmount,
+		&purchase.TotalAmount,
+		&purchase.Status,
+		&purchase.CreatedAt,
+	)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if purchase.PurchaseNumber != req.InvoiceNumber || purchase.SupplierID != req.SupplierID {
+		return nil, errors.New("idempotency key was already used for a different purchase")
+	}
+	return &purchase, nil
 }
 
-// GetAllPurchases retrieves all purchases with supplier information and item counts
+// GetAllPurchases retrieves a page of purchases with supplier information,
+// item counts and outstanding quantities. Purchases can be searched by
+// purchase number, supplier name or notes and filtered by status, supplier
+// and purchase date range.
 //
 //encore:api public method=GET path=/api/purchases
-func GetAllPurchases(ctx context.Context) (ListPurchasesResponse, error) {
-	rows, err := db.Query(ctx, `
-		SELECT 
-			p.id,
-			p.purchase_number,
-			s.name as supplier_name,
-			p.purchase_date,
-			p.total_amount,
-			p.status,
-			p.notes,
-			COALESCE(COUNT(pi.id), 0) as total_item
-		FROM purchases p
-		LEFT JOIN suppliers s ON p.supplier_id = s.id
-		LEFT JOIN purchase_items pi ON p.id = pi.purchase_id
-		GROUP BY p.id, p.purchase_number, s.name, p.purchase_date, p.total_amount, p.status, p.notes
-		ORDER BY p.purchase_date DESC
-	`)
+func GetAllPurchases(ctx context.Context, params *GetAllPurchasesParams) (ListPurchasesResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return ListPurchasesResponse{Message: "Validation failed"}, err
+	}
+	cursor, _ := pagination.Decode(params.Cursor)
+	sort, _ := pagination.ParseSort(params.Sort, purchaseSortColumns, "-purchase_date")
+	dateFrom, _ := params.dateFrom()
+	dateTo, _ := params.dateTo()
+	limit := pagination.Limit(params.Limit)
+
+	var supplierID *uuid.UUID
+	if params.SupplierID != uuid.Nil {
+		supplierID = &params.SupplierID
+	}
+	filterArgs := []interface{}{pagination.SearchPattern(params.Search), optionalText(params.Status), supplierID, dateFrom, dateTo}
+
+	base := `
+		WITH purchase_list AS (
+			SELECT 
+				p.id,
+				p.purchase_number,
+				s.name as supplier_name,
+				p.purchase_date,
+				p.expected_delivery,
+				p.subtotal,
+				p.discount_amount,
+				p.tax_amount,
+				p.total_amount,
+				p.status,
+				p.created_at,
+				COALESCE(COUNT(pi.id), 0) as total_item,
+				COALESCE(SUM(pi.quantity), 0) as ordered_quantity,
+				COALESCE(SUM(pi.received_quantity), 0) as received_quantity
+			FROM purchases p
+			LEFT JOIN suppliers s ON p.supplier_id = s.id
+			LEFT JOIN purchase_items pi ON p.id = pi.purchase_id
+			WHERE ($1::text IS NULL OR p.purchase_number ILIKE $1 OR s.name ILIKE $1 OR p.notes ILIKE $1)
+				AND ($2::text IS NULL OR p.status = $2)
+				AND ($3::uuid IS NULL OR p.supplier_id = $3)
+				AND ($4::date IS NULL OR p.purchase_date >= $4)
+				AND ($5::date IS NULL OR p.purchase_date <= $5)
+			GROUP BY p.id, p.purchase_number, s.name, p.purchase_date, p.expected_delivery, p.subtotal, p.discount_amount, p.tax_amount, p.total_amount, p.status
+		)
+	`
+
+	var total int
+	err := db.QueryRow(ctx, base+"SELECT COUNT(*) FROM purchase_list", filterArgs...).Scan(&total)
+	if err != nil {
+		return ListPurchasesResponse{Message: "Failed to count purchases"}, errors.New("failed to count purchases")
+	}
+
+	cursorValue, cursorID := pagination.Args(cursor)
+	query := base + fmt.Sprintf(`
+		SELECT
+			id,
+			purchase_number,
+			supplier_name,
+			purchase_date,
+			expected_delivery,
+			subtotal,
+			discount_amount,
+			tax_amount,
+			total_amount,
+			status,
+			total_item,
+			ordered_quantity,
+			received_quantity,
+			(%s)::text as sort_value
+		FROM purchase_list
+		WHERE %s
+		ORDER BY %s
+		LIMIT $8
+	`, sort.Column.Expr, sort.After("id", 6, 7), sort.OrderBy("id"))
+	rows, err := db.Query(ctx, query, append(filterArgs, cursorValue, cursorID, limit+1)...)
 	if err != nil {
 		return ListPurchasesResponse{
 			Message: "Failed to retrieve purchases",
@@ -151,68 +345,170 @@ func GetAllPurchases(ctx context.Context) (ListPurchasesResponse, error) {
 	}
 	defer rows.Close()
 
+	var sortValues []string
 	var purchases []PurchaseListItem
 	for rows.Next() {
 		var purchase PurchaseListItem
-		var notes string
+		var sortValue string
 		err = rows.Scan(
 			&purchase.ID,
 			&purchase.Invoice,
 			&purchase.Supplier,
 			&purchase.OrderDate,
+			&purchase.ExpectedDelivery,
+			&purchase.Subtotal,
+			&purchase.Discount,
+			&purchase.Tax,
 			&purchase.Total,
 			&purchase.Status,
-			&notes,
 			&purchase.TotalItem,
+			&purchase.OrderedQuantity,
+			&purchase.ReceivedQuantity,
+			&sortValue,
 		)
 		if err != nil {
 			return ListPurchasesResponse{Message: "Failed to scan purchase"}, errors.New("failed to scan purchase")
 		}
 
-		// Parse expected delivery from notes
-		expectedDelivery := parseExpectedDelivery(notes)
-		if expectedDelivery != nil {
-			purchase.ExpectedDelivery = expectedDelivery
-		}
+		purchase.OutstandingQuantity = purchase.OrderedQuantity - purchase.ReceivedQuantity
 
 		purchases = append(purchases, purchase)
+		sortValues = append(sortValues, sortValue)
 	}
 
 	if err = rows.Err(); err != nil {
 		return ListPurchasesResponse{Message: "Error iterating purchases"}, errors.New("error iterating purchases: " + err.Error())
 	}
 
+	// The extra row only tells whether there is a next page
+	var nextCursor string
+	if len(purchases) > limit {
+		purchases = purchases[:limit]
+		nextCursor = pagination.Encode(sortValues[limit-1], purchases[limit-1].ID)
+	}
+
 	return ListPurchasesResponse{
-		Message: "Purchases retrieved successfully",
-		Data:    purchases,
+		Message:    "Purchases retrieved successfully",
+		Data:       purchases,
+		Total:      total,
+		NextCursor: nextCursor,
 	}, nil
 }
 
-// parseExpectedDelivery extracts the expected delivery date from notes
-// Format: "Expected delivery: YYYY-MM-DD"
-func parseExpectedDelivery(notes string) *time.Time {
-	if notes == "" {
-		return nil
+// GetPurchase retrieves a purchase with its supplier, line items and status
+// history. Product names and barcodes are resolved through the product service.
+//
+//encore:api public method=GET path=/api/purchases/:id
+func GetPurchase(ctx context.Context, id uuid.UUID) (PurchaseDetailResponse, error) {
+	var purchase PurchaseDetail
+	var supplierID uuid.UUID
+	err := db.QueryRow(ctx, `
+		SELECT
+			p.id, p.purchase_number, p.supplier_id, p.purchase_date, p.expected_delivery,
+			p.subtotal, p.discount_basis_points, p.discount_amount, p.tax_base, p.tax_amount, p.total_amount,
+			COALESCE(t.code, ''), p.tax_rate_basis_points, p.prices_include_tax,
+			p.status, COALESCE(p.notes, ''), p.approval_threshold, p.created_by, p.created_at, p.updated_at
+		FROM purchases p
+		LEFT JOIN tax_rates t ON p.tax_rate_id = t.id
+		WHERE p.id = $1
+	`, id).Scan(
+		&purchase.ID,
+		&purchase.PurchaseNumber,
+		&supplierID,
+		&purchase.OrderDate,
+		&purchase.ExpectedDelivery,
+		&purchase.Subtotal,
+		&purchase.DiscountBasisPoints,
+		&purchase.DiscountAmount,
+		&purchase.TaxBase,
+		&purchase.TaxAmount,
+		&purchase.TotalAmount,
+		&purchase.TaxRateCode,
+		&purchase.TaxRateBasisPoints,
+		&purchase.PricesIncludeTax,
+		&purchase.Status,
+		&purchase.Notes,
+		&purchase.ApprovalThreshold,
+		&purchase.CreatedBy,
+		&purchase.CreatedAt,
+		&purchase.UpdatedAt,
+	)
+	if err != nil {
+		return PurchaseDetailResponse{Message: "Purchase not found"}, errors.New("purchase not found")
 	}
 
-	// Look for "Expected delivery: YYYY-MM-DD" pattern
-	re := regexp.MustCompile(`Expected delivery:\s*(\d{4}-\d{2}-\d{2})`)
-	matches := re.FindStringSubmatch(notes)
-	if len(matches) < 2 {
-		return nil
+	supplier, err := GetSupplier(ctx, supplierID)
+	if err != nil {
+		return PurchaseDetailResponse{Message: "Supplier not found"}, err
 	}
+	purchase.Supplier = supplier.Data
 
-	// Try to parse the date
-	dateStr := strings.TrimSpace(matches[1])
-	parsedDate, err := time.Parse("2006-01-02", dateStr)
+	rows, err := db.Query(ctx, `
+		SELECT id, product_id, quantity, received_quantity, unit_price, discount_basis_points, discount_amount, total_price
+		FROM purchase_items
+		WHERE purchase_id = $1
+		ORDER BY created_at, id
+	`, id)
 	if err != nil {
-		return nil
+		return PurchaseDetailResponse{Message: "Failed to retrieve purchase items"}, errors.New("failed to retrieve purchase items")
 	}
+	defer rows.Close()
 
-	return &parsedDate
+	for rows.Next() {
+		var item PurchaseDetailItem
+		err = rows.Scan(
+			&item.ID,
+			&item.ProductID,
+			&item.Quantity,
+			&item.ReceivedQuantity,
+			&item.UnitPrice,
+			&item.DiscountBasisPoints,
+			&item.DiscountAmount,
+			&item.TotalPrice,
+		)
+		if err != nil {
+			return PurchaseDetailResponse{Message: "Failed to scan purchase item"}, errors.New("failed to scan purchase item")
+		}
+		item.OutstandingQuantity = item.Quantity - item.ReceivedQuantity
+		purchase.Items = append(purchase.Items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return PurchaseDetailResponse{Message: "Error iterating purchase items"}, errors.New("error iterating purchase items: " + err.Error())
+	}
+	rows.Close()
+
+	// Resolve product names and barcodes, once per product
+	products := make(map[uuid.UUID]*product.Product)
+	for i := range purchase.Items {
+		item := &purchase.Items[i]
+		productData, ok := products[item.ProductID]
+		if !ok {
+			productData, err = product.GetProduct(ctx, item.ProductID)
+			if err != nil {
+				return PurchaseDetailResponse{Message: "Product not found: " + item.ProductID.String()}, errors.New("product not found")
+			}
+			products[item.ProductID] = productData
+		}
+		item.ProductName = productData.Name
+		item.Barcode = productData.Barcode
+	}
+
+	purchase.History, err = purchaseHistory(ctx, id)
+	if err != nil {
+		return PurchaseDetailResponse{Message: "Failed to retrieve purchase history"}, err
+	}
+
+	return PurchaseDetailResponse{
+		Message: "Purchase retrieved successfully",
+		Data:    &purchase,
+	}, nil
 }
 
-// UpdatePurchaseStatus updates the status of a purchase
+// UpdatePurchaseStatus moves a purchase to a new status. Only the allowed
+// transitions are accepted and each change is recorded in the purchase history.
+// Submitting a draft approves it right away unless it exceeds the approval
+// threshold.
 //
 //encore:api public method=PUT path=/api/purchases/:id/status
 func UpdatePurchaseStatus(ctx context.Context, id uuid.UUID, req *UpdatePurchaseStatusRequest) (Response, error) {
@@ -221,31 +517,71 @@ func UpdatePurchaseStatus(ctx context.Context, id uuid.UUID, req *UpdatePurchase
 		return Response{Message: "Validation failed"}, err
 	}
 
-	// Check if purchase exists
-	var purchaseExists bool
-	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM purchases WHERE id = $1)", id).Scan(&purchaseExists)
+	// Categories decide the approval threshold of a submitted draft
+	var categoryIDs []uuid.UUID
+	if req.Status == PurchaseStatusSubmitted {
+		var err error
+		categoryIDs, err = purchaseCategoryIDs(ctx, id)
+		if err != nil {
+			return Response{Message: "Failed to retrieve purchase products"}, err
+		}
+	}
+
+	tx, err := db.Begin(ctx)
 	if err != nil {
-		return Response{Message: "Failed to check purchase"}, err
+		return Response{Message: "Failed to start transaction"}, err
 	}
-	if !purchaseExists {
+	defer tx.Rollback()
+
+	// Lock the purchase so concurrent changes see each other's status
+	var status string
+	var supplierID uuid.UUID
+	var totalAmount money.Amount
+	err = tx.QueryRow(ctx, "SELECT status, supplier_id, total_amount FROM purchases WHERE id = $1 FOR UPDATE", id).Scan(&status, &supplierID, &totalAmount)
+	if err != nil {
 		return Response{Message: "Purchase not found"}, errors.New("purchase not found")
 	}
+	if status == req.Status {
+		return Response{Message: "Purchase already has status " + status}, errors.New("purchase already has status " + status)
+	}
 
-	// Update purchase status
-	result, err := db.Exec(ctx, `
-		UPDATE purchases 
-		SET status = $1, updated_at = NOW()
-		WHERE id = $2
-	`, req.Status, id)
-	if err != nil {
-		return Response{Message: "Failed to update purchase status"}, err
+	if err = changePurchaseStatus(ctx, tx, id, status, req.Status, req.Reason, req.ChangedBy); err != nil {
+		return Response{Message: "Invalid status transition"}, err
 	}
 
-	// Check if any rows were affected
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return Response{Message: "Purchase not found"}, errors.New("purchase not found")
+	newStatus := req.Status
+	if req.Status == PurchaseStatusSubmitted {
+		newStatus, err = routeForApproval(ctx, tx, id, supplierID, categoryIDs, totalAmount, req.ChangedBy)
+		if err != nil {
+			return Response{Message: "Failed to route purchase for approval"}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Response{Message: "Failed to commit purchase status"}, err
 	}
 
+	if newStatus == PurchaseStatusSubmitted {
+		return Response{Message: "Purchase submitted and awaiting approval"}, nil
+	}
 	return Response{Message: "Purchase status updated successfully"}, nil
 }
+
+// CountProductReferences counts the purchase items and supplier price list
+// entries that name a product, so the product is not deleted while
+// procurement still refers to it
+//
+//encore:api private method=GET path=/internal/procurement/products/:id/references
+func CountProductReferences(ctx context.Context, id uuid.UUID) (ProductReferencesResponse, error) {
+	var references ProductReferencesResponse
+	err := db.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM purchase_items WHERE product_id = $1),
+			(SELECT COUNT(*) FROM supplier_prices WHERE product_id = $1)
+	`, id).Scan(&references.PurchaseItems, &references.SupplierPrices)
+	if err != nil {
+		return ProductReferencesResponse{Message: "Failed to count product references"}, err
+	}
+	references.Message = "Product references counted successfully"
+	return references, nil
+}
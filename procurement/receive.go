@@ -0,0 +1,377 @@
+package procurement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/money"
+	"encore.app/pricing"
+	"encore.app/product"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// ReceivePurchase records a delivery against a purchase. A purchase can be
+// received in several deliveries: each receipt turns the delivered quantities
+// into product batches, and the purchase is completed once every item has
+// been received in full. The receipt is committed before its batches are
+// created in the product service; a receipt that could not be stocked is
+// retried with StockPurchaseReceipt.
+//
+//encore:api public method=POST path=/api/purchases/:id/receive
+func ReceivePurchase(ctx context.Context, id uuid.UUID, req *ReceivePurchaseRequest) (ReceivePurchaseResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return ReceivePurchaseResponse{Message: "Validation failed"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return ReceivePurchaseResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the purchase so concurrent deliveries are applied one at a time
+	var status string
+	var discountAmount money.Amount
+	var taxBasisPoints int64
+	var pricesIncludeTax bool
+	err = tx.QueryRow(ctx, `
+		SELECT status, discount_amount, tax_rate_basis_points, prices_include_tax
+		FROM purchases
+		WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&status, &discountAmount, &taxBasisPoints, &pricesIncludeTax)
+	if err != nil {
+		return ReceivePurchaseResponse{Message: "Purchase not found"}, errors.New("purchase not found")
+	}
+	if status == PurchaseStatusSubmitted {
+		return ReceivePurchaseResponse{Message: "Purchase is awaiting approval"}, errors.New("purchase is awaiting approval and cannot be received")
+	}
+	if status != PurchaseStatusApproved && status != PurchaseStatusPartiallyReceived {
+		return ReceivePurchaseResponse{Message: "Purchase cannot be received"}, errors.New("purchase cannot be received, current status: " + status)
+	}
+
+	// Load the ordered items of the purchase
+	rows, err := tx.Query(ctx, `
+		SELECT id, product_id, quantity, received_quantity, unit_price, discount_amount, total_price
+		FROM purchase_items
+		WHERE purchase_id = $1
+	`, id)
+	if err != nil {
+		return ReceivePurchaseResponse{Message: "Failed to retrieve purchase items"}, errors.New("failed to retrieve purchase items")
+	}
+	defer rows.Close()
+
+	// purchases.discount_amount covers the line discounts and the invoice discount
+	orderedItems := make(map[uuid.UUID]PurchaseItem)
+	var net, lineDiscounts money.Amount
+	for rows.Next() {
+		var item PurchaseItem
+		var lineDiscount money.Amount
+		if err = rows.Scan(&item.ID, &item.ProductID, &item.Quantity, &item.ReceivedQuantity, &item.UnitPrice, &lineDiscount, &item.TotalPrice); err != nil {
+			return ReceivePurchaseResponse{Message: "Failed to scan purchase item"}, errors.New("failed to scan purchase item")
+		}
+		orderedItems[item.ID] = item
+		net += item.TotalPrice
+		lineDiscounts += lineDiscount
+	}
+	if err = rows.Err(); err != nil {
+		return ReceivePurchaseResponse{Message: "Error iterating purchase items"}, errors.New("error iterating purchase items: " + err.Error())
+	}
+	rows.Close()
+	discounted := net - (discountAmount - lineDiscounts)
+
+	batches := make([]product.ReceiveBatchItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		ordered, ok := orderedItems[item.PurchaseItemID]
+		if !ok {
+			return ReceivePurchaseResponse{Message: "Purchase item not found: " + item.PurchaseItemID.String()}, errors.New("purchase item not found")
+		}
+		outstanding := ordered.Quantity - ordered.ReceivedQuantity
+		if item.ReceivedQuantity > outstanding {
+			return ReceivePurchaseResponse{Message: "Validation failed"}, fmt.Errorf("received_quantity exceeds outstanding quantity %d for purchase item %s", outstanding, ordered.ID)
+		}
+
+		// Batches are costed at the ordered line after discounts, without tax
+		batches = append(batches, product.ReceiveBatchItem{
+			ProductID:      ordered.ProductID,
+			BatchNumber:    item.BatchNumber,
+			Quantity:       item.ReceivedQuantity,
+			PurchasePrice:  pricing.UnitCost(ordered.TotalPrice, ordered.Quantity, net, discounted, taxBasisPoints, pricesIncludeTax),
+			SellingPrice:   item.SellingPrice,
+			ExpirationDate: item.ExpirationDate,
+		})
+
+		ordered.ReceivedQuantity += item.ReceivedQuantity
+		orderedItems[item.PurchaseItemID] = ordered
+	}
+
+	// Record the receipt event
+	receivedAt := req.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	var receiptID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO purchase_receipts (purchase_id, delivery_note_number, received_at, received_by, notes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, id, req.DeliveryNoteNumber, receivedAt, req.ReceivedBy, req.Notes).Scan(&receiptID)
+	if err != nil {
+		return ReceivePurchaseResponse{Message: "Failed to create purchase receipt"}, err
+	}
+
+	for i, item := range req.Items {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO purchase_receipt_items (receipt_id, purchase_item_id, line_number, quantity, batch_number, expiration_date, purchase_price, selling_price)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, receiptID, item.PurchaseItemID, i, item.ReceivedQuantity, item.BatchNumber, item.ExpirationDate, batches[i].PurchasePrice, item.SellingPrice)
+		if err != nil {
+			return ReceivePurchaseResponse{Message: "Failed to create purchase receipt item"}, err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE purchase_items
+			SET received_quantity = received_quantity + $1
+			WHERE id = $2
+		`, item.ReceivedQuantity, item.PurchaseItemID)
+		if err != nil {
+			return ReceivePurchaseResponse{Message: "Failed to update received quantity"}, err
+		}
+	}
+
+	// The purchase is received on its own once every item is fully received
+	newStatus := PurchaseStatusReceived
+	for _, item := range orderedItems {
+		if item.ReceivedQuantity < item.Quantity {
+			newStatus = PurchaseStatusPartiallyReceived
+			break
+		}
+	}
+	err = changePurchaseStatus(ctx, tx, id, status, newStatus, "Goods received, delivery note "+req.DeliveryNoteNumber, req.ReceivedBy)
+	if err != nil {
+		return ReceivePurchaseResponse{Message: "Failed to update purchase status"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ReceivePurchaseResponse{Message: "Failed to commit purchase receipt"}, err
+	}
+
+	// Create the batches in the product service
+	batchIDs, err := stockReceipt(ctx, id, receiptID)
+	if err != nil {
+		return ReceivePurchaseResponse{
+			Message:   "Goods received but not stocked, retry stocking the receipt",
+			ReceiptID: &receiptID,
+			Status:    newStatus,
+		}, err
+	}
+
+	return ReceivePurchaseResponse{
+		Message:   "Purchase received successfully",
+		ReceiptID: &receiptID,
+		Status:    newStatus,
+		BatchIDs:  batchIDs,
+	}, nil
+}
+
+// StockPurchaseReceipt creates the batches of a receipt whose goods were
+// recorded but not stocked, e.g. because the product service could not be
+// reached. Stocking a receipt again returns the batches it already has.
+//
+//encore:api public method=POST path=/api/purchases/:id/receipts/:receiptID/stock
+func StockPurchaseReceipt(ctx context.Context, id uuid.UUID, receiptID uuid.UUID) (ReceivePurchaseResponse, error) {
+	batchIDs, err := stockReceipt(ctx, id, receiptID)
+	if err != nil {
+		return ReceivePurchaseResponse{Message: "Failed to stock purchase receipt", ReceiptID: &receiptID}, err
+	}
+
+	return ReceivePurchaseResponse{
+		Message:   "Purchase receipt stocked successfully",
+		ReceiptID: &receiptID,
+		BatchIDs:  batchIDs,
+	}, nil
+}
+
+// stockReceipt creates the batches of a recorded receipt in the product
+// service and links each receipt line to its batch. The product service
+// creates the batches of a receipt only once, so it is safe to call again
+// after any failure.
+func stockReceipt(ctx context.Context, purchaseID, receiptID uuid.UUID) ([]uuid.UUID, error) {
+	var supplierID, receivedBy uuid.UUID
+	var stockedAt *time.Time
+	err := db.QueryRow(ctx, `
+		SELECT p.supplier_id, r.received_by, r.stocked_at
+		FROM purchase_receipts r
+		JOIN purchases p ON p.id = r.purchase_id
+		WHERE r.id = $1 AND r.purchase_id = $2
+	`, receiptID, purchaseID).Scan(&supplierID, &receivedBy, &stockedAt)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		return nil, errors.New("purchase receipt not found")
+	}
+	if err != nil {
+		return nil, errors.New("failed to retrieve purchase receipt: " + err.Error())
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT ri.id, pi.product_id, ri.quantity, ri.batch_number, ri.expiration_date, ri.purchase_price, ri.selling_price, ri.batch_id
+		FROM purchase_receipt_items ri
+		JOIN purchase_items pi ON pi.id = ri.purchase_item_id
+		WHERE ri.receipt_id = $1
+		ORDER BY ri.line_number
+	`, receiptID)
+	if err != nil {
+		return nil, errors.New("failed to retrieve purchase receipt items: " + err.Error())
+	}
+	defer rows.Close()
+
+	var itemIDs, batchIDs []uuid.UUID
+	var items []product.ReceiveBatchItem
+	for rows.Next() {
+		var itemID uuid.UUID
+		var item product.ReceiveBatchItem
+		var batchID *uuid.UUID
+		err = rows.Scan(&itemID, &item.ProductID, &item.Quantity, &item.BatchNumber, &item.ExpirationDate, &item.PurchasePrice, &item.SellingPrice, &batchID)
+		if err != nil {
+			return nil, errors.New("failed to scan purchase receipt item: " + err.Error())
+		}
+		itemIDs = append(itemIDs, itemID)
+		items = append(items, item)
+		if batchID != nil {
+			batchIDs = append(batchIDs, *batchID)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("error iterating purchase receipt items: " + err.Error())
+	}
+	rows.Close()
+
+	if stockedAt != nil {
+		return batchIDs, nil
+	}
+
+	received, err := product.ReceiveBatches(ctx, &product.ReceiveBatchesRequest{
+		PurchaseID: purchaseID,
+		SupplierID: supplierID,
+		ReceiptID:  receiptID,
+		ReceivedBy: receivedBy,
+		Items:      items,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("goods of receipt %s are recorded but not stocked: %v", receiptID, err)
+	}
+
+	if err = linkReceiptBatches(ctx, receiptID, itemIDs, received.BatchIDs); err != nil {
+		return nil, fmt.Errorf("batches %v of receipt %s are stocked but not linked to the receipt: %v", received.BatchIDs, receiptID, err)
+	}
+	return received.BatchIDs, nil
+}
+
+// linkReceiptBatches links each receipt line to the batch it created and
+// marks the receipt as stocked
+func linkReceiptBatches(ctx context.Context, receiptID uuid.UUID, itemIDs, batchIDs []uuid.UUID) error {
+	if len(batchIDs) != len(itemIDs) {
+		return fmt.Errorf("got %d batches for %d receipt lines", len(batchIDs), len(itemIDs))
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, itemID := range itemIDs {
+		if _, err = tx.Exec(ctx, "UPDATE purchase_receipt_items SET batch_id = $1 WHERE id = $2", batchIDs[i], itemID); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(ctx, "UPDATE purchase_receipts SET stocked_at = NOW() WHERE id = $1", receiptID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPurchaseReceipts retrieves the delivery history of a purchase
+//
+//encore:api public method=GET path=/api/purchases/:id/receipts
+func GetPurchaseReceipts(ctx context.Context, id uuid.UUID) (ListPurchaseReceiptsResponse, error) {
+	// Check if purchase exists
+	var purchaseExists bool
+	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM purchases WHERE id = $1)", id).Scan(&purchaseExists)
+	if err != nil {
+		return ListPurchaseReceiptsResponse{Message: "Failed to check purchase"}, err
+	}
+	if !purchaseExists {
+		return ListPurchaseReceiptsResponse{Message: "Purchase not found"}, errors.New("purchase not found")
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT
+			r.id,
+			r.delivery_note_number,
+			r.received_at,
+			r.received_by,
+			COALESCE(r.notes, ''),
+			r.stocked_at,
+			ri.purchase_item_id,
+			pi.product_id,
+			ri.quantity,
+			ri.batch_number,
+			ri.expiration_date,
+			ri.batch_id
+		FROM purchase_receipts r
+		JOIN purchase_receipt_items ri ON ri.receipt_id = r.id
+		JOIN purchase_items pi ON pi.id = ri.purchase_item_id
+		WHERE r.purchase_id = $1
+		ORDER BY r.received_at, r.id, ri.line_number, ri.created_at
+	`, id)
+	if err != nil {
+		return ListPurchaseReceiptsResponse{
+			Message: "Failed to retrieve purchase receipts",
+			Data:    []PurchaseReceiptListItem{},
+		}, errors.New("failed to retrieve purchase receipts")
+	}
+	defer rows.Close()
+
+	var receipts []PurchaseReceiptListItem
+	for rows.Next() {
+		var receipt PurchaseReceiptListItem
+		var item PurchaseReceiptItem
+		err = rows.Scan(
+			&receipt.ID,
+			&receipt.DeliveryNoteNumber,
+			&receipt.ReceivedAt,
+			&receipt.ReceivedBy,
+			&receipt.Notes,
+			&receipt.StockedAt,
+			&item.PurchaseItemID,
+			&item.ProductID,
+			&item.Quantity,
+			&item.BatchNumber,
+			&item.ExpirationDate,
+			&item.BatchID,
+		)
+		if err != nil {
+			return ListPurchaseReceiptsResponse{Message: "Failed to scan purchase receipt"}, errors.New("failed to scan purchase receipt")
+		}
+
+		// Rows are ordered by receipt, so group consecutive lines together
+		if len(receipts) == 0 || receipts[len(receipts)-1].ID != receipt.ID {
+			receipts = append(receipts, receipt)
+		}
+		last := &receipts[len(receipts)-1]
+		last.Items = append(last.Items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListPurchaseReceiptsResponse{Message: "Error iterating purchase receipts"}, errors.New("error iterating purchase receipts: " + err.Error())
+	}
+
+	return ListPurchaseReceiptsResponse{
+		Message: "Purchase receipts retrieved successfully",
+		Data:    receipts,
+	}, nil
+}
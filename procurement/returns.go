@@ -0,0 +1,470 @@
+package procurement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/pagination"
+	"encore.app/product"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+	"encore.dev/types/uuid"
+)
+
+// Supplier return statuses
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusShipped   = "shipped"
+	ReturnStatusCredited  = "credited"
+)
+
+// CreateSupplierReturn records goods going back to the supplier of a purchase
+// and takes the returned batches out of stock in the product service. The
+// return is valued at the purchase price of each batch.
+//
+//encore:api public method=POST path=/api/supplier-returns
+func CreateSupplierReturn(ctx context.Context, req *CreateSupplierReturnRequest) (SupplierReturnResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SupplierReturnResponse{Message: "Validation failed"}, err
+	}
+
+	returnNumber, err := nextReturnNumber(ctx, time.Now())
+	if err != nil {
+		return SupplierReturnResponse{Message: "Failed to generate return number"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return SupplierReturnResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	// Only goods that were received can go back
+	var supplierID uuid.UUID
+	var status string
+	err = tx.QueryRow(ctx, "SELECT supplier_id, status FROM purchases WHERE id = $1 FOR SHARE", req.PurchaseID).Scan(&supplierID, &status)
+	if err != nil {
+		return SupplierReturnResponse{Message: "Purchase not found"}, errors.New("purchase not found")
+	}
+	if status != PurchaseStatusPartiallyReceived && status != PurchaseStatusReceived && status != PurchaseStatusClosed {
+		return SupplierReturnResponse{Message: "Purchase has no received goods"}, errors.New("goods can only be returned from a received purchase, current status: " + status)
+	}
+
+	supplierReturn := SupplierReturn{
+		ReturnNumber: returnNumber,
+		PurchaseID:   req.PurchaseID,
+		SupplierID:   supplierID,
+		Reason:       req.Reason,
+		Status:       ReturnStatusRequested,
+		Notes:        req.Notes,
+		RequestedBy:  req.RequestedBy,
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO supplier_returns (return_number, purchase_id, supplier_id, reason, status, total_amount, notes, requested_by)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $7)
+		RETURNING id, requested_at, created_at, updated_at
+	`, returnNumber, req.PurchaseID, supplierID, req.Reason, ReturnStatusRequested, req.Notes, req.RequestedBy).Scan(
+		&supplierReturn.ID,
+		&supplierReturn.RequestedAt,
+		&supplierReturn.CreatedAt,
+		&supplierReturn.UpdatedAt,
+	)
+	if err != nil {
+		return SupplierReturnResponse{Message: "Failed to create supplier return"}, err
+	}
+
+	// Take the goods out of stock in the product service
+	items := make([]product.ReturnBatchItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, product.ReturnBatchItem{BatchID: item.BatchID, Quantity: item.Quantity})
+	}
+	returned, err := product.ReturnBatchesToSupplier(ctx, &product.ReturnBatchesToSupplierRequest{
+		SupplierID: supplierID,
+		PurchaseID: req.PurchaseID,
+		ReturnID:   supplierReturn.ID,
+		CreatedBy:  req.RequestedBy,
+		Notes:      "Returned to supplier, " + returnNumber,
+		Items:      items,
+	})
+	if err != nil {
+		return SupplierReturnResponse{Message: "Failed to return batches"}, err
+	}
+
+	if err = saveSupplierReturnItems(ctx, tx, &supplierReturn, returned.Batches); err != nil {
+		// The return was not recorded, so put the batches back into stock
+		if restoreErr := restoreReturnedBatches(ctx, supplierReturn.ID, req.RequestedBy, returned.Batches); restoreErr != nil {
+			return SupplierReturnResponse{Message: "Failed to create supplier return and to restore its stock"}, restoreErr
+		}
+		return SupplierReturnResponse{Message: "Failed to create supplier return"}, err
+	}
+
+	return SupplierReturnResponse{
+		Message: "Supplier return created successfully",
+		Data:    &supplierReturn,
+	}, nil
+}
+
+// saveSupplierReturnItems stores the batches taken out of stock as the items
+// of a supplier return, sets its total and commits the transaction
+func saveSupplierReturnItems(ctx context.Context, tx *sqldb.Tx, supplierReturn *SupplierReturn, batches []product.ReturnedBatch) error {
+	for _, batch := range batches {
+		item := SupplierReturnItem{
+			BatchID:        batch.BatchID,
+			ProductID:      batch.ProductID,
+			BatchNumber:    batch.BatchNumber,
+			ExpirationDate: batch.ExpirationDate,
+			Quantity:       batch.Quantity,
+			UnitCost:       batch.PurchasePrice,
+			TotalCost:      batch.PurchasePrice.Mul(batch.Quantity),
+		}
+		err := tx.QueryRow(ctx, `
+			INSERT INTO supplier_return_items (return_id, batch_id, product_id, batch_number, expiration_date, quantity, unit_cost, total_cost)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id
+		`, supplierReturn.ID, item.BatchID, item.ProductID, item.BatchNumber, item.ExpirationDate, item.Quantity, item.UnitCost, item.TotalCost).Scan(&item.ID)
+		if err != nil {
+			return errors.New("failed to create supplier return item: " + err.Error())
+		}
+		supplierReturn.TotalAmount += item.TotalCost
+		supplierReturn.Items = append(supplierReturn.Items, item)
+	}
+
+	_, err := tx.Exec(ctx, "UPDATE supplier_returns SET total_amount = $1 WHERE id = $2", supplierReturn.TotalAmount, supplierReturn.ID)
+	if err != nil {
+		return errors.New("failed to update supplier return total: " + err.Error())
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New("failed to commit supplier return: " + err.Error())
+	}
+	return nil
+}
+
+// restoreReturnedBatches puts back stock taken for a supplier return that
+// could not be recorded. The error names the return and batches so the stock
+// can be corrected by hand.
+func restoreReturnedBatches(ctx context.Context, returnID, createdBy uuid.UUID, batches []product.ReturnedBatch) error {
+	items := make([]product.ReturnStockItem, 0, len(batches))
+	batchIDs := make([]uuid.UUID, 0, len(batches))
+	for _, batch := range batches {
+		items = append(items, product.ReturnStockItem{
+			BatchID:  batch.BatchID,
+			Quantity: batch.Quantity,
+		})
+		batchIDs = append(batchIDs, batch.BatchID)
+	}
+
+	_, err := product.ReturnStock(ctx, &product.ReturnStockRequest{
+		ReferenceID: &returnID,
+		Notes:       "Supplier return not completed",
+		CreatedBy:   createdBy,
+		Items:       items,
+	})
+	if err != nil {
+		return fmt.Errorf("supplier return %s was not recorded but batches %v are still out of stock: %v", returnID, batchIDs, err)
+	}
+	return nil
+}
+
+// GetAllSupplierReturns retrieves a page of supplier returns, optionally
+// filtered by supplier, purchase and status
+//
+//encore:api public method=GET path=/api/supplier-returns
+func GetAllSupplierReturns(ctx context.Context, params *GetAllSupplierReturnsParams) (ListSupplierReturnsResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return ListSupplierReturnsResponse{Message: "Validation failed"}, err
+	}
+	cursor, _ := pagination.Decode(params.Cursor)
+	sort, _ := pagination.ParseSort(params.Sort, supplierReturnSortColumns, "-created_at")
+	limit := pagination.Limit(params.Limit)
+
+	var supplierID, purchaseID *uuid.UUID
+	if params.SupplierID != uuid.Nil {
+		supplierID = &params.SupplierID
+	}
+	if params.PurchaseID != uuid.Nil {
+		purchaseID = &params.PurchaseID
+	}
+	filterArgs := []interface{}{supplierID, purchaseID, optionalText(params.Status)}
+
+	base := `
+		WITH return_list AS (
+			SELECT
+				r.id,
+				r.return_number,
+				r.purchase_id,
+				p.purchase_number,
+				r.supplier_id,
+				s.name as supplier_name,
+				r.reason,
+				r.status,
+				r.total_amount,
+				r.credit_amount,
+				r.requested_at,
+				r.created_at
+			FROM supplier_returns r
+			JOIN purchases p ON p.id = r.purchase_id
+			JOIN suppliers s ON s.id = r.supplier_id
+			WHERE ($1::uuid IS NULL OR r.supplier_id = $1)
+				AND ($2::uuid IS NULL OR r.purchase_id = $2)
+				AND ($3::text IS NULL OR r.status = $3)
+		)
+	`
+
+	var total int
+	err := db.QueryRow(ctx, base+"SELECT COUNT(*) FROM return_list", filterArgs...).Scan(&total)
+	if err != nil {
+		return ListSupplierReturnsResponse{Message: "Failed to count supplier returns"}, errors.New("failed to count supplier returns")
+	}
+
+	cursorValue, cursorID := pagination.Args(cursor)
+	query := base + fmt.Sprintf(`
+		SELECT
+			id,
+			return_number,
+			purchase_id,
+			purchase_number,
+			supplier_id,
+			supplier_name,
+			reason,
+			status,
+			total_amount,
+			credit_amount,
+			requested_at,
+			(%s)::text as sort_value
+		FROM return_list
+		WHERE %s
+		ORDER BY %s
+		LIMIT $6
+	`, sort.Column.Expr, sort.After("id", 4, 5), sort.OrderBy("id"))
+	rows, err := db.Query(ctx, query, append(filterArgs, cursorValue, cursorID, limit+1)...)
+	if err != nil {
+		return ListSupplierReturnsResponse{
+			Message: "Failed to retrieve supplier returns",
+			Data:    []SupplierReturnListItem{},
+		}, errors.New("failed to retrieve supplier returns")
+	}
+	defer rows.Close()
+
+	var returns []SupplierReturnListItem
+	var sortValues []string
+	for rows.Next() {
+		var supplierReturn SupplierReturnListItem
+		var sortValue string
+		err = rows.Scan(
+			&supplierReturn.ID,
+			&supplierReturn.ReturnNumber,
+			&supplierReturn.PurchaseID,
+			&supplierReturn.PurchaseNumber,
+			&supplierReturn.SupplierID,
+			&supplierReturn.SupplierName,
+			&supplierReturn.Reason,
+			&supplierReturn.Status,
+			&supplierReturn.TotalAmount,
+			&supplierReturn.CreditAmount,
+			&supplierReturn.RequestedAt,
+			&sortValue,
+		)
+		if err != nil {
+			return ListSupplierReturnsResponse{Message: "Failed to scan supplier return"}, errors.New("failed to scan supplier return")
+		}
+		returns = append(returns, supplierReturn)
+		sortValues = append(sortValues, sortValue)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListSupplierReturnsResponse{Message: "Error iterating supplier returns"}, errors.New("error iterating supplier returns: " + err.Error())
+	}
+
+	// The extra row only tells whether there is a next page
+	var nextCursor string
+	if len(returns) > limit {
+		returns = returns[:limit]
+		nextCursor = pagination.Encode(sortValues[limit-1], returns[limit-1].ID)
+	}
+
+	return ListSupplierReturnsResponse{
+		Message:    "Supplier returns retrieved successfully",
+		Data:       returns,
+		Total:      total,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// GetSupplierReturn retrieves a supplier return with its returned batches
+//
+//encore:api public method=GET path=/api/supplier-returns/:id
+func GetSupplierReturn(ctx context.Context, id uuid.UUID) (SupplierReturnResponse, error) {
+	supplierReturn, err := supplierReturnByID(ctx, id)
+	if err != nil {
+		return SupplierReturnResponse{Message: "Supplier return not found"}, err
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT id, batch_id, product_id, batch_number, expiration_date, quantity, unit_cost, total_cost
+		FROM supplier_return_items
+		WHERE return_id = $1
+		ORDER BY created_at, id
+	`, id)
+	if err != nil {
+		return SupplierReturnResponse{Message: "Failed to retrieve supplier return items"}, errors.New("failed to retrieve supplier return items")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item SupplierReturnItem
+		err = rows.Scan(
+			&item.ID,
+			&item.BatchID,
+			&item.ProductID,
+			&item.BatchNumber,
+			&item.ExpirationDate,
+			&item.Quantity,
+			&item.UnitCost,
+			&item.TotalCost,
+		)
+		if err != nil {
+			return SupplierReturnResponse{Message: "Failed to scan supplier return item"}, errors.New("failed to scan supplier return item")
+		}
+		supplierReturn.Items = append(supplierReturn.Items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return SupplierReturnResponse{Message: "Error iterating supplier return items"}, errors.New("error iterating supplier return items: " + err.Error())
+	}
+
+	return SupplierReturnResponse{
+		Message: "Supplier return retrieved successfully",
+		Data:    supplierReturn,
+	}, nil
+}
+
+// ShipSupplierReturn marks a requested return as shipped to the supplier
+//
+//encore:api public method=POST path=/api/supplier-returns/:id/ship
+func ShipSupplierReturn(ctx context.Context, id uuid.UUID, req *ShipSupplierReturnRequest) (SupplierReturnResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SupplierReturnResponse{Message: "Validation failed"}, err
+	}
+
+	shippedAt := req.ShippedAt
+	if shippedAt.IsZero() {
+		shippedAt = time.Now()
+	}
+
+	result, err := db.Exec(ctx, `
+		UPDATE supplier_returns
+		SET status = $1, shipped_by = $2, shipped_at = $3, updated_at = NOW()
+		WHERE id = $4 AND status = $5
+	`, ReturnStatusShipped, req.ShippedBy, shippedAt, id, ReturnStatusRequested)
+	if err != nil {
+		return SupplierReturnResponse{Message: "Failed to update supplier return"}, err
+	}
+	if result.RowsAffected() == 0 {
+		return supplierReturnNotChanged(ctx, id, ReturnStatusRequested)
+	}
+
+	return GetSupplierReturn(ctx, id)
+}
+
+// CreditSupplierReturn records the credit note the supplier issued for a
+// shipped return. The credited amount may be less than the value of the
+// returned goods but not more.
+//
+//encore:api public method=POST path=/api/supplier-returns/:id/credit
+func CreditSupplierReturn(ctx context.Context, id uuid.UUID, req *CreditSupplierReturnRequest) (SupplierReturnResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SupplierReturnResponse{Message: "Validation failed"}, err
+	}
+
+	creditedAt := req.CreditedAt
+	if creditedAt.IsZero() {
+		creditedAt = time.Now()
+	}
+
+	result, err := db.Exec(ctx, `
+		UPDATE supplier_returns
+		SET status = $1, credit_note_number = $2, credit_amount = $3, credited_by = $4, credited_at = $5, updated_at = NOW()
+		WHERE id = $6 AND status = $7 AND total_amount >= $3
+	`, ReturnStatusCredited, req.CreditNoteNumber, req.CreditAmount, req.CreditedBy, creditedAt, id, ReturnStatusShipped)
+	if sqldb.ErrCode(err) == sqlerr.UniqueViolation {
+		return SupplierReturnResponse{Message: "Credit note number already recorded for this supplier"}, errors.New("credit note number already recorded for this supplier")
+	}
+	if err != nil {
+		return SupplierReturnResponse{Message: "Failed to update supplier return"}, err
+	}
+	if result.RowsAffected() == 0 {
+		resp, err := supplierReturnNotChanged(ctx, id, ReturnStatusShipped)
+		if resp.Data != nil && resp.Data.Status == ReturnStatusShipped {
+			return SupplierReturnResponse{Message: "Validation failed"}, errors.New("credit_amount must not exceed the total amount of the return " + resp.Data.TotalAmount.String())
+		}
+		return resp, err
+	}
+
+	return GetSupplierReturn(ctx, id)
+}
+
+// supplierReturnNotChanged explains why a status change matched no return:
+// either it does not exist or it is not in the expected status
+func supplierReturnNotChanged(ctx context.Context, id uuid.UUID, expected string) (SupplierReturnResponse, error) {
+	supplierReturn, err := supplierReturnByID(ctx, id)
+	if err != nil {
+		return SupplierReturnResponse{Message: "Supplier return not found"}, err
+	}
+	if supplierReturn.Status != expected {
+		return SupplierReturnResponse{Message: "Invalid status transition"}, fmt.Errorf("supplier return must be %s, current status: %s", expected, supplierReturn.Status)
+	}
+	return SupplierReturnResponse{Message: "Supplier return not changed", Data: supplierReturn}, errors.New("supplier return not changed")
+}
+
+// supplierReturnByID loads the header of a supplier return
+func supplierReturnByID(ctx context.Context, id uuid.UUID) (*SupplierReturn, error) {
+	var supplierReturn SupplierReturn
+	err := db.QueryRow(ctx, `
+		SELECT
+			id, return_number, purchase_id, supplier_id, reason, status, total_amount, COALESCE(notes, ''),
+			requested_by, requested_at, shipped_by, shipped_at,
+			credit_note_number, credit_amount, credited_by, credited_at,
+			created_at, updated_at
+		FROM supplier_returns
+		WHERE id = $1
+	`, id).Scan(
+		&supplierReturn.ID,
+		&supplierReturn.ReturnNumber,
+		&supplierReturn.PurchaseID,
+		&supplierReturn.SupplierID,
+		&supplierReturn.Reason,
+		&supplierReturn.Status,
+		&supplierReturn.TotalAmount,
+		&supplierReturn.Notes,
+		&supplierReturn.RequestedBy,
+		&supplierReturn.RequestedAt,
+		&supplierReturn.ShippedBy,
+		&supplierReturn.ShippedAt,
+		&supplierReturn.CreditNoteNumber,
+		&supplierReturn.CreditAmount,
+		&supplierReturn.CreditedBy,
+		&supplierReturn.CreditedAt,
+		&supplierReturn.CreatedAt,
+		&supplierReturn.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.New("supplier return not found")
+	}
+	return &supplierReturn, nil
+}
+
+// nextReturnNumber generates a return number like RT-20240131-0001
+func nextReturnNumber(ctx context.Context, date time.Time) (string, error) {
+	var sequence int64
+	if err := db.QueryRow(ctx, "SELECT nextval('supplier_return_number_seq')").Scan(&sequence); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("RT-%s-%04d", date.Format("20060102"), sequence), nil
+}
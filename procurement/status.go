@@ -0,0 +1,144 @@
+package procurement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// Purchase statuses
+const (
+	PurchaseStatusDraft             = "draft"
+	PurchaseStatusSubmitted         = "submitted"
+	PurchaseStatusApproved          = "approved"
+	PurchaseStatusRejected          = "rejected"
+	PurchaseStatusPartiallyReceived = "partially_received"
+	PurchaseStatusReceived          = "received"
+	PurchaseStatusClosed            = "closed"
+	PurchaseStatusCancelled         = "cancelled"
+)
+
+// purchaseTransitions lists the statuses a purchase may move to from each
+// status. Receiving goods is the only way into partially_received and
+// received; cancellation is only possible before anything was received. A
+// submitted purchase waits for approval, and a rejected one can go back to
+// draft to be revised.
+var purchaseTransitions = map[string][]string{
+	PurchaseStatusDraft:             {PurchaseStatusSubmitted, PurchaseStatusCancelled},
+	PurchaseStatusSubmitted:         {PurchaseStatusApproved, PurchaseStatusRejected, PurchaseStatusCancelled},
+	PurchaseStatusRejected:          {PurchaseStatusDraft, PurchaseStatusCancelled},
+	PurchaseStatusApproved:          {PurchaseStatusPartiallyReceived, PurchaseStatusReceived, PurchaseStatusCancelled},
+	PurchaseStatusPartiallyReceived: {PurchaseStatusPartiallyReceived, PurchaseStatusReceived, PurchaseStatusClosed},
+	PurchaseStatusReceived:          {PurchaseStatusClosed},
+}
+
+// canTransition reports whether a purchase may move from one status to another
+func canTransition(from, to string) bool {
+	for _, allowed := range purchaseTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPurchaseHistory retrieves the status history of a purchase in chronological order
+//
+//encore:api public method=GET path=/api/purchases/:id/history
+func GetPurchaseHistory(ctx context.Context, id uuid.UUID) (PurchaseHistoryResponse, error) {
+	// Check if purchase exists
+	var purchaseExists bool
+	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM purchases WHERE id = $1)", id).Scan(&purchaseExists)
+	if err != nil {
+		return PurchaseHistoryResponse{Message: "Failed to check purchase"}, err
+	}
+	if !purchaseExists {
+		return PurchaseHistoryResponse{Message: "Purchase not found"}, errors.New("purchase not found")
+	}
+
+	history, err := purchaseHistory(ctx, id)
+	if err != nil {
+		return PurchaseHistoryResponse{
+			Message: "Failed to retrieve purchase history",
+			Data:    []PurchaseStatusChange{},
+		}, err
+	}
+
+	return PurchaseHistoryResponse{
+		Message: "Purchase history retrieved successfully",
+		Data:    history,
+	}, nil
+}
+
+// purchaseHistory returns the status changes of a purchase in chronological order
+func purchaseHistory(ctx context.Context, purchaseID uuid.UUID) ([]PurchaseStatusChange, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, from_status, to_status, COALESCE(reason, ''), changed_by, changed_at
+		FROM purchase_status_history
+		WHERE purchase_id = $1
+		ORDER BY changed_at, id
+	`, purchaseID)
+	if err != nil {
+		return nil, errors.New("failed to retrieve purchase history")
+	}
+	defer rows.Close()
+
+	var history []PurchaseStatusChange
+	for rows.Next() {
+		var change PurchaseStatusChange
+		err = rows.Scan(
+			&change.ID,
+			&change.FromStatus,
+			&change.ToStatus,
+			&change.Reason,
+			&change.ChangedBy,
+			&change.ChangedAt,
+		)
+		if err != nil {
+			return nil, errors.New("failed to scan status change")
+		}
+		history = append(history, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("error iterating purchase history: " + err.Error())
+	}
+	return history, nil
+}
+
+// changePurchaseStatus moves a locked purchase from its current status to a
+// new one if the transition is allowed, and records it in the history
+func changePurchaseStatus(ctx context.Context, tx *sqldb.Tx, purchaseID uuid.UUID, from, to, reason string, changedBy uuid.UUID) error {
+	if !canTransition(from, to) {
+		return fmt.Errorf("purchase cannot change from %s to %s", from, to)
+	}
+	if from == to {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `
+		UPDATE purchases
+		SET status = $1, updated_at = NOW()
+		WHERE id = $2
+	`, to, purchaseID)
+	if err != nil {
+		return errors.New("failed to update purchase status: " + err.Error())
+	}
+	return recordStatusChange(ctx, tx, purchaseID, &from, to, reason, changedBy)
+}
+
+// recordStatusChange inserts an entry into the purchase status history. The
+// from status is nil for the status a purchase is created in.
+func recordStatusChange(ctx context.Context, tx *sqldb.Tx, purchaseID uuid.UUID, from *string, to, reason string, changedBy uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO purchase_status_history (purchase_id, from_status, to_status, reason, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, $5, clock_timestamp())
+	`, purchaseID, from, to, reason, changedBy)
+	if err != nil {
+		return errors.New("failed to record purchase status change: " + err.Error())
+	}
+	return nil
+}
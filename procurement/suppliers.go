@@ -3,8 +3,13 @@ package procurement
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"encore.app/pagination"
+	"encore.app/product"
+	"encore.dev/storage/sqldb"
 	"encore.dev/types/uuid"
 )
 
@@ -50,16 +55,44 @@ func CreateSupplier(ctx context.Context, req *CreateSupplierRequest) (Response,
 	return Response{Message: "Supplier created successfully"}, nil
 }
 
-// GetAllSuppliers retrieves all suppliers
+// GetAllSuppliers retrieves a page of suppliers. Suppliers can be searched by
+// name, contact person, email or phone and filtered by city, country and
+// active flag.
 //
 //encore:api public method=GET path=/api/suppliers
-func GetAllSuppliers(ctx context.Context) (ListSuppliersResponse, error) {
-	var listSuppliersResponse ListSuppliersResponse
-	rows, err := db.Query(ctx, `
-		SELECT id, name, contact_person, email, phone, address, city, country, is_active
-		FROM suppliers 
-		ORDER BY name
-	`)
+func GetAllSuppliers(ctx context.Context, params *GetAllSuppliersParams) (ListSuppliersResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return ListSuppliersResponse{Message: "Validation failed"}, err
+	}
+	cursor, _ := pagination.Decode(params.Cursor)
+	isActive, _ := pagination.OptionalBool(params.IsActive)
+	sort, _ := pagination.ParseSort(params.Sort, supplierSortColumns, "name")
+	limit := pagination.Limit(params.Limit)
+
+	filterArgs := []interface{}{pagination.SearchPattern(params.Search), optionalText(params.City), optionalText(params.Country), isActive}
+	filter := `
+		WHERE ($1::text IS NULL OR name ILIKE $1 OR contact_person ILIKE $1 OR email ILIKE $1 OR phone ILIKE $1)
+			AND ($2::text IS NULL OR LOWER(city) = LOWER($2))
+			AND ($3::text IS NULL OR LOWER(country) = LOWER($3))
+			AND ($4::boolean IS NULL OR COALESCE(is_active, false) = $4)
+	`
+
+	var total int
+	err := db.QueryRow(ctx, "SELECT COUNT(*) FROM suppliers"+filter, filterArgs...).Scan(&total)
+	if err != nil {
+		return ListSuppliersResponse{Message: "Failed to count suppliers"}, errors.New("failed to count suppliers")
+	}
+
+	cursorValue, cursorID := pagination.Args(cursor)
+	query := fmt.Sprintf(`
+		SELECT id, name, contact_person, email, phone, address, city, country, is_active, merged_into_id, (%s)::text
+		FROM suppliers
+		%s AND %s
+		ORDER BY %s
+		LIMIT $7
+	`, sort.Column.Expr, filter, sort.After("id", 5, 6), sort.OrderBy("id"))
+	rows, err := db.Query(ctx, query, append(filterArgs, cursorValue, cursorID, limit+1)...)
 	if err != nil {
 		return ListSuppliersResponse{
 			Message: "Failed to retrieve suppliers",
@@ -68,8 +101,11 @@ func GetAllSuppliers(ctx context.Context) (ListSuppliersResponse, error) {
 	}
 	defer rows.Close()
 
+	var suppliers []SupplierListItem
+	var sortValues []string
 	for rows.Next() {
 		var supplier SupplierListItem
+		var sortValue string
 		err = rows.Scan(
 			&supplier.ID,
 			&supplier.Name,
@@ -80,20 +116,32 @@ func GetAllSuppliers(ctx context.Context) (ListSuppliersResponse, error) {
 			&supplier.City,
 			&supplier.Country,
 			&supplier.IsActive,
+			&supplier.MergedIntoID,
+			&sortValue,
 		)
 		if err != nil {
 			return ListSuppliersResponse{Message: "Failed to scan supplier"}, errors.New("failed to scan supplier")
 		}
-		listSuppliersResponse.Data = append(listSuppliersResponse.Data, supplier)
+		suppliers = append(suppliers, supplier)
+		sortValues = append(sortValues, sortValue)
 	}
 
 	if err = rows.Err(); err != nil {
 		return ListSuppliersResponse{Message: "Error iterating suppliers"}, errors.New("error iterating suppliers: " + err.Error())
 	}
 
+	// The extra row only tells whether there is a next page
+	var nextCursor string
+	if len(suppliers) > limit {
+		suppliers = suppliers[:limit]
+		nextCursor = pagination.Encode(sortValues[limit-1], suppliers[limit-1].ID)
+	}
+
 	return ListSuppliersResponse{
-		Message: "Suppliers retrieved successfully",
-		Data:    listSuppliersResponse.Data,
+		Message:    "Suppliers retrieved successfully",
+		Data:       suppliers,
+		Total:      total,
+		NextCursor: nextCursor,
 	}, nil
 }
 
@@ -103,7 +151,7 @@ func GetAllSuppliers(ctx context.Context) (ListSuppliersResponse, error) {
 func GetSupplier(ctx context.Context, id uuid.UUID) (SupplierResponse, error) {
 	var supplier SupplierListItem
 	err := db.QueryRow(ctx, `
-		SELECT id, name, contact_person, email, phone, address, city, country, is_active
+		SELECT id, name, contact_person, email, phone, address, city, country, is_active, merged_into_id
 		FROM suppliers 
 		WHERE id = $1
 	`, id).Scan(
@@ -116,6 +164,7 @@ func GetSupplier(ctx context.Context, id uuid.UUID) (SupplierResponse, error) {
 		&supplier.City,
 		&supplier.Country,
 		&supplier.IsActive,
+		&supplier.MergedIntoID,
 	)
 	if err != nil {
 		return SupplierResponse{Message: "Supplier not found"}, errors.New("supplier not found")
@@ -126,6 +175,250 @@ func GetSupplier(ctx context.Context, id uuid.UUID) (SupplierResponse, error) {
 	}, nil
 }
 
+// UpdateSupplier updates the details of a supplier
+//
+//encore:api public method=PUT path=/api/suppliers/:id
+func UpdateSupplier(ctx context.Context, id uuid.UUID, req *UpdateSupplierRequest) (SupplierResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SupplierResponse{Message: "Validation failed"}, err
+	}
+
+	// Check if another supplier already uses this name
+	var nameTaken bool
+	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM suppliers WHERE name = $1 AND id <> $2)", req.Name, id).Scan(&nameTaken)
+	if err != nil {
+		return SupplierResponse{Message: "Failed to check supplier name"}, err
+	}
+	if nameTaken {
+		return SupplierResponse{Message: "Supplier with this name already exists"}, errors.New("supplier with this name already exists")
+	}
+
+	result, err := db.Exec(ctx, `
+		UPDATE suppliers
+		SET name = $1, contact_person = $2, email = $3, phone = $4, address = $5, city = $6, country = $7, updated_at = NOW()
+		WHERE id = $8
+	`, req.Name, req.ContactPerson, req.Email, req.Phone, req.Address, req.City, req.Country, id)
+	if err != nil {
+		return SupplierResponse{Message: "Failed to update supplier"}, err
+	}
+	if result.RowsAffected() == 0 {
+		return SupplierResponse{Message: "Supplier not found"}, errors.New("supplier not found")
+	}
+
+	resp, err := GetSupplier(ctx, id)
+	if err != nil {
+		return resp, err
+	}
+	resp.Message = "Supplier updated successfully"
+	return resp, nil
+}
+
+// ActivateSupplier marks a supplier as active so new purchases can be placed with it
+//
+//encore:api public method=PUT path=/api/suppliers/:id/activate
+func ActivateSupplier(ctx context.Context, id uuid.UUID) (Response, error) {
+	var mergedIntoID *uuid.UUID
+	err := db.QueryRow(ctx, "SELECT merged_into_id FROM suppliers WHERE id = $1", id).Scan(&mergedIntoID)
+	if err != nil {
+		return Response{Message: "Supplier not found"}, errors.New("supplier not found")
+	}
+	if mergedIntoID != nil {
+		return Response{Message: "Supplier has been merged into another supplier"}, errors.New("merged supplier cannot be activated")
+	}
+
+	return setSupplierActive(ctx, id, true)
+}
+
+// DeactivateSupplier marks a supplier as inactive. Existing purchases are kept,
+// but no new purchases can be placed with the supplier.
+//
+//encore:api public method=PUT path=/api/suppliers/:id/deactivate
+func DeactivateSupplier(ctx context.Context, id uuid.UUID) (Response, error) {
+	return setSupplierActive(ctx, id, false)
+}
+
+// MergeSupplier merges a duplicate supplier into the supplier given by ID.
+// Purchases, price lists, returns, invoices, payments and credit notes of the
+// duplicate are moved in one transaction and the duplicate is deactivated;
+// afterwards the product service moves the supplier of its batches. If that
+// last step fails the merge can simply be repeated. The merge is refused when
+// the two suppliers share an invoice or credit note number, or have price
+// list entries for the same product valid in the same period.
+//
+//encore:api public method=POST path=/api/suppliers/:id/merge
+func MergeSupplier(ctx context.Context, id uuid.UUID, req *MergeSupplierRequest) (MergeSupplierResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return MergeSupplierResponse{Message: "Validation failed"}, err
+	}
+	if req.DuplicateSupplierID == id {
+		return MergeSupplierResponse{Message: "Validation failed"}, errors.New("a supplier cannot be merged into itself")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return MergeSupplierResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	// Lock both suppliers
+	var isActive bool
+	var mergedIntoID *uuid.UUID
+	err = tx.QueryRow(ctx, "SELECT is_active, merged_into_id FROM suppliers WHERE id = $1 FOR UPDATE", id).Scan(&isActive, &mergedIntoID)
+	if err != nil {
+		return MergeSupplierResponse{Message: "Supplier not found"}, errors.New("supplier not found")
+	}
+	if mergedIntoID != nil || !isActive {
+		return MergeSupplierResponse{Message: "Supplier is not active"}, errors.New("suppliers can only be merged into an active supplier")
+	}
+
+	err = tx.QueryRow(ctx, "SELECT merged_into_id FROM suppliers WHERE id = $1 FOR UPDATE", req.DuplicateSupplierID).Scan(&mergedIntoID)
+	if err != nil {
+		return MergeSupplierResponse{Message: "Duplicate supplier not found"}, errors.New("duplicate supplier not found")
+	}
+	if mergedIntoID != nil && *mergedIntoID != id {
+		return MergeSupplierResponse{Message: "Duplicate supplier has already been merged into another supplier"}, errors.New("duplicate supplier already merged")
+	}
+
+	if err = checkSupplierMergeConflicts(ctx, tx, id, req.DuplicateSupplierID); err != nil {
+		return MergeSupplierResponse{Message: "Suppliers cannot be merged"}, err
+	}
+
+	// Move purchases to the canonical supplier
+	result, err := tx.Exec(ctx, `
+		UPDATE purchases
+		SET supplier_id = $1, updated_at = NOW()
+		WHERE supplier_id = $2
+	`, id, req.DuplicateSupplierID)
+	if err != nil {
+		return MergeSupplierResponse{Message: "Failed to move purchases"}, err
+	}
+	merge := SupplierMerge{
+		SupplierID:          id,
+		DuplicateSupplierID: req.DuplicateSupplierID,
+		PurchasesMoved:      int(result.RowsAffected()),
+	}
+
+	// Move the price list, returns and payables with their purchases
+	moves := []struct {
+		query string
+		count *int
+	}{
+		{"UPDATE supplier_prices SET supplier_id = $1, updated_at = NOW() WHERE supplier_id = $2", &merge.PricesMoved},
+		{"UPDATE supplier_returns SET supplier_id = $1, updated_at = NOW() WHERE supplier_id = $2", &merge.ReturnsMoved},
+		{"UPDATE supplier_invoices SET supplier_id = $1, updated_at = NOW() WHERE supplier_id = $2", &merge.InvoicesMoved},
+		{"UPDATE supplier_payments SET supplier_id = $1 WHERE supplier_id = $2", nil},
+		{"UPDATE supplier_credit_notes SET supplier_id = $1 WHERE supplier_id = $2", nil},
+	}
+	for _, move := range moves {
+		result, err = tx.Exec(ctx, move.query, id, req.DuplicateSupplierID)
+		if err != nil {
+			return MergeSupplierResponse{Message: "Failed to move supplier records"}, err
+		}
+		if move.count != nil {
+			*move.count = int(result.RowsAffected())
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE suppliers
+		SET is_active = false, merged_into_id = $1, updated_at = NOW()
+		WHERE id = $2
+	`, id, req.DuplicateSupplierID)
+	if err != nil {
+		return MergeSupplierResponse{Message: "Failed to deactivate duplicate supplier"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return MergeSupplierResponse{Message: "Failed to commit supplier merge"}, err
+	}
+
+	// Move batch supplier references in the product service
+	reassigned, err := product.ReassignBatchSupplier(ctx, &product.ReassignBatchSupplierRequest{
+		FromSupplierID: req.DuplicateSupplierID,
+		ToSupplierID:   id,
+	})
+	if err != nil {
+		return MergeSupplierResponse{
+			Message: "Purchases merged but failed to reassign batches, please retry the merge",
+			Data:    &merge,
+		}, err
+	}
+	merge.BatchesMoved = reassigned.BatchesMoved
+
+	return MergeSupplierResponse{
+		Message: "Suppliers merged successfully",
+		Data:    &merge,
+	}, nil
+}
+
+// checkSupplierMergeConflicts refuses a merge that would break the uniqueness
+// of invoice and credit note numbers per supplier, or leave two price list
+// entries for the same product and minimum quantity valid on the same date
+func checkSupplierMergeConflicts(ctx context.Context, tx *sqldb.Tx, id, duplicateID uuid.UUID) error {
+	var invoiceNumber, creditNoteNumber *string
+	var overlappingPrice *uuid.UUID
+	err := tx.QueryRow(ctx, `
+		SELECT
+			(SELECT d.invoice_number
+				FROM supplier_invoices d
+				JOIN supplier_invoices s ON s.supplier_id = $1 AND s.invoice_number = d.invoice_number
+				WHERE d.supplier_id = $2
+				LIMIT 1),
+			(SELECT d.credit_note_number
+				FROM (
+					SELECT credit_note_number FROM supplier_credit_notes WHERE supplier_id = $2
+					UNION
+					SELECT credit_note_number FROM supplier_returns WHERE supplier_id = $2 AND credit_note_number IS NOT NULL
+				) d
+				WHERE EXISTS(SELECT 1 FROM supplier_credit_notes WHERE supplier_id = $1 AND credit_note_number = d.credit_note_number)
+					OR EXISTS(SELECT 1 FROM supplier_returns WHERE supplier_id = $1 AND credit_note_number = d.credit_note_number)
+				LIMIT 1),
+			(SELECT d.product_id
+				FROM supplier_prices d
+				JOIN supplier_prices s ON s.supplier_id = $1
+					AND s.product_id = d.product_id
+					AND s.min_order_quantity = d.min_order_quantity
+					AND daterange(s.valid_from, s.valid_to, '[]') && daterange(d.valid_from, d.valid_to, '[]')
+				WHERE d.supplier_id = $2
+				LIMIT 1)
+	`, id, duplicateID).Scan(&invoiceNumber, &creditNoteNumber, &overlappingPrice)
+	if err != nil {
+		return errors.New("failed to check supplier records: " + err.Error())
+	}
+	if invoiceNumber != nil {
+		return errors.New("both suppliers have an invoice numbered " + *invoiceNumber)
+	}
+	if creditNoteNumber != nil {
+		return errors.New("both suppliers have a credit note numbered " + *creditNoteNumber)
+	}
+	if overlappingPrice != nil {
+		return errors.New("both suppliers have a price for product " + overlappingPrice.String() + " valid in the same period")
+	}
+	return nil
+}
+
+// setSupplierActive updates the active flag of a supplier
+func setSupplierActive(ctx context.Context, id uuid.UUID, active bool) (Response, error) {
+	result, err := db.Exec(ctx, `
+		UPDATE suppliers
+		SET is_active = $1, updated_at = NOW()
+		WHERE id = $2
+	`, active, id)
+	if err != nil {
+		return Response{Message: "Failed to update supplier status"}, err
+	}
+	if result.RowsAffected() == 0 {
+		return Response{Message: "Supplier not found"}, errors.New("supplier not found")
+	}
+
+	if active {
+		return Response{Message: "Supplier activated successfully"}, nil
+	}
+	return Response{Message: "Supplier deactivated successfully"}, nil
+}
+
 // IsSupplierExists checks if a supplier with the given name already exists
 func IsSupplierExists(ctx context.Context, name string) (bool, error) {
 	var count int
@@ -135,3 +428,12 @@ func IsSupplierExists(ctx context.Context, name string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// optionalText returns nil for an empty filter value so the filter is skipped
+func optionalText(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,181 @@
+package procurement
+
+import (
+	"context"
+	"errors"
+
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
+	"encore.dev/types/uuid"
+)
+
+// GetTaxRates retrieves the configured tax rates
+//
+//encore:api public method=GET path=/api/tax-rates
+func GetTaxRates(ctx context.Context) (ListTaxRatesResponse, error) {
+	rows, err := db.Query(ctx, `
+		SELECT id, code, name, rate_basis_points, is_default, is_active, created_at, updated_at
+		FROM tax_rates
+		ORDER BY is_default DESC, code
+	`)
+	if err != nil {
+		return ListTaxRatesResponse{
+			Message: "Failed to retrieve tax rates",
+			Data:    []TaxRate{},
+		}, errors.New("failed to retrieve tax rates")
+	}
+	defer rows.Close()
+
+	var rates []TaxRate
+	for rows.Next() {
+		var rate TaxRate
+		err = rows.Scan(
+			&rate.ID,
+			&rate.Code,
+			&rate.Name,
+			&rate.RateBasisPoints,
+			&rate.IsDefault,
+			&rate.IsActive,
+			&rate.CreatedAt,
+			&rate.UpdatedAt,
+		)
+		if err != nil {
+			return ListTaxRatesResponse{Message: "Failed to scan tax rate"}, errors.New("failed to scan tax rate")
+		}
+		rates = append(rates, rate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListTaxRatesResponse{Message: "Error iterating tax rates"}, errors.New("error iterating tax rates: " + err.Error())
+	}
+
+	return ListTaxRatesResponse{
+		Message: "Tax rates retrieved successfully",
+		Data:    rates,
+	}, nil
+}
+
+// CreateTaxRate adds a tax rate. Making it the default replaces the previous default.
+//
+//encore:api public method=POST path=/api/tax-rates
+func CreateTaxRate(ctx context.Context, req *TaxRateRequest) (TaxRateResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return TaxRateResponse{Message: "Validation failed"}, err
+	}
+
+	return saveTaxRate(ctx, nil, req)
+}
+
+// UpdateTaxRate changes a tax rate. Purchases keep the rate they were created with.
+//
+//encore:api public method=PUT path=/api/tax-rates/:id
+func UpdateTaxRate(ctx context.Context, id uuid.UUID, req *TaxRateRequest) (TaxRateResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return TaxRateResponse{Message: "Validation failed"}, err
+	}
+
+	return saveTaxRate(ctx, &id, req)
+}
+
+// saveTaxRate inserts a tax rate, or updates it when id is given, keeping at
+// most one default rate
+func saveTaxRate(ctx context.Context, id *uuid.UUID, req *TaxRateRequest) (TaxRateResponse, error) {
+	isActive := req.IsActive == nil || *req.IsActive
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return TaxRateResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	if req.IsDefault {
+		_, err = tx.Exec(ctx, `
+			UPDATE tax_rates
+			SET is_default = false, updated_at = NOW()
+			WHERE is_default AND ($1::uuid IS NULL OR id <> $1)
+		`, id)
+		if err != nil {
+			return TaxRateResponse{Message: "Failed to update default tax rate"}, err
+		}
+	}
+
+	var rate TaxRate
+	scan := []interface{}{
+		&rate.ID,
+		&rate.Code,
+		&rate.Name,
+		&rate.RateBasisPoints,
+		&rate.IsDefault,
+		&rate.IsActive,
+		&rate.CreatedAt,
+		&rate.UpdatedAt,
+	}
+	if id == nil {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO tax_rates (code, name, rate_basis_points, is_default, is_active)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, code, name, rate_basis_points, is_default, is_active, created_at, updated_at
+		`, req.Code, req.Name, req.RateBasisPoints, req.IsDefault, isActive).Scan(scan...)
+	} else {
+		err = tx.QueryRow(ctx, `
+			UPDATE tax_rates
+			SET code = $1, name = $2, rate_basis_points = $3, is_default = $4, is_active = $5, updated_at = NOW()
+			WHERE id = $6
+			RETURNING id, code, name, rate_basis_points, is_default, is_active, created_at, updated_at
+		`, req.Code, req.Name, req.RateBasisPoints, req.IsDefault, isActive, *id).Scan(scan...)
+		if errors.Is(err, sqldb.ErrNoRows) {
+			return TaxRateResponse{Message: "Tax rate not found"}, errors.New("tax rate not found")
+		}
+	}
+	if sqldb.ErrCode(err) == sqlerr.UniqueViolation {
+		return TaxRateResponse{Message: "Tax rate code already exists"}, errors.New("tax rate code already exists")
+	}
+	if err != nil {
+		return TaxRateResponse{Message: "Failed to save tax rate"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return TaxRateResponse{Message: "Failed to commit tax rate"}, err
+	}
+
+	message := "Tax rate created successfully"
+	if id != nil {
+		message = "Tax rate updated successfully"
+	}
+	return TaxRateResponse{
+		Message: message,
+		Data:    &rate,
+	}, nil
+}
+
+// purchaseTaxRate returns the active tax rate with the given ID, or the
+// default rate when id is nil
+func purchaseTaxRate(ctx context.Context, id *uuid.UUID) (*TaxRate, error) {
+	var rate TaxRate
+	err := db.QueryRow(ctx, `
+		SELECT id, code, name, rate_basis_points, is_default, is_active, created_at, updated_at
+		FROM tax_rates
+		WHERE is_active AND (($1::uuid IS NULL AND is_default) OR id = $1)
+	`, id).Scan(
+		&rate.ID,
+		&rate.Code,
+		&rate.Name,
+		&rate.RateBasisPoints,
+		&rate.IsDefault,
+		&rate.IsActive,
+		&rate.CreatedAt,
+		&rate.UpdatedAt,
+	)
+	if errors.Is(err, sqldb.ErrNoRows) {
+		if id == nil {
+			return nil, errors.New("no default tax rate is configured, tax_rate_id is required")
+		}
+		return nil, errors.New("tax rate not found or inactive")
+	}
+	if err != nil {
+		return nil, errors.New("failed to look up tax rate: " + err.Error())
+	}
+	return &rate, nil
+}
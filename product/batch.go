@@ -2,33 +2,257 @@ package product
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"encore.app/money"
+	"encore.dev/storage/sqldb"
+	"encore.dev/storage/sqldb/sqlerr"
 	"encore.dev/types/uuid"
 )
 
+// Batch statuses
+const (
+	BatchStatusActive  = "active"
+	BatchStatusExpired = "expired"
+)
+
 type Batch struct {
-	ID             uuid.UUID  `json:"id"`
-	ProductID      uuid.UUID  `json:"product_id"`
-	BatchNumber    string     `json:"batch_number"`
-	Quantity       int        `json:"quantity"`
-	PurchasePrice  float64    `json:"purchase_price"`
-	SellingPrice   float64    `json:"selling_price"`
-	ExpirationDate time.Time  `json:"expiration_date"`
-	SupplierID     *uuid.UUID `json:"supplier_id,omitempty"`
-	PurchaseID     *uuid.UUID `json:"purchase_id,omitempty"`
-	CreatedAt      time.Time  `json:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at"`
+	ID             uuid.UUID    `json:"id"`
+	ProductID      uuid.UUID    `json:"product_id"`
+	BatchNumber    string       `json:"batch_number"`
+	Quantity       int          `json:"quantity"`
+	PurchasePrice  money.Amount `json:"purchase_price"`
+	SellingPrice   money.Amount `json:"selling_price"`
+	ExpirationDate time.Time    `json:"expiration_date"`
+	SupplierID     *uuid.UUID   `json:"supplier_id,omitempty"`
+	PurchaseID     *uuid.UUID   `json:"purchase_id,omitempty"`
+	Status         string       `json:"status"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
 }
 
-// CreateBatch creates a new batch
+// CreateBatch creates a new batch and records its quantity as initial stock
 func CreateBatch(ctx context.Context, batch *Batch) error {
-	_, err := db.Exec(ctx, `
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = createBatchTx(ctx, tx, batch, ReasonInitialStock, nil, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReceiveBatches creates the batches for goods received against a purchase.
+// All batches are created in a single transaction, so a delivery is either
+// stocked in full or not at all. Batches are created once per receipt: a
+// retried receipt gets back the batches created the first time.
+//
+//encore:api private method=POST path=/internal/batches/receive
+func ReceiveBatches(ctx context.Context, req *ReceiveBatchesRequest) (*ReceiveBatchesResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return &ReceiveBatchesResponse{Message: "Validation failed"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return &ReceiveBatchesResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	batchIDs, err := receiptBatchIDs(ctx, tx, req.ReceiptID)
+	if err != nil {
+		return &ReceiveBatchesResponse{Message: "Failed to retrieve receipt batches"}, err
+	}
+	if len(batchIDs) > 0 {
+		if len(batchIDs) != len(req.Items) {
+			return &ReceiveBatchesResponse{Message: "Receipt already received with other items"}, errors.New("receipt was already received with a different number of items")
+		}
+		return &ReceiveBatchesResponse{
+			Message:  "Batches already received",
+			BatchIDs: batchIDs,
+		}, nil
+	}
+
+	for i, item := range req.Items {
+		// Fall back to the product's base price when no selling price is given
+		sellingPrice := item.SellingPrice
+		if sellingPrice == 0 {
+			err = tx.QueryRow(ctx, "SELECT base_price FROM products WHERE id = $1", item.ProductID).Scan(&sellingPrice)
+			if err != nil {
+				return &ReceiveBatchesResponse{Message: "Product not found: " + item.ProductID.String()}, errors.New("product not found")
+			}
+		}
+
+		batchID, err := createBatchTx(ctx, tx, &Batch{
+			ProductID:      item.ProductID,
+			BatchNumber:    item.BatchNumber,
+			Quantity:       item.Quantity,
+			PurchasePrice:  item.PurchasePrice,
+			SellingPrice:   sellingPrice,
+			ExpirationDate: item.ExpirationDate,
+			SupplierID:     &req.SupplierID,
+			PurchaseID:     &req.PurchaseID,
+		}, ReasonPurchaseReceipt, &req.ReceiptID, &req.ReceivedBy)
+		if err != nil {
+			return &ReceiveBatchesResponse{Message: "Failed to create batch"}, err
+		}
+
+		// A concurrent retry of the same receipt trips the unique receipt line
+		_, err = tx.Exec(ctx, "UPDATE batches SET receipt_id = $1, receipt_line = $2 WHERE id = $3", req.ReceiptID, i, batchID)
+		if sqldb.ErrCode(err) == sqlerr.UniqueViolation {
+			return &ReceiveBatchesResponse{Message: "Receipt is already being received"}, errors.New("batches for this receipt are already being created")
+		}
+		if err != nil {
+			return &ReceiveBatchesResponse{Message: "Failed to create batch"}, err
+		}
+		batchIDs = append(batchIDs, batchID)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return &ReceiveBatchesResponse{Message: "Failed to commit batches"}, err
+	}
+
+	return &ReceiveBatchesResponse{
+		Message:  "Batches received successfully",
+		BatchIDs: batchIDs,
+	}, nil
+}
+
+// receiptBatchIDs returns the batches already created for a purchase receipt,
+// in the order of its lines
+func receiptBatchIDs(ctx context.Context, tx *sqldb.Tx, receiptID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.Query(ctx, "SELECT id FROM batches WHERE receipt_id = $1 ORDER BY receipt_line", receiptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batchIDs []uuid.UUID
+	for rows.Next() {
+		var batchID uuid.UUID
+		if err = rows.Scan(&batchID); err != nil {
+			return nil, err
+		}
+		batchIDs = append(batchIDs, batchID)
+	}
+	return batchIDs, rows.Err()
+}
+
+// ReassignBatchSupplier moves every batch of a supplier to another supplier.
+// It is used when duplicate suppliers are merged in procurement.
+//
+//encore:api private method=POST path=/internal/batches/reassign-supplier
+func ReassignBatchSupplier(ctx context.Context, req *ReassignBatchSupplierRequest) (*ReassignBatchSupplierResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return &ReassignBatchSupplierResponse{Message: "Validation failed"}, err
+	}
+
+	result, err := db.Exec(ctx, `
+		UPDATE batches
+		SET supplier_id = $1, updated_at = NOW()
+		WHERE supplier_id = $2
+	`, req.ToSupplierID, req.FromSupplierID)
+	if err != nil {
+		return &ReassignBatchSupplierResponse{Message: "Failed to reassign batches"}, err
+	}
+
+	return &ReassignBatchSupplierResponse{
+		Message:      "Batches reassigned successfully",
+		BatchesMoved: int(result.RowsAffected()),
+	}, nil
+}
+
+// ReturnBatchesToSupplier takes returned goods out of stock. Every batch must
+// have been received from the supplier against the given purchase. The
+// quantities of all batches change in a single transaction.
+//
+//encore:api private method=POST path=/internal/batches/supplier-return
+func ReturnBatchesToSupplier(ctx context.Context, req *ReturnBatchesToSupplierRequest) (*ReturnBatchesToSupplierResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return &ReturnBatchesToSupplierResponse{Message: "Validation failed"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return &ReturnBatchesToSupplierResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	batches := make([]ReturnedBatch, 0, len(req.Items))
+	for _, item := range req.Items {
+		batch := ReturnedBatch{BatchID: item.BatchID, Quantity: item.Quantity}
+		var supplierID, purchaseID *uuid.UUID
+		err = tx.QueryRow(ctx, `
+			SELECT product_id, batch_number, expiration_date, purchase_price, supplier_id, purchase_id
+			FROM batches
+			WHERE id = $1
+			FOR UPDATE
+		`, item.BatchID).Scan(
+			&batch.ProductID,
+			&batch.BatchNumber,
+			&batch.ExpirationDate,
+			&batch.PurchasePrice,
+			&supplierID,
+			&purchaseID,
+		)
+		if err != nil {
+			return &ReturnBatchesToSupplierResponse{Message: "Batch not found: " + item.BatchID.String()}, errors.New("batch not found")
+		}
+		if supplierID == nil || *supplierID != req.SupplierID || purchaseID == nil || *purchaseID != req.PurchaseID {
+			return &ReturnBatchesToSupplierResponse{Message: "Batch was not received against this purchase: " + batch.BatchNumber}, errors.New("batch was not received from the supplier against this purchase")
+		}
+
+		_, err = changeBatchQuantity(ctx, tx, item.BatchID, -item.Quantity, ReasonSupplierReturn, &req.ReturnID, &req.CreatedBy, req.Notes)
+		if err != nil {
+			return &ReturnBatchesToSupplierResponse{Message: "Failed to return batch: " + batch.BatchNumber}, err
+		}
+		batches = append(batches, batch)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return &ReturnBatchesToSupplierResponse{Message: "Failed to commit returned batches"}, err
+	}
+
+	return &ReturnBatchesToSupplierResponse{
+		Message: "Batches returned successfully",
+		Batches: batches,
+	}, nil
+}
+
+// createBatchTx creates a new batch inside the given transaction, records its
+// quantity as a stock movement with the given reason and returns its ID
+func createBatchTx(ctx context.Context, tx *sqldb.Tx, batch *Batch, reason string, referenceID, createdBy *uuid.UUID) (uuid.UUID, error) {
+	var batchID uuid.UUID
+	err := tx.QueryRow(ctx, `
 		INSERT INTO batches (product_id, batch_number, quantity, purchase_price, selling_price, expiration_date, supplier_id, purchase_id) 
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, batch.ProductID, batch.BatchNumber, batch.Quantity, batch.PurchasePrice, batch.SellingPrice, batch.ExpirationDate, batch.SupplierID, batch.PurchaseID)
+		RETURNING id
+	`, batch.ProductID, batch.BatchNumber, batch.Quantity, batch.PurchasePrice, batch.SellingPrice, batch.ExpirationDate, batch.SupplierID, batch.PurchaseID).Scan(&batchID)
 	if err != nil {
-		return err
+		return uuid.Nil, err
+	}
+
+	if batch.Quantity > 0 {
+		err = recordMovement(ctx, tx, &StockMovement{
+			ProductID:      batch.ProductID,
+			BatchID:        batchID,
+			Reason:         reason,
+			QuantityChange: batch.Quantity,
+			QuantityBefore: 0,
+			QuantityAfter:  batch.Quantity,
+			ReferenceID:    referenceID,
+			CreatedBy:      createdBy,
+		})
+		if err != nil {
+			return uuid.Nil, err
+		}
 	}
-	return nil
+	return batchID, nil
 }
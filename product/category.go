@@ -3,26 +3,61 @@ package product
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
+	"encore.app/pagination"
 	"encore.dev/types/uuid"
 )
 
 // Category model
 type Category struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentID    *uuid.UUID `json:"parent_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
-// GetAllCategories retrieves all categories
+// GetAllCategories retrieves a page of categories, optionally searched by
+// name or description and filtered by parent category
 //
 //encore:api public  method=GET path=/api/categories
-func GetAllCategories(ctx context.Context) (ListCategoriesResponse, error) {
-	var listCategoriesResponse ListCategoriesResponse
-	rows, err := db.Query(ctx, "SELECT id, name, description FROM categories")
+func GetAllCategories(ctx context.Context, params *GetAllCategoriesParams) (ListCategoriesResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return ListCategoriesResponse{Message: "Validation failed"}, err
+	}
+	cursor, _ := pagination.Decode(params.Cursor)
+	sort, _ := pagination.ParseSort(params.Sort, categorySortColumns, "name")
+	limit := pagination.Limit(params.Limit)
+
+	var parentID *uuid.UUID
+	if params.ParentID != uuid.Nil {
+		parentID = &params.ParentID
+	}
+	filterArgs := []interface{}{pagination.SearchPattern(params.Search), parentID}
+	filter := `
+		WHERE ($1::text IS NULL OR name ILIKE $1 OR description ILIKE $1)
+			AND ($2::uuid IS NULL OR parent_id = $2)
+	`
+
+	var total int
+	err := db.QueryRow(ctx, "SELECT COUNT(*) FROM categories"+filter, filterArgs...).Scan(&total)
+	if err != nil {
+		return ListCategoriesResponse{Message: "Failed to count categories"}, errors.New("failed to count categories")
+	}
+
+	cursorValue, cursorID := pagination.Args(cursor)
+	query := fmt.Sprintf(`
+		SELECT id, name, description, parent_id, (%s)::text
+		FROM categories
+		%s AND %s
+		ORDER BY %s
+		LIMIT $5
+	`, sort.Column.Expr, filter, sort.After("id", 3, 4), sort.OrderBy("id"))
+	rows, err := db.Query(ctx, query, append(filterArgs, cursorValue, cursorID, limit+1)...)
 	if err != nil {
 		return ListCategoriesResponse{
 			Message: "Failed to retrieve categories",
@@ -30,24 +65,68 @@ func GetAllCategories(ctx context.Context) (ListCategoriesResponse, error) {
 		}, errors.New("failed to retrieve categories")
 	}
 	defer rows.Close()
+
+	var categories []CategoryListItem
+	var sortValues []string
 	for rows.Next() {
 		var category CategoryListItem
+		var sortValue string
 		err = rows.Scan(
 			&category.ID,
 			&category.Name,
 			&category.Description,
+			&category.ParentID,
+			&sortValue,
 		)
 		if err != nil {
 			return ListCategoriesResponse{Message: "Failed to scan category"}, errors.New("failed to scan category")
 		}
-		listCategoriesResponse.Data = append(listCategoriesResponse.Data, category)
+		categories = append(categories, category)
+		sortValues = append(sortValues, sortValue)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListCategoriesResponse{Message: "Error iterating categories"}, errors.New("error iterating categories: " + err.Error())
+	}
+
+	// The extra row only tells whether there is a next page
+	var nextCursor string
+	if len(categories) > limit {
+		categories = categories[:limit]
+		nextCursor = pagination.Encode(sortValues[limit-1], categories[limit-1].ID)
 	}
+
 	return ListCategoriesResponse{
-		Message: "Categories retrieved successfully",
-		Data:    listCategoriesResponse.Data,
+		Message:    "Categories retrieved successfully",
+		Data:       categories,
+		Total:      total,
+		NextCursor: nextCursor,
 	}, nil
 }
 
+// GetCategory retrieves a category by ID
+//
+//encore:api public method=GET path=/api/categories/:id
+func GetCategory(ctx context.Context, id uuid.UUID) (*Category, error) {
+	var category Category
+	err := db.QueryRow(ctx, `
+		SELECT id, name, description, parent_id, created_at, updated_at
+		FROM categories
+		WHERE id = $1
+	`, id).Scan(
+		&category.ID,
+		&category.Name,
+		&category.Description,
+		&category.ParentID,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.New("category not found")
+	}
+	return &category, nil
+}
+
 // CreateCategory creates a new category
 //
 //encore:api public method=POST path=/api/categories
@@ -65,9 +144,24 @@ func CreateCategory(ctx context.Context, category *CreateCategoryRequest) (*Resp
 		}, nil
 	}
 
+	// Check if parent category exists
+	if category.ParentID != nil {
+		parentExists, err := IsCategoryIDExists(ctx, *category.ParentID)
+		if err != nil {
+			return &Response{
+				Message: "Failed to check parent category",
+			}, err
+		}
+		if !parentExists {
+			return &Response{
+				Message: "Parent category not found",
+			}, errors.New("parent category not found")
+		}
+	}
+
 	// Create category
 	var categoryID uuid.UUID
-	err = db.QueryRow(ctx, "INSERT INTO categories (name, description) VALUES ($1, $2) RETURNING id", category.Name, category.Description).Scan(&categoryID)
+	err = db.QueryRow(ctx, "INSERT INTO categories (name, description, parent_id) VALUES ($1, $2, $3) RETURNING id", category.Name, category.Description, category.ParentID).Scan(&categoryID)
 	if err != nil {
 		return &Response{
 			Message: "Failed to create category",
@@ -78,6 +172,115 @@ func CreateCategory(ctx context.Context, category *CreateCategoryRequest) (*Resp
 	}, nil
 }
 
+// UpdateCategory updates the name, description and parent of a category
+//
+//encore:api public method=PUT path=/api/categories/:id
+func UpdateCategory(ctx context.Context, id uuid.UUID, req *UpdateCategoryRequest) (*CategoryResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return &CategoryResponse{Message: "Validation failed"}, err
+	}
+
+	// Check if another category already uses this name
+	var nameTaken bool
+	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE name = $1 AND id <> $2)", req.Name, id).Scan(&nameTaken)
+	if err != nil {
+		return &CategoryResponse{Message: "Failed to check category name"}, err
+	}
+	if nameTaken {
+		return &CategoryResponse{Message: "Category with this name already exists"}, errors.New("category with this name already exists")
+	}
+
+	// The parent must exist and must not be the category itself or one of its descendants
+	if req.ParentID != nil {
+		var parentInSubtree bool
+		err = db.QueryRow(ctx, `
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM categories WHERE id = $1
+				UNION ALL
+				SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+			)
+			SELECT EXISTS(SELECT 1 FROM subtree WHERE id = $2)
+		`, id, *req.ParentID).Scan(&parentInSubtree)
+		if err != nil {
+			return &CategoryResponse{Message: "Failed to check parent category"}, err
+		}
+		if parentInSubtree {
+			return &CategoryResponse{Message: "Validation failed"}, errors.New("parent_id must not be the category itself or one of its descendants")
+		}
+
+		parentExists, err := IsCategoryIDExists(ctx, *req.ParentID)
+		if err != nil {
+			return &CategoryResponse{Message: "Failed to check parent category"}, err
+		}
+		if !parentExists {
+			return &CategoryResponse{Message: "Parent category not found"}, errors.New("parent category not found")
+		}
+	}
+
+	var category CategoryListItem
+	err = db.QueryRow(ctx, `
+		UPDATE categories
+		SET name = $1, description = $2, parent_id = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING id, name, description, parent_id
+	`, req.Name, req.Description, req.ParentID, id).Scan(
+		&category.ID,
+		&category.Name,
+		&category.Description,
+		&category.ParentID,
+	)
+	if err != nil {
+		return &CategoryResponse{Message: "Category not found"}, errors.New("category not found")
+	}
+
+	return &CategoryResponse{
+		Message: "Category updated successfully",
+		Data:    &category,
+	}, nil
+}
+
+// DeleteCategory deletes a category that has no products and no subcategories
+//
+//encore:api public method=DELETE path=/api/categories/:id
+func DeleteCategory(ctx context.Context, id uuid.UUID) (*Response, error) {
+	// Check if category exists
+	exists, err := IsCategoryIDExists(ctx, id)
+	if err != nil {
+		return &Response{Message: "Failed to check category"}, err
+	}
+	if !exists {
+		return &Response{Message: "Category not found"}, errors.New("category not found")
+	}
+
+	// Check if any product references the category
+	var hasProducts bool
+	err = db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE category_id = $1)", id).Scan(&hasProducts)
+	if err != nil {
+		return &Response{Message: "Failed to check products"}, err
+	}
+	if hasProducts {
+		return &Response{Message: "Category has products"}, errors.New("category is referenced by products")
+	}
+
+	// Check if the category has subcategories
+	var hasChildren bool
+	err = db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = $1)", id).Scan(&hasChildren)
+	if err != nil {
+		return &Response{Message: "Failed to check subcategories"}, err
+	}
+	if hasChildren {
+		return &Response{Message: "Category has subcategories"}, errors.New("category has subcategories")
+	}
+
+	_, err = db.Exec(ctx, "DELETE FROM categories WHERE id = $1", id)
+	if err != nil {
+		return &Response{Message: "Failed to delete category"}, err
+	}
+
+	return &Response{Message: "Category deleted successfully"}, nil
+}
+
 // IsCategoryExists checks if a category with the given name already exists
 func IsCategoryExists(ctx context.Context, name string) (bool, error) {
 	var count int
@@ -87,3 +290,13 @@ func IsCategoryExists(ctx context.Context, name string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// IsCategoryIDExists checks if a category with the given ID exists
+func IsCategoryIDExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	var count int
+	err := db.QueryRow(ctx, "SELECT COUNT(*) FROM categories WHERE id = $1", id).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,325 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// Drug classes
+const (
+	DrugClassRegular      = "regular"
+	DrugClassOTCLimited   = "otc_limited"
+	DrugClassHardDrug     = "hard_drug"
+	DrugClassPsychotropic = "psychotropic"
+	DrugClassNarcotic     = "narcotic"
+)
+
+// Register reasons for entries without a stock movement: the stock on hand
+// when a product is classified into or out of the controlled classes
+const (
+	RegisterReasonReclassifiedIn  = "reclassified_in"
+	RegisterReasonReclassifiedOut = "reclassified_out"
+)
+
+// IsValidDrugClass checks if the given value is a known drug class
+func IsValidDrugClass(drugClass string) bool {
+	switch drugClass {
+	case DrugClassRegular, DrugClassOTCLimited, DrugClassHardDrug, DrugClassPsychotropic, DrugClassNarcotic:
+		return true
+	}
+	return false
+}
+
+// IsControlledDrugClass checks if products of the given drug class must be
+// kept in the controlled substance register (narcotics and psychotropics)
+func IsControlledDrugClass(drugClass string) bool {
+	return drugClass == DrugClassPsychotropic || drugClass == DrugClassNarcotic
+}
+
+// GetControlledSubstanceRegister retrieves the register entries of controlled
+// products for a month, optionally limited to a single product
+//
+//encore:api public method=GET path=/api/controlled-substances/register
+func GetControlledSubstanceRegister(ctx context.Context, params *ControlledSubstanceRegisterParams) (ControlledSubstanceRegisterResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return ControlledSubstanceRegisterResponse{Message: "Validation failed"}, err
+	}
+	from, to := params.Period()
+
+	rows, err := db.Query(ctx, `
+		SELECT
+			r.id,
+			r.entry_number,
+			r.product_id,
+			p.name,
+			r.batch_id,
+			b.batch_number,
+			r.drug_class,
+			r.reason,
+			r.quantity_in,
+			r.quantity_out,
+			r.balance_before,
+			r.balance_after,
+			r.reference_id,
+			r.created_by,
+			r.created_at
+		FROM controlled_substance_register r
+		JOIN products p ON p.id = r.product_id
+		JOIN batches b ON b.id = r.batch_id
+		WHERE r.created_at >= $1 AND r.created_at < $2
+			AND ($3::uuid IS NULL OR r.product_id = $3)
+		ORDER BY p.name, r.entry_number
+	`, from, to, params.productID())
+	if err != nil {
+		return ControlledSubstanceRegisterResponse{
+			Message: "Failed to retrieve register entries",
+			Data:    []ControlledRegisterEntry{},
+		}, errors.New("failed to retrieve register entries")
+	}
+	defer rows.Close()
+
+	var entries []ControlledRegisterEntry
+	for rows.Next() {
+		var entry ControlledRegisterEntry
+		err = rows.Scan(
+			&entry.ID,
+			&entry.EntryNumber,
+			&entry.ProductID,
+			&entry.ProductName,
+			&entry.BatchID,
+			&entry.BatchNumber,
+			&entry.DrugClass,
+			&entry.Reason,
+			&entry.QuantityIn,
+			&entry.QuantityOut,
+			&entry.BalanceBefore,
+			&entry.BalanceAfter,
+			&entry.ReferenceID,
+			&entry.CreatedBy,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return ControlledSubstanceRegisterResponse{Message: "Failed to scan register entry"}, errors.New("failed to scan register entry")
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ControlledSubstanceRegisterResponse{Message: "Error iterating register entries"}, errors.New("error iterating register entries: " + err.Error())
+	}
+
+	return ControlledSubstanceRegisterResponse{
+		Message: "Register entries retrieved successfully",
+		Data:    entries,
+	}, nil
+}
+
+// GetControlledSubstanceReport generates the monthly narcotics and
+// psychotropics report: per product the opening balance, receipts, dispenses,
+// other outgoing stock and closing balance for the month
+//
+//encore:api public method=GET path=/api/controlled-substances/report
+func GetControlledSubstanceReport(ctx context.Context, params *ControlledSubstanceRegisterParams) (ControlledSubstanceReportResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return ControlledSubstanceReportResponse{Message: "Validation failed"}, err
+	}
+	from, to := params.Period()
+
+	// Opening balance is the balance after the last entry before the month;
+	// products without entries in the month are reported while they hold stock
+	rows, err := db.Query(ctx, `
+		WITH opening AS (
+			SELECT DISTINCT ON (product_id) product_id, balance_after
+			FROM controlled_substance_register
+			WHERE created_at < $1
+			ORDER BY product_id, entry_number DESC
+		),
+		period AS (
+			SELECT
+				product_id,
+				SUM(quantity_in) as receipts,
+				SUM(quantity_out) FILTER (WHERE reason = 'sale') as dispenses,
+				SUM(quantity_out) FILTER (WHERE reason <> 'sale') as other_out
+			FROM controlled_substance_register
+			WHERE created_at >= $1 AND created_at < $2
+			GROUP BY product_id
+		)
+		SELECT
+			p.id,
+			p.name,
+			p.drug_class,
+			COALESCE(o.balance_after, 0) as opening_balance,
+			COALESCE(m.receipts, 0) as receipts,
+			COALESCE(m.dispenses, 0) as dispenses,
+			COALESCE(m.other_out, 0) as other_out
+		FROM products p
+		LEFT JOIN opening o ON o.product_id = p.id
+		LEFT JOIN period m ON m.product_id = p.id
+		WHERE (o.balance_after > 0 OR m.product_id IS NOT NULL)
+			AND ($3::uuid IS NULL OR p.id = $3)
+		ORDER BY p.drug_class, p.name
+	`, from, to, params.productID())
+	if err != nil {
+		return ControlledSubstanceReportResponse{Message: "Failed to generate report"}, errors.New("failed to generate report: " + err.Error())
+	}
+	defer rows.Close()
+
+	report := ControlledSubstanceReport{
+		Year:  params.Year,
+		Month: params.Month,
+	}
+	for rows.Next() {
+		var line ControlledSubstanceReportLine
+		err = rows.Scan(
+			&line.ProductID,
+			&line.ProductName,
+			&line.DrugClass,
+			&line.OpeningBalance,
+			&line.Receipts,
+			&line.Dispenses,
+			&line.OtherOut,
+		)
+		if err != nil {
+			return ControlledSubstanceReportResponse{Message: "Failed to scan report line"}, errors.New("failed to scan report line")
+		}
+		line.ClosingBalance = line.OpeningBalance + line.Receipts - line.Dispenses - line.OtherOut
+		report.Lines = append(report.Lines, line)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ControlledSubstanceReportResponse{Message: "Error iterating report lines"}, errors.New("error iterating report lines: " + err.Error())
+	}
+
+	return ControlledSubstanceReportResponse{
+		Message: "Report generated successfully",
+		Data:    &report,
+	}, nil
+}
+
+// recordControlledMovement logs a stock movement to the controlled substance
+// register when its product is a narcotic or psychotropic. Only controlled
+// products are locked, so the product-level balances of their concurrent
+// movements stay in sequence; entries are numbered and timestamped after the
+// lock is taken.
+func recordControlledMovement(ctx context.Context, tx *sqldb.Tx, movement *StockMovement) error {
+	var drugClass string
+	err := tx.QueryRow(ctx, "SELECT drug_class FROM products WHERE id = $1", movement.ProductID).Scan(&drugClass)
+	if err != nil {
+		return errors.New("product not found")
+	}
+	if !IsControlledDrugClass(drugClass) {
+		return nil
+	}
+
+	err = tx.QueryRow(ctx, "SELECT drug_class FROM products WHERE id = $1 FOR UPDATE", movement.ProductID).Scan(&drugClass)
+	if err != nil {
+		return errors.New("product not found")
+	}
+
+	// The batch quantity has already been changed, so the sum is the balance after the movement
+	var balanceAfter int
+	err = tx.QueryRow(ctx, "SELECT COALESCE(SUM(quantity), 0) FROM batches WHERE product_id = $1", movement.ProductID).Scan(&balanceAfter)
+	if err != nil {
+		return errors.New("failed to compute product balance: " + err.Error())
+	}
+
+	quantityIn, quantityOut := 0, 0
+	if movement.QuantityChange > 0 {
+		quantityIn = movement.QuantityChange
+	} else {
+		quantityOut = -movement.QuantityChange
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO controlled_substance_register (stock_movement_id, product_id, batch_id, drug_class, reason, quantity_in, quantity_out, balance_before, balance_after, reference_id, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, clock_timestamp())
+	`, movement.ID, movement.ProductID, movement.BatchID, drugClass, movement.Reason, quantityIn, quantityOut, balanceAfter-movement.QuantityChange, balanceAfter, movement.ReferenceID, movement.CreatedBy)
+	if err != nil {
+		return errors.New("failed to record controlled substance register entry: " + err.Error())
+	}
+	return nil
+}
+
+// recordReclassification opens or closes the register of a product that moves
+// into or out of the controlled classes, with one entry per batch holding
+// stock, so the register balance matches the stock on hand. The batches come
+// from lockProductBatches.
+func recordReclassification(ctx context.Context, tx *sqldb.Tx, productID uuid.UUID, fromClass, toClass string, batches []Batch) error {
+	if IsControlledDrugClass(fromClass) == IsControlledDrugClass(toClass) {
+		return nil
+	}
+
+	// Opening entries count up from zero, closing entries down from the stock on hand
+	reason, drugClass, balance := RegisterReasonReclassifiedIn, toClass, 0
+	if IsControlledDrugClass(fromClass) {
+		reason, drugClass = RegisterReasonReclassifiedOut, fromClass
+		for _, batch := range batches {
+			balance += batch.Quantity
+		}
+	}
+
+	for _, batch := range batches {
+		quantityIn, quantityOut := batch.Quantity, 0
+		if reason == RegisterReasonReclassifiedOut {
+			quantityIn, quantityOut = 0, batch.Quantity
+		}
+		balanceAfter := balance + quantityIn - quantityOut
+
+		_, err := tx.Exec(ctx, `
+			INSERT INTO controlled_substance_register (product_id, batch_id, drug_class, reason, quantity_in, quantity_out, balance_before, balance_after, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, clock_timestamp())
+		`, productID, batch.ID, drugClass, reason, quantityIn, quantityOut, balance, balanceAfter)
+		if err != nil {
+			return errors.New("failed to record controlled substance register entry: " + err.Error())
+		}
+		balance = balanceAfter
+	}
+	return nil
+}
+
+// lockProductBatches locks every batch of a product and returns those holding stock
+func lockProductBatches(ctx context.Context, tx *sqldb.Tx, productID uuid.UUID) ([]Batch, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id, batch_number, quantity
+		FROM batches
+		WHERE product_id = $1
+		ORDER BY created_at, id
+		FOR UPDATE
+	`, productID)
+	if err != nil {
+		return nil, errors.New("failed to retrieve batches: " + err.Error())
+	}
+	defer rows.Close()
+
+	var batches []Batch
+	for rows.Next() {
+		var batch Batch
+		if err = rows.Scan(&batch.ID, &batch.BatchNumber, &batch.Quantity); err != nil {
+			return nil, errors.New("failed to scan batch: " + err.Error())
+		}
+		if batch.Quantity > 0 {
+			batches = append(batches, batch)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("error iterating batches: " + err.Error())
+	}
+	return batches, nil
+}
+
+// pharmacyLocation is the local time of the pharmacy. Monthly reports and the
+// expiry job follow WIB, which has no daylight saving time.
+var pharmacyLocation = time.FixedZone("Asia/Jakarta", 7*60*60)
+
+// monthPeriod returns the first instant of the month and of the following
+// month in local time, as UTC to match the stored timestamps
+func monthPeriod(year, month int) (time.Time, time.Time) {
+	from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, pharmacyLocation)
+	return from.UTC(), from.AddDate(0, 1, 0).UTC()
+}
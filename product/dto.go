@@ -2,8 +2,13 @@ package product
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
+	"encore.app/gs1"
+	"encore.app/money"
+	"encore.app/pagination"
 	"encore.dev/types/uuid"
 )
 
@@ -12,14 +17,16 @@ type Response struct {
 }
 
 type CategoryListItem struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentID    *uuid.UUID `json:"parent_id,omitempty"`
 }
 
 type CreateCategoryRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentID    *uuid.UUID `json:"parent_id"`
 }
 
 func (c *CreateCategoryRequest) Validate() error {
@@ -32,23 +39,74 @@ func (c *CreateCategoryRequest) Validate() error {
 	return nil
 }
 
+type UpdateCategoryRequest struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+}
+
+func (c *UpdateCategoryRequest) Validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(c.Name) > 255 {
+		return errors.New("name must be less than 255 characters")
+	}
+	return nil
+}
+
+type CategoryResponse struct {
+	Message string            `json:"message"`
+	Data    *CategoryListItem `json:"data,omitempty"`
+}
+
+// categorySortColumns are the columns categories can be sorted by
+var categorySortColumns = map[string]pagination.Column{
+	"name":       {Expr: "name", Type: "text"},
+	"created_at": {Expr: "created_at", Type: "timestamp"},
+}
+
+type GetAllCategoriesParams struct {
+	Cursor   string    `query:"cursor"`
+	Limit    int       `query:"limit"`
+	Search   string    `query:"search"`
+	ParentID uuid.UUID `query:"parent_id"`
+	Sort     string    `query:"sort"`
+}
+
+func (g *GetAllCategoriesParams) Validate() error {
+	if err := pagination.ValidateLimit(g.Limit); err != nil {
+		return err
+	}
+	if _, err := pagination.Decode(g.Cursor); err != nil {
+		return err
+	}
+	_, err := pagination.ParseSort(g.Sort, categorySortColumns, "name")
+	return err
+}
+
 type ListCategoriesResponse struct {
-	Message string             `json:"message"`
-	Data    []CategoryListItem `json:"data"`
+	Message    string             `json:"message"`
+	Data       []CategoryListItem `json:"data"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor,omitempty"`
 }
 
 type CreateProductRequest struct {
-	Name                 string    `json:"name"`
-	CategoryID           uuid.UUID `json:"category_id"`
-	SellingPrice         float64   `json:"selling_price"`
-	CostPrice            float64   `json:"cost_price"`
-	StockQuantity        int       `json:"stock_quantity"`
-	MinimumStockQuantity int       `json:"minimum_stock_quantity"`
-	Barcode              string    `json:"barcode"`
-	ExpirationDate       time.Time `json:"expiration_date"`
-	BatchNumber          string    `json:"batch_number"`
-	SupplierID           uuid.UUID `json:"supplier_id"`
-	Description          string    `json:"description"`
+	Name                 string       `json:"name"`
+	CategoryID           uuid.UUID    `json:"category_id"`
+	SellingPrice         money.Amount `json:"selling_price"`
+	CostPrice            money.Amount `json:"cost_price"`
+	StockQuantity        int          `json:"stock_quantity"`
+	MinimumStockQuantity int          `json:"minimum_stock_quantity"`
+	Barcode              string       `json:"barcode"`
+	ExpirationDate       time.Time    `json:"expiration_date"`
+	BatchNumber          string       `json:"batch_number"`
+	SupplierID           uuid.UUID    `json:"supplier_id"`
+	Description          string       `json:"description"`
+	RequiresPrescription bool         `json:"requires_prescription"`
+	DrugClass            string       `json:"drug_class"`
+	CreatedBy            uuid.UUID    `json:"created_by"`
 }
 
 func (p *CreateProductRequest) Validate() error {
@@ -82,31 +140,604 @@ func (p *CreateProductRequest) Validate() error {
 	if p.SupplierID == uuid.Nil {
 		return errors.New("supplier_id is required")
 	}
+	if p.DrugClass != "" && !IsValidDrugClass(p.DrugClass) {
+		return errors.New("drug_class must be one of: regular, otc_limited, hard_drug, psychotropic, narcotic")
+	}
 	return nil
 }
 
 type CreateBatchRequest struct {
-	ProductID      uuid.UUID `json:"product_id"`
-	BatchNumber    string    `json:"batch_number"`
-	Quantity       int       `json:"quantity"`
-	PurchasePrice  float64   `json:"purchase_price"`
-	SellingPrice   float64   `json:"selling_price"`
-	ExpirationDate time.Time `json:"expiration_date"`
+	ProductID      uuid.UUID    `json:"product_id"`
+	BatchNumber    string       `json:"batch_number"`
+	Quantity       int          `json:"quantity"`
+	PurchasePrice  money.Amount `json:"purchase_price"`
+	SellingPrice   money.Amount `json:"selling_price"`
+	ExpirationDate time.Time    `json:"expiration_date"`
 }
 
 type ProductWithBatchListItem struct {
-	ID                   uuid.UUID `json:"id"`
-	Name                 string    `json:"name"`
-	Category             string    `json:"category"`
-	Description          string    `json:"description"`
-	BatchNumber          string    `json:"batch_number"`
-	Quantity             int       `json:"quantity"`
-	MinimumStockQuantity int       `json:"minimum_stock_quantity"`
-	SellingPrice         float64   `json:"selling_price"`
-	ExpirationDate       time.Time `json:"expiration_date"`
+	ID                   uuid.UUID    `json:"id"`
+	Name                 string       `json:"name"`
+	Category             string       `json:"category"`
+	Description          string       `json:"description"`
+	BatchNumber          string       `json:"batch_number"`
+	Quantity             int          `json:"quantity"`
+	MinimumStockQuantity int          `json:"minimum_stock_quantity"`
+	SellingPrice         money.Amount `json:"selling_price"`
+	ExpirationDate       time.Time    `json:"expiration_date"`
+	IsActive             bool         `json:"is_active"`
+}
+
+// productSortColumns are the columns products can be sorted by
+var productSortColumns = map[string]pagination.Column{
+	"name":       {Expr: "name", Type: "text"},
+	"base_price": {Expr: "base_price", Type: "numeric"},
+	"quantity":   {Expr: "total_quantity", Type: "bigint"},
+	"created_at": {Expr: "created_at", Type: "timestamp"},
+}
+
+type GetAllProductsParams struct {
+	Cursor     string    `query:"cursor"`
+	Limit      int       `query:"limit"`
+	Search     string    `query:"search"`
+	CategoryID uuid.UUID `query:"category_id"`
+	IsActive   string    `query:"is_active"`
+	Sort       string    `query:"sort"`
+}
+
+func (g *GetAllProductsParams) Validate() error {
+	if err := pagination.ValidateLimit(g.Limit); err != nil {
+		return err
+	}
+	if _, err := pagination.Decode(g.Cursor); err != nil {
+		return err
+	}
+	if _, err := pagination.OptionalBool(g.IsActive); err != nil {
+		return errors.New("is_active must be true or false")
+	}
+	_, err := pagination.ParseSort(g.Sort, productSortColumns, "name")
+	return err
 }
 
 type ProductResponse struct {
+	Message    string                     `json:"message"`
+	Data       []ProductWithBatchListItem `json:"data,omitempty"`
+	Total      int                        `json:"total"`
+	NextCursor string                     `json:"next_cursor,omitempty"`
+}
+
+type BarcodeLookup struct {
+	Product *Product  `json:"product"`
+	Batch   *Batch    `json:"batch,omitempty"`
+	GS1     *gs1.Data `json:"gs1,omitempty"`
+}
+
+type BarcodeLookupResponse struct {
+	Message string         `json:"message"`
+	Data    *BarcodeLookup `json:"data,omitempty"`
+}
+
+type ReceiveBatchItem struct {
+	ProductID      uuid.UUID    `json:"product_id"`
+	BatchNumber    string       `json:"batch_number"`
+	Quantity       int          `json:"quantity"`
+	PurchasePrice  money.Amount `json:"purchase_price"`
+	SellingPrice   money.Amount `json:"selling_price"`
+	ExpirationDate time.Time    `json:"expiration_date"`
+}
+
+type ReceiveBatchesRequest struct {
+	PurchaseID uuid.UUID          `json:"purchase_id"`
+	SupplierID uuid.UUID          `json:"supplier_id"`
+	ReceiptID  uuid.UUID          `json:"receipt_id"`
+	ReceivedBy uuid.UUID          `json:"received_by"`
+	Items      []ReceiveBatchItem `json:"items"`
+}
+
+func (r *ReceiveBatchesRequest) Validate() error {
+	if r.PurchaseID == uuid.Nil {
+		return errors.New("purchase_id is required")
+	}
+	if r.ReceiptID == uuid.Nil {
+		return errors.New("receipt_id is required")
+	}
+	if r.SupplierID == uuid.Nil {
+		return errors.New("supplier_id is required")
+	}
+	if r.ReceivedBy == uuid.Nil {
+		return errors.New("received_by is required")
+	}
+	if len(r.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+
+	for i, item := range r.Items {
+		itemNum := i + 1
+		if item.ProductID == uuid.Nil {
+			return fmt.Errorf("product_id is required for item %d", itemNum)
+		}
+		if item.BatchNumber == "" {
+			return fmt.Errorf("batch_number is required for item %d", itemNum)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0 for item %d", itemNum)
+		}
+		if item.PurchasePrice < 0 {
+			return fmt.Errorf("purchase_price must be non-negative for item %d", itemNum)
+		}
+		if item.SellingPrice < 0 {
+			return fmt.Errorf("selling_price must be non-negative for item %d", itemNum)
+		}
+		if item.ExpirationDate.IsZero() {
+			return fmt.Errorf("expiration_date is required for item %d", itemNum)
+		}
+	}
+
+	return nil
+}
+
+type ReceiveBatchesResponse struct {
+	Message  string      `json:"message"`
+	BatchIDs []uuid.UUID `json:"batch_ids"`
+}
+
+type ReassignBatchSupplierRequest struct {
+	FromSupplierID uuid.UUID `json:"from_supplier_id"`
+	ToSupplierID   uuid.UUID `json:"to_supplier_id"`
+}
+
+func (r *ReassignBatchSupplierRequest) Validate() error {
+	if r.FromSupplierID == uuid.Nil {
+		return errors.New("from_supplier_id is required")
+	}
+	if r.ToSupplierID == uuid.Nil {
+		return errors.New("to_supplier_id is required")
+	}
+	if r.FromSupplierID == r.ToSupplierID {
+		return errors.New("from_supplier_id and to_supplier_id must differ")
+	}
+	return nil
+}
+
+type ReassignBatchSupplierResponse struct {
+	Message      string `json:"message"`
+	BatchesMoved int    `json:"batches_moved"`
+}
+
+type ReturnBatchItem struct {
+	BatchID  uuid.UUID `json:"batch_id"`
+	Quantity int       `json:"quantity"`
+}
+
+type ReturnBatchesToSupplierRequest struct {
+	SupplierID uuid.UUID         `json:"supplier_id"`
+	PurchaseID uuid.UUID         `json:"purchase_id"`
+	ReturnID   uuid.UUID         `json:"return_id"`
+	CreatedBy  uuid.UUID         `json:"created_by"`
+	Notes      string            `json:"notes"`
+	Items      []ReturnBatchItem `json:"items"`
+}
+
+func (r *ReturnBatchesToSupplierRequest) Validate() error {
+	if r.SupplierID == uuid.Nil {
+		return errors.New("supplier_id is required")
+	}
+	if r.PurchaseID == uuid.Nil {
+		return errors.New("purchase_id is required")
+	}
+	if r.ReturnID == uuid.Nil {
+		return errors.New("return_id is required")
+	}
+	if r.CreatedBy == uuid.Nil {
+		return errors.New("created_by is required")
+	}
+	if len(r.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+
+	for i, item := range r.Items {
+		itemNum := i + 1
+		if item.BatchID == uuid.Nil {
+			return fmt.Errorf("batch_id is required for item %d", itemNum)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0 for item %d", itemNum)
+		}
+	}
+	return nil
+}
+
+type ReturnedBatch struct {
+	BatchID        uuid.UUID    `json:"batch_id"`
+	ProductID      uuid.UUID    `json:"product_id"`
+	BatchNumber    string       `json:"batch_number"`
+	ExpirationDate time.Time    `json:"expiration_date"`
+	PurchasePrice  money.Amount `json:"purchase_price"`
+	Quantity       int          `json:"quantity"`
+}
+
+type ReturnBatchesToSupplierResponse struct {
+	Message string          `json:"message"`
+	Batches []ReturnedBatch `json:"batches"`
+}
+
+type DispenseItem struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+}
+
+type DispenseStockRequest struct {
+	Reason      string         `json:"reason"`
+	ReferenceID *uuid.UUID     `json:"reference_id"`
+	Notes       string         `json:"notes"`
+	CreatedBy   uuid.UUID      `json:"created_by"`
+	Items       []DispenseItem `json:"items"`
+
+	// MaxTotal refuses the dispense when the selling price of the allocated
+	// batches adds up to more, e.g. the cash handed over for a sale
+	MaxTotal *money.Amount `json:"max_total,omitempty"`
+}
+
+func (d *DispenseStockRequest) Validate() error {
+	validReasons := map[string]bool{
+		ReasonSale:     true,
+		ReasonWriteOff: true,
+		ReasonTransfer: true,
+	}
+	if !validReasons[d.Reason] {
+		return errors.New("reason must be one of: sale, write_off, transfer")
+	}
+	if d.CreatedBy == uuid.Nil {
+		return errors.New("created_by is required")
+	}
+	if len(d.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+	if d.MaxTotal != nil && *d.MaxTotal < 0 {
+		return errors.New("max_total must not be negative")
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for i, item := range d.Items {
+		itemNum := i + 1
+		if item.ProductID == uuid.Nil {
+			return fmt.Errorf("product_id is required for item %d", itemNum)
+		}
+		if seen[item.ProductID] {
+			return fmt.Errorf("product_id is duplicated for item %d", itemNum)
+		}
+		seen[item.ProductID] = true
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0 for item %d", itemNum)
+		}
+	}
+
+	return nil
+}
+
+type BatchAllocation struct {
+	BatchID        uuid.UUID    `json:"batch_id"`
+	BatchNumber    string       `json:"batch_number"`
+	ExpirationDate time.Time    `json:"expiration_date"`
+	Quantity       int          `json:"quantity"`
+	PurchasePrice  money.Amount `json:"purchase_price"`
+	SellingPrice   money.Amount `json:"selling_price"`
+}
+
+type DispensedItem struct {
+	ProductID   uuid.UUID         `json:"product_id"`
+	Quantity    int               `json:"quantity"`
+	Allocations []BatchAllocation `json:"allocations"`
+}
+
+type DispenseStockResponse struct {
+	Message string          `json:"message"`
+	Data    []DispensedItem `json:"data,omitempty"`
+}
+
+type AdjustBatchRequest struct {
+	QuantityChange int        `json:"quantity_change"`
+	Reason         string     `json:"reason"`
+	ReferenceID    *uuid.UUID `json:"reference_id"`
+	Notes          string     `json:"notes"`
+	CreatedBy      uuid.UUID  `json:"created_by"`
+}
+
+func (a *AdjustBatchRequest) Validate() error {
+	if a.QuantityChange == 0 {
+		return errors.New("quantity_change must not be 0")
+	}
+	validReasons := map[string]bool{
+		ReasonAdjustment: true,
+		ReasonReturn:     true,
+		ReasonWriteOff:   true,
+		ReasonTransfer:   true,
+	}
+	if !validReasons[a.Reason] {
+		return errors.New("reason must be one of: adjustment, return, write_off, transfer")
+	}
+	if a.CreatedBy == uuid.Nil {
+		return errors.New("created_by is required")
+	}
+	return nil
+}
+
+type StockMovementResponse struct {
+	Message string         `json:"message"`
+	Data    *StockMovement `json:"data,omitempty"`
+}
+
+type StockMovementListItem struct {
+	ID             uuid.UUID  `json:"id"`
+	BatchID        uuid.UUID  `json:"batch_id"`
+	BatchNumber    string     `json:"batch_number"`
+	Reason         string     `json:"reason"`
+	QuantityChange int        `json:"quantity_change"`
+	QuantityBefore int        `json:"quantity_before"`
+	QuantityAfter  int        `json:"quantity_after"`
+	Balance        int        `json:"balance"`
+	ReferenceID    *uuid.UUID `json:"reference_id,omitempty"`
+	Notes          string     `json:"notes"`
+	CreatedBy      *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type ListStockMovementsResponse struct {
+	Message string                  `json:"message"`
+	Data    []StockMovementListItem `json:"data"`
+}
+
+type ReturnStockItem struct {
+	BatchID  uuid.UUID `json:"batch_id"`
+	Quantity int       `json:"quantity"`
+}
+
+type ReturnStockRequest struct {
+	ReferenceID *uuid.UUID        `json:"reference_id"`
+	Notes       string            `json:"notes"`
+	CreatedBy   uuid.UUID         `json:"created_by"`
+	Items       []ReturnStockItem `json:"items"`
+}
+
+func (r *ReturnStockRequest) Validate() error {
+	if r.CreatedBy == uuid.Nil {
+		return errors.New("created_by is required")
+	}
+	if len(r.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+
+	for i, item := range r.Items {
+		itemNum := i + 1
+		if item.BatchID == uuid.Nil {
+			return fmt.Errorf("batch_id is required for item %d", itemNum)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0 for item %d", itemNum)
+		}
+	}
+
+	return nil
+}
+
+type ControlledSubstanceRegisterParams struct {
+	Year      int       `query:"year"`
+	Month     int       `query:"month"`
+	ProductID uuid.UUID `query:"product_id"`
+}
+
+func (c *ControlledSubstanceRegisterParams) Validate() error {
+	if c.Year < 2000 || c.Year > 9999 {
+		return errors.New("year is required")
+	}
+	if c.Month < 1 || c.Month > 12 {
+		return errors.New("month must be between 1 and 12")
+	}
+	return nil
+}
+
+// Period returns the start of the requested month and of the month after it
+func (c *ControlledSubstanceRegisterParams) Period() (time.Time, time.Time) {
+	return monthPeriod(c.Year, c.Month)
+}
+
+func (c *ControlledSubstanceRegisterParams) productID() *uuid.UUID {
+	if c.ProductID == uuid.Nil {
+		return nil
+	}
+	return &c.ProductID
+}
+
+type ControlledRegisterEntry struct {
+	ID            uuid.UUID  `json:"id"`
+	EntryNumber   int64      `json:"entry_number"`
+	ProductID     uuid.UUID  `json:"product_id"`
+	ProductName   string     `json:"product_name"`
+	BatchID       uuid.UUID  `json:"batch_id"`
+	BatchNumber   string     `json:"batch_number"`
+	DrugClass     string     `json:"drug_class"`
+	Reason        string     `json:"reason"`
+	QuantityIn    int        `json:"quantity_in"`
+	QuantityOut   int        `json:"quantity_out"`
+	BalanceBefore int        `json:"balance_before"`
+	BalanceAfter  int        `json:"balance_after"`
+	ReferenceID   *uuid.UUID `json:"reference_id,omitempty"`
+	CreatedBy     *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type ControlledSubstanceRegisterResponse struct {
+	Message string                    `json:"message"`
+	Data    []ControlledRegisterEntry `json:"data"`
+}
+
+type ControlledSubstanceReportLine struct {
+	ProductID      uuid.UUID `json:"product_id"`
+	ProductName    string    `json:"product_name"`
+	DrugClass      string    `json:"drug_class"`
+	OpeningBalance int       `json:"opening_balance"`
+	Receipts       int       `json:"receipts"`
+	Dispenses      int       `json:"dispenses"`
+	OtherOut       int       `json:"other_out"`
+	ClosingBalance int       `json:"closing_balance"`
+}
+
+type ControlledSubstanceReport struct {
+	Year  int                             `json:"year"`
+	Month int                             `json:"month"`
+	Lines []ControlledSubstanceReportLine `json:"lines"`
+}
+
+type ControlledSubstanceReportResponse struct {
 	Message string                     `json:"message"`
-	Data    []ProductWithBatchListItem `json:"data,omitempty"`
+	Data    *ControlledSubstanceReport `json:"data,omitempty"`
+}
+
+type ExpiringBatchesParams struct {
+	Within     string    `query:"within"`
+	CategoryID uuid.UUID `query:"category_id"`
+	SupplierID uuid.UUID `query:"supplier_id"`
+}
+
+// maxWithinDays caps the expiry warning window at ten years
+const maxWithinDays = 3650
+
+// WithinDays parses the warning window, e.g. "90d", "8w" or "3m", into a number of days
+func (e *ExpiringBatchesParams) WithinDays() (int, error) {
+	if e.Within == "" {
+		return 90, nil
+	}
+
+	unit := e.Within[len(e.Within)-1]
+	amount, err := strconv.Atoi(e.Within[:len(e.Within)-1])
+	if err != nil || amount < 0 {
+		return 0, errors.New("within must be a duration like 90d, 8w or 3m")
+	}
+	var daysPerUnit int
+	switch unit {
+	case 'd':
+		daysPerUnit = 1
+	case 'w':
+		daysPerUnit = 7
+	case 'm':
+		daysPerUnit = 30
+	default:
+		return 0, errors.New("within must be a duration like 90d, 8w or 3m")
+	}
+	if amount > maxWithinDays/daysPerUnit {
+		return 0, errors.New("within must be a duration like 90d, 8w or 3m")
+	}
+	return amount * daysPerUnit, nil
+}
+
+type ExpiringBatchListItem struct {
+	ID             uuid.UUID    `json:"id"`
+	ProductID      uuid.UUID    `json:"product_id"`
+	ProductName    string       `json:"product_name"`
+	Category       string       `json:"category"`
+	BatchNumber    string       `json:"batch_number"`
+	SupplierID     *uuid.UUID   `json:"supplier_id,omitempty"`
+	ExpirationDate time.Time    `json:"expiration_date"`
+	DaysToExpiry   int          `json:"days_to_expiry"`
+	Status         string       `json:"status"`
+	Quantity       int          `json:"quantity"`
+	PurchasePrice  money.Amount `json:"purchase_price"`
+	ValueAtRisk    money.Amount `json:"value_at_risk"`
+}
+
+type ListExpiringBatchesResponse struct {
+	Message          string                  `json:"message"`
+	WithinDays       int                     `json:"within_days"`
+	TotalQuantity    int                     `json:"total_quantity"`
+	TotalValueAtRisk money.Amount            `json:"total_value_at_risk"`
+	Data             []ExpiringBatchListItem `json:"data"`
+}
+
+type MarkExpiredBatchesResponse struct {
+	Message string `json:"message"`
+	Count   int64  `json:"count"`
+}
+
+type ReorderSuggestionsParams struct {
+	ConsumptionDays int `query:"consumption_days"`
+	CoverDays       int `query:"cover_days"`
+}
+
+func (r *ReorderSuggestionsParams) Validate() error {
+	if r.ConsumptionDays < 0 || r.ConsumptionDays > 365 {
+		return errors.New("consumption_days must be between 0 and 365 (0 uses the default)")
+	}
+	if r.CoverDays < 0 || r.CoverDays > 365 {
+		return errors.New("cover_days must be between 0 and 365 (0 uses the default)")
+	}
+	return nil
+}
+
+type ReorderSuggestion struct {
+	ProductID           uuid.UUID    `json:"product_id"`
+	ProductName         string       `json:"product_name"`
+	Category            string       `json:"category"`
+	CurrentStock        int          `json:"current_stock"`
+	MinStockLevel       int          `json:"min_stock_level"`
+	Consumption         int          `json:"consumption"`
+	AverageDailyUsage   float64      `json:"average_daily_usage"`
+	SuggestedQuantity   int          `json:"suggested_quantity"`
+	PreferredSupplierID *uuid.UUID   `json:"preferred_supplier_id,omitempty"`
+	LastPurchasePrice   money.Amount `json:"last_purchase_price"`
+	EstimatedCost       money.Amount `json:"estimated_cost"`
+}
+
+type ReorderSuggestionsResponse struct {
+	Message         string              `json:"message"`
+	ConsumptionDays int                 `json:"consumption_days"`
+	CoverDays       int                 `json:"cover_days"`
+	Data            []ReorderSuggestion `json:"data"`
+}
+
+type UpdateProductRequest struct {
+	Name                 string       `json:"name"`
+	CategoryID           uuid.UUID    `json:"category_id"`
+	Description          string       `json:"description"`
+	BasePrice            money.Amount `json:"base_price"`
+	MinStockLevel        int          `json:"min_stock_level"`
+	Barcode              string       `json:"barcode"`
+	RequiresPrescription bool         `json:"requires_prescription"`
+	DrugClass            string       `json:"drug_class"`
+}
+
+func (p *UpdateProductRequest) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(p.Name) > 255 {
+		return errors.New("name must be less than 255 characters")
+	}
+	if p.CategoryID == uuid.Nil {
+		return errors.New("category_id is required")
+	}
+	if p.BasePrice <= 0 {
+		return errors.New("base_price must be greater than 0")
+	}
+	if p.MinStockLevel < 0 {
+		return errors.New("min_stock_level must be non-negative")
+	}
+	if len(p.Barcode) > 255 {
+		return errors.New("barcode must be less than 255 characters")
+	}
+	if p.DrugClass == "" {
+		return errors.New("drug_class is required")
+	}
+	if !IsValidDrugClass(p.DrugClass) {
+		return errors.New("drug_class must be one of: regular, otc_limited, hard_drug, psychotropic, narcotic")
+	}
+	return nil
+}
+
+type UpdateProductStatusRequest struct {
+	IsActive bool `json:"is_active"`
+}
+
+type ProductDetailResponse struct {
+	Message string   `json:"message"`
+	Data    *Product `json:"data,omitempty"`
 }
@@ -0,0 +1,123 @@
+package product
+
+import (
+	"context"
+	"errors"
+
+	"encore.dev/cron"
+	"encore.dev/types/uuid"
+)
+
+// Mark batches that reached their expiration date as expired every night.
+// Cron schedules run in UTC: 17:05 UTC is 00:05 WIB.
+var _ = cron.NewJob("mark-expired-batches", cron.JobConfig{
+	Title:    "Mark expired batches",
+	Schedule: "5 17 * * *",
+	Endpoint: MarkExpiredBatches,
+})
+
+// GetExpiringBatches lists batches in stock that expire within the warning
+// window or have already expired, with the purchase value at risk
+//
+//encore:api public method=GET path=/api/batches/expiring
+func GetExpiringBatches(ctx context.Context, params *ExpiringBatchesParams) (ListExpiringBatchesResponse, error) {
+	withinDays, err := params.WithinDays()
+	if err != nil {
+		return ListExpiringBatchesResponse{Message: "Validation failed"}, err
+	}
+
+	var categoryID, supplierID *uuid.UUID
+	if params.CategoryID != uuid.Nil {
+		categoryID = &params.CategoryID
+	}
+	if params.SupplierID != uuid.Nil {
+		supplierID = &params.SupplierID
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT
+			b.id,
+			b.product_id,
+			p.name,
+			c.name as category_name,
+			b.batch_number,
+			b.supplier_id,
+			b.expiration_date,
+			b.expiration_date - CURRENT_DATE as days_to_expiry,
+			b.status,
+			b.quantity,
+			b.purchase_price
+		FROM batches b
+		JOIN products p ON p.id = b.product_id
+		LEFT JOIN categories c ON c.id = p.category_id
+		WHERE b.quantity > 0
+			AND b.expiration_date <= CURRENT_DATE + $1::int
+			AND ($2::uuid IS NULL OR p.category_id = $2)
+			AND ($3::uuid IS NULL OR b.supplier_id = $3)
+		ORDER BY b.expiration_date ASC, p.name
+	`, withinDays, categoryID, supplierID)
+	if err != nil {
+		return ListExpiringBatchesResponse{
+			Message: "Failed to retrieve expiring batches",
+			Data:    []ExpiringBatchListItem{},
+		}, errors.New("failed to retrieve expiring batches")
+	}
+	defer rows.Close()
+
+	response := ListExpiringBatchesResponse{WithinDays: withinDays}
+	for rows.Next() {
+		var batch ExpiringBatchListItem
+		var category *string
+		err = rows.Scan(
+			&batch.ID,
+			&batch.ProductID,
+			&batch.ProductName,
+			&category,
+			&batch.BatchNumber,
+			&batch.SupplierID,
+			&batch.ExpirationDate,
+			&batch.DaysToExpiry,
+			&batch.Status,
+			&batch.Quantity,
+			&batch.PurchasePrice,
+		)
+		if err != nil {
+			return ListExpiringBatchesResponse{Message: "Failed to scan batch"}, errors.New("failed to scan batch")
+		}
+		if category != nil {
+			batch.Category = *category
+		}
+
+		batch.ValueAtRisk = batch.PurchasePrice.Mul(batch.Quantity)
+		response.TotalQuantity += batch.Quantity
+		response.TotalValueAtRisk += batch.ValueAtRisk
+		response.Data = append(response.Data, batch)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListExpiringBatchesResponse{Message: "Error iterating batches"}, errors.New("error iterating batches: " + err.Error())
+	}
+
+	response.Message = "Expiring batches retrieved successfully"
+	return response, nil
+}
+
+// MarkExpiredBatches marks active batches that reached their expiration date
+// in local time as expired, so they can no longer be dispensed or sold
+//
+//encore:api private method=POST path=/internal/batches/mark-expired
+func MarkExpiredBatches(ctx context.Context) (MarkExpiredBatchesResponse, error) {
+	result, err := db.Exec(ctx, `
+		UPDATE batches
+		SET status = 'expired', updated_at = NOW()
+		WHERE status = 'active' AND expiration_date <= (NOW() AT TIME ZONE $1)::date
+	`, pharmacyLocation.String())
+	if err != nil {
+		return MarkExpiredBatchesResponse{Message: "Failed to mark expired batches"}, err
+	}
+
+	return MarkExpiredBatchesResponse{
+		Message: "Expired batches marked successfully",
+		Count:   result.RowsAffected(),
+	}, nil
+}
@@ -0,0 +1,123 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// GetReorderSuggestions lists active products whose sellable stock is below
+// their minimum stock level. The suggested order quantity restores the
+// minimum stock plus the expected usage over the cover period, based on the
+// sales of the consumption period. The preferred supplier is the one that
+// delivered most batches of the product, and the last purchase price comes
+// from the most recent batch.
+//
+//encore:api public method=GET path=/api/inventory/reorder-suggestions
+func GetReorderSuggestions(ctx context.Context, params *ReorderSuggestionsParams) (ReorderSuggestionsResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return ReorderSuggestionsResponse{Message: "Validation failed"}, err
+	}
+	consumptionDays := params.ConsumptionDays
+	if consumptionDays == 0 {
+		consumptionDays = 30
+	}
+	coverDays := params.CoverDays
+	if coverDays == 0 {
+		coverDays = 30
+	}
+
+	rows, err := db.Query(ctx, `
+		WITH stock AS (
+			SELECT product_id, SUM(quantity) as quantity
+			FROM batches
+			WHERE status = 'active' AND expiration_date > CURRENT_DATE
+			GROUP BY product_id
+		),
+		consumption AS (
+			SELECT product_id, -SUM(quantity_change) as quantity
+			FROM stock_movements
+			WHERE reason = 'sale' AND created_at >= NOW() - make_interval(days => $1)
+			GROUP BY product_id
+		),
+		preferred_supplier AS (
+			SELECT DISTINCT ON (product_id) product_id, supplier_id
+			FROM batches
+			WHERE supplier_id IS NOT NULL
+			GROUP BY product_id, supplier_id
+			ORDER BY product_id, COUNT(*) DESC, MAX(created_at) DESC
+		),
+		last_price AS (
+			SELECT DISTINCT ON (product_id) product_id, purchase_price
+			FROM batches
+			ORDER BY product_id, created_at DESC
+		)
+		SELECT
+			p.id,
+			p.name,
+			c.name as category_name,
+			COALESCE(s.quantity, 0) as current_stock,
+			p.min_stock_level,
+			COALESCE(u.quantity, 0) as consumption,
+			ps.supplier_id,
+			COALESCE(lp.purchase_price, 0) as last_purchase_price
+		FROM products p
+		LEFT JOIN categories c ON c.id = p.category_id
+		LEFT JOIN stock s ON s.product_id = p.id
+		LEFT JOIN consumption u ON u.product_id = p.id
+		LEFT JOIN preferred_supplier ps ON ps.product_id = p.id
+		LEFT JOIN last_price lp ON lp.product_id = p.id
+		WHERE p.is_active = TRUE
+			AND COALESCE(s.quantity, 0) < p.min_stock_level
+		ORDER BY p.name
+	`, consumptionDays)
+	if err != nil {
+		return ReorderSuggestionsResponse{
+			Message: "Failed to retrieve reorder suggestions",
+			Data:    []ReorderSuggestion{},
+		}, errors.New("failed to retrieve reorder suggestions: " + err.Error())
+	}
+	defer rows.Close()
+
+	var suggestions []ReorderSuggestion
+	for rows.Next() {
+		var suggestion ReorderSuggestion
+		var category *string
+		err = rows.Scan(
+			&suggestion.ProductID,
+			&suggestion.ProductName,
+			&category,
+			&suggestion.CurrentStock,
+			&suggestion.MinStockLevel,
+			&suggestion.Consumption,
+			&suggestion.PreferredSupplierID,
+			&suggestion.LastPurchasePrice,
+		)
+		if err != nil {
+			return ReorderSuggestionsResponse{Message: "Failed to scan reorder suggestion"}, errors.New("failed to scan reorder suggestion")
+		}
+		if category != nil {
+			suggestion.Category = *category
+		}
+
+		// Restore the minimum stock and cover the expected usage until the next order
+		suggestion.AverageDailyUsage = math.Round(float64(suggestion.Consumption)/float64(consumptionDays)*100) / 100
+		expectedUsage := int(math.Ceil(float64(suggestion.Consumption) / float64(consumptionDays) * float64(coverDays)))
+		suggestion.SuggestedQuantity = suggestion.MinStockLevel + expectedUsage - suggestion.CurrentStock
+		suggestion.EstimatedCost = suggestion.LastPurchasePrice.Mul(suggestion.SuggestedQuantity)
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ReorderSuggestionsResponse{Message: "Error iterating reorder suggestions"}, errors.New("error iterating reorder suggestions: " + err.Error())
+	}
+
+	return ReorderSuggestionsResponse{
+		Message:         "Reorder suggestions retrieved successfully",
+		ConsumptionDays: consumptionDays,
+		CoverDays:       coverDays,
+		Data:            suggestions,
+	}, nil
+}
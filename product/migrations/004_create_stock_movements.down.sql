@@ -0,0 +1,3 @@
+-- Drop stock_movements table
+DROP TABLE IF EXISTS stock_movements;
+DROP FUNCTION IF EXISTS prevent_stock_movement_change();
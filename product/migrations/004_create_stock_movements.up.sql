@@ -0,0 +1,38 @@
+-- Create stock_movements table
+-- id, product_id, batch_id, reason, quantity_change, quantity_before, quantity_after, reference_id, notes, created_by, created_at
+CREATE TABLE stock_movements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id),
+    batch_id UUID NOT NULL REFERENCES batches(id),
+    reason VARCHAR(30) NOT NULL CHECK (reason IN ('initial_stock', 'purchase_receipt', 'sale', 'adjustment', 'return', 'write_off', 'transfer')),
+    quantity_change INT NOT NULL CHECK (quantity_change <> 0),
+    quantity_before INT NOT NULL CHECK (quantity_before >= 0),
+    quantity_after INT NOT NULL CHECK (quantity_after >= 0),
+    reference_id UUID,
+    notes TEXT,
+    created_by UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (quantity_after = quantity_before + quantity_change)
+);
+
+-- Create indexes
+CREATE INDEX idx_stock_movements_product_id ON stock_movements(product_id, created_at);
+CREATE INDEX idx_stock_movements_batch_id ON stock_movements(batch_id);
+CREATE INDEX idx_stock_movements_reference_id ON stock_movements(reference_id);
+
+-- Stock movements are an audit trail and must never be changed
+CREATE FUNCTION prevent_stock_movement_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'stock movements are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_immutable
+    BEFORE UPDATE OR DELETE ON stock_movements
+    FOR EACH ROW EXECUTE FUNCTION prevent_stock_movement_change();
+
+-- Record the current quantity of existing batches as their initial stock
+INSERT INTO stock_movements (product_id, batch_id, reason, quantity_change, quantity_before, quantity_after, created_at)
+SELECT product_id, id, 'initial_stock', quantity, 0, quantity, created_at
+FROM batches
+WHERE quantity > 0;
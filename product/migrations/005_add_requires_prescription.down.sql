@@ -0,0 +1,2 @@
+-- Drop requires_prescription column
+ALTER TABLE products DROP COLUMN IF EXISTS requires_prescription;
@@ -0,0 +1,3 @@
+-- Mark products that can only be sold against a prescription
+ALTER TABLE products
+    ADD COLUMN requires_prescription BOOLEAN NOT NULL DEFAULT FALSE;
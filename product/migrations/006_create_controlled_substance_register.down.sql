@@ -0,0 +1,6 @@
+-- Drop controlled_substance_register table
+DROP TABLE IF EXISTS controlled_substance_register;
+DROP FUNCTION IF EXISTS prevent_controlled_register_change();
+
+-- Drop drug_class column
+ALTER TABLE products DROP COLUMN IF EXISTS drug_class;
@@ -0,0 +1,40 @@
+-- Classify products by regulatory drug class
+ALTER TABLE products
+    ADD COLUMN drug_class VARCHAR(20) NOT NULL DEFAULT 'regular'
+    CHECK (drug_class IN ('regular', 'otc_limited', 'hard_drug', 'psychotropic', 'narcotic'));
+
+CREATE INDEX idx_products_drug_class ON products(drug_class);
+
+-- Create controlled_substance_register table
+-- One entry per stock movement of a narcotic or psychotropic product, with the product-level balance
+-- id, stock_movement_id, product_id, batch_id, drug_class, reason, quantity_in, quantity_out, balance_before, balance_after, reference_id, created_by, created_at
+CREATE TABLE controlled_substance_register (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    stock_movement_id UUID NOT NULL UNIQUE REFERENCES stock_movements(id),
+    product_id UUID NOT NULL REFERENCES products(id),
+    batch_id UUID NOT NULL REFERENCES batches(id),
+    drug_class VARCHAR(20) NOT NULL CHECK (drug_class IN ('psychotropic', 'narcotic')),
+    reason VARCHAR(30) NOT NULL,
+    quantity_in INT NOT NULL DEFAULT 0 CHECK (quantity_in >= 0),
+    quantity_out INT NOT NULL DEFAULT 0 CHECK (quantity_out >= 0),
+    balance_before INT NOT NULL CHECK (balance_before >= 0),
+    balance_after INT NOT NULL CHECK (balance_after >= 0),
+    reference_id UUID,
+    created_by UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (balance_after = balance_before + quantity_in - quantity_out)
+);
+
+-- Create indexes
+CREATE INDEX idx_controlled_substance_register_product_id ON controlled_substance_register(product_id, created_at);
+
+-- The register is a legal record and must never be changed
+CREATE FUNCTION prevent_controlled_register_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'controlled substance register entries are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER controlled_substance_register_immutable
+    BEFORE UPDATE OR DELETE ON controlled_substance_register
+    FOR EACH ROW EXECUTE FUNCTION prevent_controlled_register_change();
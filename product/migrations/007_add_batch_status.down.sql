@@ -0,0 +1,2 @@
+-- Drop batch status column
+ALTER TABLE batches DROP COLUMN IF EXISTS status;
@@ -0,0 +1,8 @@
+-- Track whether a batch can still be sold
+ALTER TABLE batches
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'expired'));
+
+-- Batches are expired from their expiration date onwards
+UPDATE batches SET status = 'expired' WHERE expiration_date <= CURRENT_DATE;
+
+CREATE INDEX idx_batches_status ON batches(status);
@@ -0,0 +1,2 @@
+-- Drop parent_id column
+ALTER TABLE categories DROP COLUMN IF EXISTS parent_id;
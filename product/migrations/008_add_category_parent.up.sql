@@ -0,0 +1,7 @@
+-- Allow categories to be nested, e.g. Medications > Antibiotics > Penicillins
+ALTER TABLE categories
+    ADD COLUMN parent_id UUID REFERENCES categories(id);
+ALTER TABLE categories
+    ADD CONSTRAINT categories_parent_id_check CHECK (parent_id <> id);
+
+CREATE INDEX idx_categories_parent_id ON categories(parent_id);
@@ -0,0 +1,4 @@
+-- Restore the original money column precision
+ALTER TABLE batches ALTER COLUMN selling_price TYPE DECIMAL(10,2);
+ALTER TABLE batches ALTER COLUMN purchase_price TYPE DECIMAL(10,2);
+ALTER TABLE products ALTER COLUMN base_price TYPE DECIMAL(10,2);
@@ -0,0 +1,4 @@
+-- Widen money columns beyond the DECIMAL(10,2) ceiling of about 99 million
+ALTER TABLE products ALTER COLUMN base_price TYPE NUMERIC(15,2);
+ALTER TABLE batches ALTER COLUMN purchase_price TYPE NUMERIC(15,2);
+ALTER TABLE batches ALTER COLUMN selling_price TYPE NUMERIC(15,2);
@@ -0,0 +1,6 @@
+-- Restore the stock movement reasons without supplier_return. The ledger is
+-- immutable, so rows already recorded as supplier returns are kept and the
+-- constraint only applies to new movements.
+ALTER TABLE stock_movements DROP CONSTRAINT IF EXISTS stock_movements_reason_check;
+ALTER TABLE stock_movements
+    ADD CONSTRAINT stock_movements_reason_check CHECK (reason IN ('initial_stock', 'purchase_receipt', 'sale', 'adjustment', 'return', 'write_off', 'transfer')) NOT VALID;
@@ -0,0 +1,4 @@
+-- Stock returned to the supplier is recorded with its own reason
+ALTER TABLE stock_movements DROP CONSTRAINT IF EXISTS stock_movements_reason_check;
+ALTER TABLE stock_movements
+    ADD CONSTRAINT stock_movements_reason_check CHECK (reason IN ('initial_stock', 'purchase_receipt', 'sale', 'adjustment', 'return', 'write_off', 'transfer', 'supplier_return'));
@@ -0,0 +1,4 @@
+-- Drop register entry_number column
+DROP INDEX IF EXISTS idx_controlled_substance_register_product_entry;
+ALTER TABLE controlled_substance_register DROP COLUMN IF EXISTS entry_number;
+DROP SEQUENCE IF EXISTS controlled_substance_register_entry_seq;
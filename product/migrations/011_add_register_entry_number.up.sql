@@ -0,0 +1,26 @@
+-- Order register entries by a sequence number: entries written in one
+-- transaction share its timestamp, so created_at alone cannot order them
+CREATE SEQUENCE controlled_substance_register_entry_seq;
+
+ALTER TABLE controlled_substance_register ADD COLUMN entry_number BIGINT;
+
+-- Number the existing entries in their recorded order
+ALTER TABLE controlled_substance_register DISABLE TRIGGER controlled_substance_register_immutable;
+UPDATE controlled_substance_register r
+SET entry_number = n.entry_number
+FROM (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY created_at, id) as entry_number
+    FROM controlled_substance_register
+) n
+WHERE n.id = r.id;
+ALTER TABLE controlled_substance_register ENABLE TRIGGER controlled_substance_register_immutable;
+
+SELECT setval('controlled_substance_register_entry_seq', COALESCE((SELECT MAX(entry_number) FROM controlled_substance_register), 0) + 1, false);
+
+ALTER TABLE controlled_substance_register
+    ALTER COLUMN entry_number SET DEFAULT nextval('controlled_substance_register_entry_seq'),
+    ALTER COLUMN entry_number SET NOT NULL,
+    ADD CONSTRAINT controlled_substance_register_entry_number_key UNIQUE (entry_number);
+ALTER SEQUENCE controlled_substance_register_entry_seq OWNED BY controlled_substance_register.entry_number;
+
+CREATE INDEX idx_controlled_substance_register_product_entry ON controlled_substance_register(product_id, entry_number);
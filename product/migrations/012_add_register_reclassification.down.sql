@@ -0,0 +1,7 @@
+-- Drop reclassification entries and restore the stock movement requirement
+ALTER TABLE controlled_substance_register DISABLE TRIGGER controlled_substance_register_immutable;
+DELETE FROM controlled_substance_register WHERE stock_movement_id IS NULL;
+ALTER TABLE controlled_substance_register ENABLE TRIGGER controlled_substance_register_immutable;
+
+ALTER TABLE controlled_substance_register DROP CONSTRAINT IF EXISTS controlled_substance_register_movement_check;
+ALTER TABLE controlled_substance_register ALTER COLUMN stock_movement_id SET NOT NULL;
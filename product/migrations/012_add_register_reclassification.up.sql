@@ -0,0 +1,6 @@
+-- Reclassifying a product into or out of the controlled classes opens or
+-- closes its register with the stock on hand, without a stock movement
+ALTER TABLE controlled_substance_register ALTER COLUMN stock_movement_id DROP NOT NULL;
+ALTER TABLE controlled_substance_register
+    ADD CONSTRAINT controlled_substance_register_movement_check
+    CHECK (stock_movement_id IS NOT NULL OR reason IN ('reclassified_in', 'reclassified_out'));
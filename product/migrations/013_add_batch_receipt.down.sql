@@ -0,0 +1,4 @@
+-- Drop batch receipt tracking
+DROP INDEX IF EXISTS idx_batches_receipt_line;
+ALTER TABLE batches DROP COLUMN IF EXISTS receipt_line;
+ALTER TABLE batches DROP COLUMN IF EXISTS receipt_id;
@@ -0,0 +1,6 @@
+-- Batches created for a purchase receipt remember the receipt line they stock,
+-- so a retried receipt gets back the same batches instead of being stocked twice
+ALTER TABLE batches ADD COLUMN receipt_id UUID;
+ALTER TABLE batches ADD COLUMN receipt_line INT;
+
+CREATE UNIQUE INDEX idx_batches_receipt_line ON batches(receipt_id, receipt_line) WHERE receipt_id IS NOT NULL;
@@ -0,0 +1,199 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// Stock movement reason codes
+const (
+	ReasonInitialStock    = "initial_stock"
+	ReasonPurchaseReceipt = "purchase_receipt"
+	ReasonSale            = "sale"
+	ReasonAdjustment      = "adjustment"
+	ReasonReturn          = "return"
+	ReasonWriteOff        = "write_off"
+	ReasonTransfer        = "transfer"
+	ReasonSupplierReturn  = "supplier_return"
+)
+
+// StockMovement model
+type StockMovement struct {
+	ID             uuid.UUID  `json:"id"`
+	ProductID      uuid.UUID  `json:"product_id"`
+	BatchID        uuid.UUID  `json:"batch_id"`
+	Reason         string     `json:"reason"`
+	QuantityChange int        `json:"quantity_change"`
+	QuantityBefore int        `json:"quantity_before"`
+	QuantityAfter  int        `json:"quantity_after"`
+	ReferenceID    *uuid.UUID `json:"reference_id,omitempty"`
+	Notes          string     `json:"notes"`
+	CreatedBy      *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// AdjustBatch changes the quantity of a single batch outside of receipts and
+// sales, e.g. after a stock count, a customer return, a write-off or a transfer
+//
+//encore:api public method=POST path=/api/batches/:id/adjustments
+func AdjustBatch(ctx context.Context, id uuid.UUID, req *AdjustBatchRequest) (StockMovementResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return StockMovementResponse{Message: "Validation failed"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return StockMovementResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	movement, err := changeBatchQuantity(ctx, tx, id, req.QuantityChange, req.Reason, req.ReferenceID, &req.CreatedBy, req.Notes)
+	if err != nil {
+		return StockMovementResponse{Message: "Failed to adjust batch"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return StockMovementResponse{Message: "Failed to commit batch adjustment"}, err
+	}
+
+	return StockMovementResponse{
+		Message: "Batch adjusted successfully",
+		Data:    movement,
+	}, nil
+}
+
+// GetProductMovements retrieves the stock card of a product: every stock
+// movement of its batches in chronological order with the running balance
+//
+//encore:api public method=GET path=/api/products/:id/movements
+func GetProductMovements(ctx context.Context, id uuid.UUID) (ListStockMovementsResponse, error) {
+	// Check if product exists
+	var productExists bool
+	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE id = $1)", id).Scan(&productExists)
+	if err != nil {
+		return ListStockMovementsResponse{Message: "Failed to check product"}, err
+	}
+	if !productExists {
+		return ListStockMovementsResponse{Message: "Product not found"}, errors.New("product not found")
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT
+			m.id,
+			m.batch_id,
+			b.batch_number,
+			m.reason,
+			m.quantity_change,
+			m.quantity_before,
+			m.quantity_after,
+			SUM(m.quantity_change) OVER (ORDER BY m.created_at, m.id) as balance,
+			m.reference_id,
+			COALESCE(m.notes, ''),
+			m.created_by,
+			m.created_at
+		FROM stock_movements m
+		JOIN batches b ON b.id = m.batch_id
+		WHERE m.product_id = $1
+		ORDER BY m.created_at, m.id
+	`, id)
+	if err != nil {
+		return ListStockMovementsResponse{
+			Message: "Failed to retrieve stock movements",
+			Data:    []StockMovementListItem{},
+		}, errors.New("failed to retrieve stock movements")
+	}
+	defer rows.Close()
+
+	var movements []StockMovementListItem
+	for rows.Next() {
+		var movement StockMovementListItem
+		err = rows.Scan(
+			&movement.ID,
+			&movement.BatchID,
+			&movement.BatchNumber,
+			&movement.Reason,
+			&movement.QuantityChange,
+			&movement.QuantityBefore,
+			&movement.QuantityAfter,
+			&movement.Balance,
+			&movement.ReferenceID,
+			&movement.Notes,
+			&movement.CreatedBy,
+			&movement.CreatedAt,
+		)
+		if err != nil {
+			return ListStockMovementsResponse{Message: "Failed to scan stock movement"}, errors.New("failed to scan stock movement")
+		}
+		movements = append(movements, movement)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListStockMovementsResponse{Message: "Error iterating stock movements"}, errors.New("error iterating stock movements: " + err.Error())
+	}
+
+	return ListStockMovementsResponse{
+		Message: "Stock movements retrieved successfully",
+		Data:    movements,
+	}, nil
+}
+
+// changeBatchQuantity locks a batch, applies the quantity change and records
+// the matching stock movement. It is the only place batch quantities change
+// after the batch has been created.
+func changeBatchQuantity(ctx context.Context, tx *sqldb.Tx, batchID uuid.UUID, change int, reason string, referenceID, createdBy *uuid.UUID, notes string) (*StockMovement, error) {
+	var productID uuid.UUID
+	var before int
+	err := tx.QueryRow(ctx, "SELECT product_id, quantity FROM batches WHERE id = $1 FOR UPDATE", batchID).Scan(&productID, &before)
+	if err != nil {
+		return nil, errors.New("batch not found")
+	}
+	if before+change < 0 {
+		return nil, fmt.Errorf("%w: batch has %d, change is %d", ErrInsufficientStock, before, change)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE batches
+		SET quantity = quantity + $1, updated_at = NOW()
+		WHERE id = $2
+	`, change, batchID)
+	if err != nil {
+		return nil, errors.New("failed to update batch quantity: " + err.Error())
+	}
+
+	movement := &StockMovement{
+		ProductID:      productID,
+		BatchID:        batchID,
+		Reason:         reason,
+		QuantityChange: change,
+		QuantityBefore: before,
+		QuantityAfter:  before + change,
+		ReferenceID:    referenceID,
+		Notes:          notes,
+		CreatedBy:      createdBy,
+	}
+	if err = recordMovement(ctx, tx, movement); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// recordMovement inserts a stock movement and fills in its ID and timestamp
+func recordMovement(ctx context.Context, tx *sqldb.Tx, movement *StockMovement) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO stock_movements (product_id, batch_id, reason, quantity_change, quantity_before, quantity_after, reference_id, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`, movement.ProductID, movement.BatchID, movement.Reason, movement.QuantityChange, movement.QuantityBefore, movement.QuantityAfter, movement.ReferenceID, movement.Notes, movement.CreatedBy).Scan(&movement.ID, &movement.CreatedAt)
+	if err != nil {
+		return errors.New("failed to record stock movement: " + err.Error())
+	}
+
+	// Narcotics and psychotropics are also kept in the controlled substance register
+	return recordControlledMovement(ctx, tx, movement)
+}
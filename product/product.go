@@ -3,23 +3,30 @@ package product
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"encore.app/gs1"
+	"encore.app/money"
+	"encore.app/pagination"
 	"encore.dev/types/uuid"
 )
 
 // Product model
 type Product struct {
-	ID            uuid.UUID `json:"id"`
-	Name          string    `json:"name"`
-	CategoryID    uuid.UUID `json:"category_id"`
-	Description   string    `json:"description"`
-	BasePrice     float64   `json:"base_price"`
-	MinStockLevel int       `json:"min_stock_level"`
-	Barcode       string    `json:"barcode"`
-	IsActive      bool      `json:"is_active"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                   uuid.UUID    `json:"id"`
+	Name                 string       `json:"name"`
+	CategoryID           uuid.UUID    `json:"category_id"`
+	Description          string       `json:"description"`
+	BasePrice            money.Amount `json:"base_price"`
+	MinStockLevel        int          `json:"min_stock_level"`
+	Barcode              string       `json:"barcode"`
+	RequiresPrescription bool         `json:"requires_prescription"`
+	DrugClass            string       `json:"drug_class"`
+	IsActive             bool         `json:"is_active"`
+	CreatedAt            time.Time    `json:"created_at"`
+	UpdatedAt            time.Time    `json:"updated_at"`
 }
 
 // CreateProduct creates a new product
@@ -35,19 +42,38 @@ func CreateProduct(ctx context.Context, product *CreateProductRequest) (Response
 		return Response{Message: "Product with this name already exists"}, nil
 	}
 
+	// Products are regular drugs unless classified otherwise
+	drugClass := product.DrugClass
+	if drugClass == "" {
+		drugClass = DrugClassRegular
+	}
+	if !IsValidDrugClass(drugClass) {
+		return Response{Message: "Validation failed"}, errors.New("drug_class must be one of: regular, otc_limited, hard_drug, psychotropic, narcotic")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return Response{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
 	// Create product
 	var productID uuid.UUID
-	err = db.QueryRow(ctx, `
-		INSERT INTO products (name, category_id, description, base_price, min_stock_level, barcode, is_active) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7) 
+	err = tx.QueryRow(ctx, `
+		INSERT INTO products (name, category_id, description, base_price, min_stock_level, barcode, requires_prescription, drug_class, is_active) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) 
 		RETURNING id
-	`, product.Name, product.CategoryID, product.Description, product.SellingPrice, product.MinimumStockQuantity, product.Barcode, true).Scan(&productID)
+	`, product.Name, product.CategoryID, product.Description, product.SellingPrice, product.MinimumStockQuantity, product.Barcode, product.RequiresPrescription, drugClass, true).Scan(&productID)
 	if err != nil {
 		return Response{Message: "Failed to create product"}, err
 	}
 
-	// Create batch
-	CreateBatch(ctx, &Batch{
+	// Create batch and record its quantity as initial stock
+	var createdBy *uuid.UUID
+	if product.CreatedBy != uuid.Nil {
+		createdBy = &product.CreatedBy
+	}
+	_, err = createBatchTx(ctx, tx, &Batch{
 		ProductID:      productID,
 		BatchNumber:    product.BatchNumber,
 		Quantity:       product.StockQuantity,
@@ -56,64 +82,128 @@ func CreateProduct(ctx context.Context, product *CreateProductRequest) (Response
 		ExpirationDate: product.ExpirationDate,
 		SupplierID:     &product.SupplierID,
 		PurchaseID:     nil, // Will be set when purchase is completed
-	})
+	}, ReasonInitialStock, nil, createdBy)
+	if err != nil {
+		return Response{Message: "Failed to create batch"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Response{Message: "Failed to commit product"}, err
+	}
 
 	return Response{Message: "Product created successfully"}, nil
 }
 
-// GetAllProducts retrieves all products with aggregated batch information
+// GetAllProducts retrieves a page of products with aggregated batch
+// information. Products can be searched by name, description or barcode and
+// filtered by category (including its subcategories) and active flag.
 //
 //encore:api public method=GET path=/api/products
-func GetAllProducts(ctx context.Context) (*ProductResponse, error) {
-	query := `
-		SELECT 
-			p.id,
-			p.name,
-			c.name as category_name,
-			p.description,
-			p.min_stock_level,
-			COALESCE(SUM(b.quantity), 0) as total_quantity,
-			(
-				SELECT batch_number 
-				FROM batches 
-				WHERE product_id = p.id 
-				ORDER BY created_at DESC 
-				LIMIT 1
-			) as latest_batch_number,
-			(
-				SELECT selling_price 
-				FROM batches 
-				WHERE product_id = p.id 
-				ORDER BY created_at DESC 
-				LIMIT 1
-			) as latest_selling_price,
-			(
-				SELECT expiration_date 
-				FROM batches 
-				WHERE product_id = p.id 
-				ORDER BY expiration_date ASC 
-				LIMIT 1
-			) as earliest_expiration_date
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
-		LEFT JOIN batches b ON p.id = b.product_id
-		GROUP BY p.id, p.name, c.name, p.description, p.min_stock_level
-		ORDER BY p.name
+func GetAllProducts(ctx context.Context, params *GetAllProductsParams) (*ProductResponse, error) {
+	// Validate request
+	if err := params.Validate(); err != nil {
+		return &ProductResponse{Message: "Validation failed"}, err
+	}
+	cursor, _ := pagination.Decode(params.Cursor)
+	isActive, _ := pagination.OptionalBool(params.IsActive)
+	sort, _ := pagination.ParseSort(params.Sort, productSortColumns, "name")
+	limit := pagination.Limit(params.Limit)
+
+	var categoryID *uuid.UUID
+	if params.CategoryID != uuid.Nil {
+		categoryID = &params.CategoryID
+	}
+	filterArgs := []interface{}{categoryID, pagination.SearchPattern(params.Search), isActive}
+
+	base := `
+		WITH RECURSIVE category_tree AS (
+			SELECT id FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id FROM categories c JOIN category_tree t ON c.parent_id = t.id
+		),
+		product_list AS (
+			SELECT 
+				p.id,
+				p.name,
+				c.name as category_name,
+				p.description,
+				p.min_stock_level,
+				COALESCE(SUM(b.quantity), 0) as total_quantity,
+				(
+					SELECT batch_number 
+					FROM batches 
+					WHERE product_id = p.id 
+					ORDER BY created_at DESC 
+					LIMIT 1
+				) as latest_batch_number,
+				(
+					SELECT selling_price 
+					FROM batches 
+					WHERE product_id = p.id 
+					ORDER BY created_at DESC 
+					LIMIT 1
+				) as latest_selling_price,
+				(
+					SELECT expiration_date 
+					FROM batches 
+					WHERE product_id = p.id 
+					ORDER BY expiration_date ASC 
+					LIMIT 1
+				) as earliest_expiration_date,
+				COALESCE(p.is_active, false) as is_active,
+				p.base_price,
+				p.created_at
+			FROM products p
+			LEFT JOIN categories c ON p.category_id = c.id
+			LEFT JOIN batches b ON p.id = b.product_id
+			WHERE ($1::uuid IS NULL OR p.category_id IN (SELECT id FROM category_tree))
+				AND ($2::text IS NULL OR p.name ILIKE $2 OR p.description ILIKE $2 OR p.barcode ILIKE $2)
+				AND ($3::boolean IS NULL OR COALESCE(p.is_active, false) = $3)
+			GROUP BY p.id, p.name, c.name, p.description, p.min_stock_level
+		)
 	`
 
-	rows, err := db.Query(ctx, query)
+	var total int
+	err := db.QueryRow(ctx, base+"SELECT COUNT(*) FROM product_list", filterArgs...).Scan(&total)
+	if err != nil {
+		return nil, errors.New("failed to count products: " + err.Error())
+	}
+
+	cursorValue, cursorID := pagination.Args(cursor)
+	query := base + fmt.Sprintf(`
+		SELECT
+			id,
+			name,
+			category_name,
+			description,
+			min_stock_level,
+			total_quantity,
+			latest_batch_number,
+			latest_selling_price,
+			earliest_expiration_date,
+			is_active,
+			(%s)::text as sort_value
+		FROM product_list
+		WHERE %s
+		ORDER BY %s
+		LIMIT $6
+	`, sort.Column.Expr, sort.After("id", 4, 5), sort.OrderBy("id"))
+
+	rows, err := db.Query(ctx, query, append(filterArgs, cursorValue, cursorID, limit+1)...)
 	if err != nil {
 		return nil, errors.New("failed to retrieve products: " + err.Error())
 	}
 	defer rows.Close()
 
+	var sortValues []string
 	var products []ProductWithBatchListItem
 	for rows.Next() {
 		var product ProductWithBatchListItem
 		var totalQuantity int
 		var nullableBatchNumber *string
-		var nullableSellingPrice *float64
+		var nullableSellingPrice *money.Amount
 		var nullableExpirationDate *time.Time
+		var sortValue string
 
 		err := rows.Scan(
 			&product.ID,
@@ -125,6 +215,8 @@ func GetAllProducts(ctx context.Context) (*ProductResponse, error) {
 			&nullableBatchNumber,
 			&nullableSellingPrice,
 			&nullableExpirationDate,
+			&product.IsActive,
+			&sortValue,
 		)
 		if err != nil {
 			return nil, errors.New("failed to scan product: " + err.Error())
@@ -143,13 +235,26 @@ func GetAllProducts(ctx context.Context) (*ProductResponse, error) {
 		}
 
 		products = append(products, product)
+		sortValues = append(sortValues, sortValue)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, errors.New("error iterating products: " + err.Error())
 	}
 
-	return &ProductResponse{Message: "Products retrieved successfully", Data: products}, nil
+	// The extra row only tells whether there is a next page
+	var nextCursor string
+	if len(products) > limit {
+		products = products[:limit]
+		nextCursor = pagination.Encode(sortValues[limit-1], products[limit-1].ID)
+	}
+
+	return &ProductResponse{
+		Message:    "Products retrieved successfully",
+		Data:       products,
+		Total:      total,
+		NextCursor: nextCursor,
+	}, nil
 }
 
 // GetProduct retrieves a product by ID
@@ -158,7 +263,7 @@ func GetAllProducts(ctx context.Context) (*ProductResponse, error) {
 func GetProduct(ctx context.Context, id uuid.UUID) (*Product, error) {
 	var product Product
 	err := db.QueryRow(ctx, `
-		SELECT id, name, category_id, description, base_price, min_stock_level, barcode, is_active, created_at, updated_at 
+		SELECT id, name, category_id, description, base_price, min_stock_level, barcode, requires_prescription, drug_class, is_active, created_at, updated_at 
 		FROM products 
 		WHERE id = $1
 	`, id).Scan(
@@ -169,6 +274,8 @@ func GetProduct(ctx context.Context, id uuid.UUID) (*Product, error) {
 		&product.BasePrice,
 		&product.MinStockLevel,
 		&product.Barcode,
+		&product.RequiresPrescription,
+		&product.DrugClass,
 		&product.IsActive,
 		&product.CreatedAt,
 		&product.UpdatedAt,
@@ -188,3 +295,278 @@ func IsProductExists(ctx context.Context, name string) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// GetProductByBarcode retrieves an active product by its barcode
+//
+//encore:api private method=GET path=/internal/products/barcode/:code
+func GetProductByBarcode(ctx context.Context, code string) (*Product, error) {
+	return findProductByBarcode(ctx, []string{code})
+}
+
+// LookupBarcode resolves a scanned code at the counter. Plain EAN/UPC
+// barcodes resolve to the product. GS1 DataMatrix and GS1-128 payloads resolve
+// through their GTIN to the product and, when they carry a batch number, to
+// the exact batch. Expired batches are refused.
+//
+//encore:api public method=GET path=/api/products/by-barcode/:code
+func LookupBarcode(ctx context.Context, code string) (BarcodeLookupResponse, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return BarcodeLookupResponse{Message: "Validation failed"}, errors.New("code is required")
+	}
+
+	if !gs1.IsElementString(code) {
+		product, err := findProductByBarcode(ctx, []string{code})
+		if err != nil {
+			return BarcodeLookupResponse{Message: "Product not found"}, err
+		}
+		return BarcodeLookupResponse{
+			Message: "Product retrieved successfully",
+			Data:    &BarcodeLookup{Product: product},
+		}, nil
+	}
+
+	parsed, err := gs1.Parse(code)
+	if err != nil {
+		return BarcodeLookupResponse{Message: "Invalid GS1 code"}, err
+	}
+	product, err := findProductByBarcode(ctx, gs1.GTINCandidates(parsed.GTIN))
+	if err != nil {
+		return BarcodeLookupResponse{Message: "Product not found"}, err
+	}
+	lookup := &BarcodeLookup{Product: product, GS1: parsed}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if parsed.Expiry != nil && !parsed.Expiry.After(today) {
+		return BarcodeLookupResponse{Message: "Batch is expired", Data: lookup}, errors.New("batch is expired")
+	}
+	if parsed.BatchNumber == "" {
+		return BarcodeLookupResponse{Message: "Product retrieved successfully", Data: lookup}, nil
+	}
+
+	// Prefer the batch whose expiry matches the code when batch numbers repeat
+	var batch Batch
+	err = db.QueryRow(ctx, `
+		SELECT id, product_id, batch_number, quantity, purchase_price, selling_price, expiration_date, supplier_id, purchase_id, status, created_at, updated_at
+		FROM batches
+		WHERE product_id = $1 AND batch_number = $2
+		ORDER BY ($3::date IS NOT NULL AND expiration_date = $3) DESC, created_at DESC
+		LIMIT 1
+	`, product.ID, parsed.BatchNumber, parsed.Expiry).Scan(
+		&batch.ID,
+		&batch.ProductID,
+		&batch.BatchNumber,
+		&batch.Quantity,
+		&batch.PurchasePrice,
+		&batch.SellingPrice,
+		&batch.ExpirationDate,
+		&batch.SupplierID,
+		&batch.PurchaseID,
+		&batch.Status,
+		&batch.CreatedAt,
+		&batch.UpdatedAt,
+	)
+	if err != nil {
+		return BarcodeLookupResponse{Message: "Batch not found", Data: lookup}, errors.New("batch not found")
+	}
+	lookup.Batch = &batch
+
+	if batch.Status == BatchStatusExpired || !batch.ExpirationDate.After(today) {
+		return BarcodeLookupResponse{Message: "Batch is expired", Data: lookup}, errors.New("batch is expired")
+	}
+
+	return BarcodeLookupResponse{
+		Message: "Batch retrieved successfully",
+		Data:    lookup,
+	}, nil
+}
+
+// findProductByBarcode retrieves the oldest active product with one of the given barcodes
+func findProductByBarcode(ctx context.Context, codes []string) (*Product, error) {
+	var product Product
+	err := db.QueryRow(ctx, `
+		SELECT id, name, category_id, description, base_price, min_stock_level, barcode, requires_prescription, drug_class, is_active, created_at, updated_at 
+		FROM products 
+		WHERE barcode = ANY($1) AND is_active = TRUE
+		ORDER BY created_at
+		LIMIT 1
+	`, codes).Scan(
+		&product.ID,
+		&product.Name,
+		&product.CategoryID,
+		&product.Description,
+		&product.BasePrice,
+		&product.MinStockLevel,
+		&product.Barcode,
+		&product.RequiresPrescription,
+		&product.DrugClass,
+		&product.IsActive,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+	return &product, nil
+}
+
+// UpdateProduct updates the details of a product. A drug class change into or
+// out of the controlled classes opens or closes the product's register with
+// the stock on hand.
+//
+//encore:api public method=PUT path=/api/products/:id
+func UpdateProduct(ctx context.Context, id uuid.UUID, req *UpdateProductRequest) (ProductDetailResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return ProductDetailResponse{Message: "Validation failed"}, err
+	}
+
+	// Check if another product already uses this name
+	var nameTaken bool
+	err := db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM products WHERE name = $1 AND id <> $2)", req.Name, id).Scan(&nameTaken)
+	if err != nil {
+		return ProductDetailResponse{Message: "Failed to check product name"}, err
+	}
+	if nameTaken {
+		return ProductDetailResponse{Message: "Product with this name already exists"}, errors.New("product with this name already exists")
+	}
+
+	// Check if category exists
+	var categoryExists bool
+	err = db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1)", req.CategoryID).Scan(&categoryExists)
+	if err != nil {
+		return ProductDetailResponse{Message: "Failed to check category"}, err
+	}
+	if !categoryExists {
+		return ProductDetailResponse{Message: "Category not found"}, errors.New("category not found")
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return ProductDetailResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	var drugClass string
+	err = tx.QueryRow(ctx, "SELECT drug_class FROM products WHERE id = $1", id).Scan(&drugClass)
+	if err != nil {
+		return ProductDetailResponse{Message: "Product not found"}, errors.New("product not found")
+	}
+	reclassified := IsControlledDrugClass(drugClass) != IsControlledDrugClass(req.DrugClass)
+
+	// Moving into or out of the controlled classes records the stock on hand in
+	// the register. Batches are locked before the product, in the same order
+	// as stock movements, so no movement slips in between.
+	var batches []Batch
+	if reclassified {
+		batches, err = lockProductBatches(ctx, tx, id)
+		if err != nil {
+			return ProductDetailResponse{Message: "Failed to lock batches"}, err
+		}
+	}
+
+	var lockedDrugClass string
+	err = tx.QueryRow(ctx, "SELECT drug_class FROM products WHERE id = $1 FOR UPDATE", id).Scan(&lockedDrugClass)
+	if err != nil {
+		return ProductDetailResponse{Message: "Product not found"}, errors.New("product not found")
+	}
+	if lockedDrugClass != drugClass {
+		return ProductDetailResponse{Message: "Product was changed concurrently"}, errors.New("drug_class was changed concurrently, try again")
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE products
+		SET name = $1, category_id = $2, description = $3, base_price = $4, min_stock_level = $5, barcode = $6,
+			requires_prescription = $7, drug_class = $8, updated_at = NOW()
+		WHERE id = $9
+	`, req.Name, req.CategoryID, req.Description, req.BasePrice, req.MinStockLevel, req.Barcode, req.RequiresPrescription, req.DrugClass, id)
+	if err != nil {
+		return ProductDetailResponse{Message: "Failed to update product"}, err
+	}
+
+	if reclassified {
+		if err = recordReclassification(ctx, tx, id, drugClass, req.DrugClass, batches); err != nil {
+			return ProductDetailResponse{Message: "Failed to update controlled substance register"}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return ProductDetailResponse{Message: "Failed to commit product"}, err
+	}
+
+	product, err := GetProduct(ctx, id)
+	if err != nil {
+		return ProductDetailResponse{Message: "Product not found"}, err
+	}
+	return ProductDetailResponse{
+		Message: "Product updated successfully",
+		Data:    product,
+	}, nil
+}
+
+// UpdateProductStatus activates or deactivates a product
+//
+//encore:api public method=PATCH path=/api/products/:id/status
+func UpdateProductStatus(ctx context.Context, id uuid.UUID, req *UpdateProductStatusRequest) (Response, error) {
+	result, err := db.Exec(ctx, `
+		UPDATE products
+		SET is_active = $1, updated_at = NOW()
+		WHERE id = $2
+	`, req.IsActive, id)
+	if err != nil {
+		return Response{Message: "Failed to update product status"}, err
+	}
+	if result.RowsAffected() == 0 {
+		return Response{Message: "Product not found"}, errors.New("product not found")
+	}
+
+	if req.IsActive {
+		return Response{Message: "Product activated successfully"}, nil
+	}
+	return Response{Message: "Product deactivated successfully"}, nil
+}
+
+// DeleteProduct deletes an inactive product that has never been stocked.
+// Products with batches must stay deactivated instead. Other services refuse
+// inactive products, so the references they hold, checked by the catalog
+// service before it calls this endpoint, cannot change in the meantime.
+//
+//encore:api private method=DELETE path=/internal/products/:id
+func DeleteProduct(ctx context.Context, id uuid.UUID) (Response, error) {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return Response{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the product so it cannot be reactivated while it is deleted
+	var isActive bool
+	err = tx.QueryRow(ctx, "SELECT is_active FROM products WHERE id = $1 FOR UPDATE", id).Scan(&isActive)
+	if err != nil {
+		return Response{Message: "Product not found"}, errors.New("product not found")
+	}
+	if isActive {
+		return Response{Message: "Product is active, deactivate it first"}, errors.New("only inactive products can be deleted")
+	}
+
+	// Check if any batch references the product
+	var hasBatches bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM batches WHERE product_id = $1)", id).Scan(&hasBatches)
+	if err != nil {
+		return Response{Message: "Failed to check batches"}, err
+	}
+	if hasBatches {
+		return Response{Message: "Product has batches, keep it deactivated instead"}, errors.New("product is referenced by batches")
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM products WHERE id = $1", id)
+	if err != nil {
+		return Response{Message: "Failed to delete product"}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Response{Message: "Failed to commit product deletion"}, err
+	}
+
+	return Response{Message: "Product deleted successfully"}, nil
+}
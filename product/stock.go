@@ -0,0 +1,179 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"encore.app/money"
+	"encore.dev/storage/sqldb"
+	"encore.dev/types/uuid"
+)
+
+// ErrInsufficientStock is returned when the non-expired batches of a product
+// cannot cover the requested quantity
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// DispenseStock takes stock out of the product batches using FEFO (first
+// expired, first out): the requested quantity is drawn from non-expired
+// batches with the earliest expiration date first. All items are dispensed in
+// a single transaction, so either every item is allocated or none is. With
+// max_total set, nothing is dispensed when the batches' selling total is higher.
+// Stock only leaves through sales; manual write-offs and transfers go through
+// AdjustBatch.
+//
+//encore:api private method=POST path=/internal/stock/dispense
+func DispenseStock(ctx context.Context, req *DispenseStockRequest) (DispenseStockResponse, error) {
+	// Stock leaves through sales unless another reason is given
+	if req.Reason == "" {
+		req.Reason = ReasonSale
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return DispenseStockResponse{Message: "Validation failed"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return DispenseStockResponse{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	// Lock products in a stable order so concurrent multi-item requests cannot deadlock
+	order := make([]int, len(req.Items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return req.Items[order[a]].ProductID.String() < req.Items[order[b]].ProductID.String()
+	})
+
+	dispensed := make([]DispensedItem, len(req.Items))
+	var total money.Amount
+	for _, i := range order {
+		item := req.Items[i]
+		allocations, err := allocateFEFO(ctx, tx, item.ProductID, item.Quantity, req)
+		if err != nil {
+			if errors.Is(err, ErrInsufficientStock) {
+				return DispenseStockResponse{Message: "Insufficient stock for product: " + item.ProductID.String()}, err
+			}
+			return DispenseStockResponse{Message: "Failed to dispense stock"}, err
+		}
+		dispensed[i] = DispensedItem{
+			ProductID:   item.ProductID,
+			Quantity:    item.Quantity,
+			Allocations: allocations,
+		}
+		for _, allocation := range allocations {
+			total += allocation.SellingPrice.Mul(allocation.Quantity)
+		}
+	}
+
+	// Rolling back leaves no trace in the stock ledger or the register
+	if req.MaxTotal != nil && total > *req.MaxTotal {
+		return DispenseStockResponse{Message: "Selling total exceeds max_total"}, fmt.Errorf("selling total %s exceeds max_total %s", total, *req.MaxTotal)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return DispenseStockResponse{Message: "Failed to commit stock dispense"}, err
+	}
+
+	return DispenseStockResponse{
+		Message: "Stock dispensed successfully",
+		Data:    dispensed,
+	}, nil
+}
+
+// allocateFEFO locks the sellable batches of a product and decrements them,
+// earliest expiration date first, until the requested quantity is covered.
+// Every decrement is recorded as a stock movement.
+func allocateFEFO(ctx context.Context, tx *sqldb.Tx, productID uuid.UUID, quantity int, req *DispenseStockRequest) ([]BatchAllocation, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id, batch_number, expiration_date, quantity, purchase_price, selling_price
+		FROM batches
+		WHERE product_id = $1
+			AND quantity > 0
+			AND expiration_date > CURRENT_DATE
+			AND status = 'active'
+		ORDER BY expiration_date ASC, created_at ASC, id ASC
+		FOR UPDATE
+	`, productID)
+	if err != nil {
+		return nil, errors.New("failed to retrieve batches: " + err.Error())
+	}
+	defer rows.Close()
+
+	var allocations []BatchAllocation
+	remaining := quantity
+	for rows.Next() && remaining > 0 {
+		var batch BatchAllocation
+		var available int
+		err = rows.Scan(
+			&batch.BatchID,
+			&batch.BatchNumber,
+			&batch.ExpirationDate,
+			&available,
+			&batch.PurchasePrice,
+			&batch.SellingPrice,
+		)
+		if err != nil {
+			return nil, errors.New("failed to scan batch: " + err.Error())
+		}
+
+		batch.Quantity = available
+		if batch.Quantity > remaining {
+			batch.Quantity = remaining
+		}
+		remaining -= batch.Quantity
+		allocations = append(allocations, batch)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New("error iterating batches: " + err.Error())
+	}
+	rows.Close()
+
+	if remaining > 0 {
+		return nil, fmt.Errorf("%w: %d requested, %d available", ErrInsufficientStock, quantity, quantity-remaining)
+	}
+
+	for _, allocation := range allocations {
+		_, err = changeBatchQuantity(ctx, tx, allocation.BatchID, -allocation.Quantity, req.Reason, req.ReferenceID, &req.CreatedBy, req.Notes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return allocations, nil
+}
+
+// ReturnStock puts stock back into the batches it was dispensed from, e.g.
+// when a sale is rolled back or a customer returns goods
+//
+//encore:api private method=POST path=/internal/stock/return
+func ReturnStock(ctx context.Context, req *ReturnStockRequest) (*Response, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return &Response{Message: "Validation failed"}, err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return &Response{Message: "Failed to start transaction"}, err
+	}
+	defer tx.Rollback()
+
+	for _, item := range req.Items {
+		_, err = changeBatchQuantity(ctx, tx, item.BatchID, item.Quantity, ReasonReturn, req.ReferenceID, &req.CreatedBy, req.Notes)
+		if err != nil {
+			return &Response{Message: "Failed to return stock"}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return &Response{Message: "Failed to commit stock return"}, err
+	}
+
+	return &Response{Message: "Stock returned successfully"}, nil
+}
@@ -0,0 +1,7 @@
+package sales
+
+import "encore.dev/storage/sqldb"
+
+var db = sqldb.NewDatabase("sales", sqldb.DatabaseConfig{
+	Migrations: "./migrations",
+})
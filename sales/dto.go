@@ -0,0 +1,110 @@
+package sales
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/money"
+	"encore.dev/types/uuid"
+)
+
+type SaleItemRequest struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Barcode   string    `json:"barcode"`
+	Quantity  int       `json:"quantity"`
+}
+
+type CreateSaleRequest struct {
+	Items          []SaleItemRequest `json:"items"`
+	PaymentMethod  string            `json:"payment_method"`
+	AmountPaid     money.Amount      `json:"amount_paid"`
+	Notes          string            `json:"notes"`
+	PrescriptionID *uuid.UUID        `json:"prescription_id"`
+	CashierID      uuid.UUID         `json:"cashier_id"`
+}
+
+func (s *CreateSaleRequest) Validate() error {
+	if len(s.Items) == 0 {
+		return errors.New("at least one item is required")
+	}
+	validPaymentMethods := map[string]bool{
+		"cash":        true,
+		"debit_card":  true,
+		"credit_card": true,
+		"qris":        true,
+		"transfer":    true,
+	}
+	if !validPaymentMethods[s.PaymentMethod] {
+		return errors.New("payment_method must be one of: cash, debit_card, credit_card, qris, transfer")
+	}
+	if s.AmountPaid < 0 {
+		return errors.New("amount_paid must be non-negative")
+	}
+	if s.CashierID == uuid.Nil {
+		return errors.New("cashier_id is required")
+	}
+
+	for i, item := range s.Items {
+		itemNum := i + 1
+		if item.ProductID == uuid.Nil && item.Barcode == "" {
+			return fmt.Errorf("product_id or barcode is required for item %d", itemNum)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0 for item %d", itemNum)
+		}
+	}
+
+	return nil
+}
+
+type ReceiptBatch struct {
+	BatchID        uuid.UUID    `json:"batch_id"`
+	BatchNumber    string       `json:"batch_number"`
+	ExpirationDate time.Time    `json:"expiration_date"`
+	Quantity       int          `json:"quantity"`
+	UnitPrice      money.Amount `json:"unit_price"`
+}
+
+type ReceiptItem struct {
+	ProductID   uuid.UUID      `json:"product_id"`
+	ProductName string         `json:"product_name"`
+	Barcode     string         `json:"barcode"`
+	Quantity    int            `json:"quantity"`
+	TotalPrice  money.Amount   `json:"total_price"`
+	Batches     []ReceiptBatch `json:"batches"`
+}
+
+type Receipt struct {
+	ID             uuid.UUID     `json:"id"`
+	SaleNumber     string        `json:"sale_number"`
+	SaleDate       time.Time     `json:"sale_date"`
+	Items          []ReceiptItem `json:"items"`
+	TotalAmount    money.Amount  `json:"total_amount"`
+	PaymentMethod  string        `json:"payment_method"`
+	AmountPaid     money.Amount  `json:"amount_paid"`
+	ChangeAmount   money.Amount  `json:"change_amount"`
+	Notes          string        `json:"notes"`
+	PrescriptionID *uuid.UUID    `json:"prescription_id,omitempty"`
+	CashierID      uuid.UUID     `json:"cashier_id"`
+}
+
+type SaleResponse struct {
+	Message string   `json:"message"`
+	Data    *Receipt `json:"data,omitempty"`
+}
+
+type SaleListItem struct {
+	ID            uuid.UUID    `json:"id"`
+	SaleNumber    string       `json:"sale_number"`
+	SaleDate      time.Time    `json:"sale_date"`
+	TotalAmount   money.Amount `json:"total_amount"`
+	PaymentMethod string       `json:"payment_method"`
+	TotalItem     int          `json:"total_item"`
+	CashierID     uuid.UUID    `json:"cashier_id"`
+}
+
+type ListSalesResponse struct {
+	Message string         `json:"message"`
+	Data    []SaleListItem `json:"data"`
+}
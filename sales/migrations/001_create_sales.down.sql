@@ -0,0 +1,3 @@
+-- Drop sales table
+DROP TABLE IF EXISTS sales;
+DROP SEQUENCE IF EXISTS sale_number_seq;
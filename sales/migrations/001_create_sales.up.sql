@@ -0,0 +1,22 @@
+-- Sequence used to generate sale numbers
+CREATE SEQUENCE sale_number_seq;
+
+-- Create sales table
+-- id, sale_number, sale_date, total_amount, payment_method, amount_paid, change_amount, notes, cashier_id, created_at, updated_at
+CREATE TABLE sales (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    sale_number VARCHAR(50) NOT NULL UNIQUE,
+    sale_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    total_amount DECIMAL(10,2) NOT NULL CHECK (total_amount >= 0),
+    payment_method VARCHAR(20) NOT NULL CHECK (payment_method IN ('cash', 'debit_card', 'credit_card', 'qris', 'transfer')),
+    amount_paid DECIMAL(10,2) NOT NULL CHECK (amount_paid >= 0),
+    change_amount DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (change_amount >= 0),
+    notes TEXT,
+    cashier_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_sales_sale_date ON sales(sale_date);
+CREATE INDEX idx_sales_cashier_id ON sales(cashier_id);
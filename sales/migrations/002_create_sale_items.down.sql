@@ -0,0 +1,2 @@
+-- Drop sale_items table
+DROP TABLE IF EXISTS sale_items;
@@ -0,0 +1,16 @@
+-- Create sale_items table
+-- id, sale_id, product_id, product_name, barcode, quantity, total_price, created_at
+CREATE TABLE sale_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    sale_id UUID NOT NULL REFERENCES sales(id),
+    product_id UUID NOT NULL,
+    product_name VARCHAR(255) NOT NULL,
+    barcode VARCHAR(255),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    total_price DECIMAL(10,2) NOT NULL CHECK (total_price >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_sale_items_sale_id ON sale_items(sale_id);
+CREATE INDEX idx_sale_items_product_id ON sale_items(product_id);
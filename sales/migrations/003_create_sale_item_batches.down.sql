@@ -0,0 +1,2 @@
+-- Drop sale_item_batches table
+DROP TABLE IF EXISTS sale_item_batches;
@@ -0,0 +1,17 @@
+-- Create sale_item_batches table
+-- Records which product batches a sale item was drawn from and at what price
+-- id, sale_item_id, batch_id, batch_number, expiration_date, quantity, unit_price, created_at
+CREATE TABLE sale_item_batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    sale_item_id UUID NOT NULL REFERENCES sale_items(id),
+    batch_id UUID NOT NULL,
+    batch_number VARCHAR(255) NOT NULL,
+    expiration_date DATE NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10,2) NOT NULL CHECK (unit_price >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX idx_sale_item_batches_sale_item_id ON sale_item_batches(sale_item_id);
+CREATE INDEX idx_sale_item_batches_batch_id ON sale_item_batches(batch_id);
@@ -0,0 +1,3 @@
+-- Drop prescription columns
+ALTER TABLE sales DROP COLUMN IF EXISTS prescription_fill_id;
+ALTER TABLE sales DROP COLUMN IF EXISTS prescription_id;
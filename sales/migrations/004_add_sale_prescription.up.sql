@@ -0,0 +1,5 @@
+-- Link sales of prescription-only products to the prescription they were filled against
+ALTER TABLE sales ADD COLUMN prescription_id UUID;
+ALTER TABLE sales ADD COLUMN prescription_fill_id UUID;
+
+CREATE INDEX idx_sales_prescription_id ON sales(prescription_id);
@@ -0,0 +1,6 @@
+-- Restore the original money column precision
+ALTER TABLE sale_item_batches ALTER COLUMN unit_price TYPE DECIMAL(10,2);
+ALTER TABLE sale_items ALTER COLUMN total_price TYPE DECIMAL(10,2);
+ALTER TABLE sales ALTER COLUMN change_amount TYPE DECIMAL(10,2);
+ALTER TABLE sales ALTER COLUMN amount_paid TYPE DECIMAL(10,2);
+ALTER TABLE sales ALTER COLUMN total_amount TYPE DECIMAL(10,2);
@@ -0,0 +1,6 @@
+-- Widen money columns beyond the DECIMAL(10,2) ceiling of about 99 million
+ALTER TABLE sales ALTER COLUMN total_amount TYPE NUMERIC(15,2);
+ALTER TABLE sales ALTER COLUMN amount_paid TYPE NUMERIC(15,2);
+ALTER TABLE sales ALTER COLUMN change_amount TYPE NUMERIC(15,2);
+ALTER TABLE sale_items ALTER COLUMN total_price TYPE NUMERIC(15,2);
+ALTER TABLE sale_item_batches ALTER COLUMN unit_price TYPE NUMERIC(15,2);
@@ -0,0 +1,461 @@
+package sales
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"encore.app/money"
+	"encore.app/prescriptions"
+	"encore.app/product"
+	"encore.dev/rlog"
+	"encore.dev/types/uuid"
+)
+
+// Sale model
+type Sale struct {
+	ID                 uuid.UUID    `json:"id"`
+	SaleNumber         string       `json:"sale_number"`
+	SaleDate           time.Time    `json:"sale_date"`
+	TotalAmount        money.Amount `json:"total_amount"`
+	PaymentMethod      string       `json:"payment_method"`
+	AmountPaid         money.Amount `json:"amount_paid"`
+	ChangeAmount       money.Amount `json:"change_amount"`
+	Notes              string       `json:"notes"`
+	PrescriptionID     *uuid.UUID   `json:"prescription_id,omitempty"`
+	PrescriptionFillID *uuid.UUID   `json:"prescription_fill_id,omitempty"`
+	CashierID          uuid.UUID    `json:"cashier_id"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+}
+
+// SaleItem model
+type SaleItem struct {
+	ID          uuid.UUID    `json:"id"`
+	SaleID      uuid.UUID    `json:"sale_id"`
+	ProductID   uuid.UUID    `json:"product_id"`
+	ProductName string       `json:"product_name"`
+	Barcode     string       `json:"barcode"`
+	Quantity    int          `json:"quantity"`
+	TotalPrice  money.Amount `json:"total_price"`
+	CreatedAt   time.Time    `json:"created_at"`
+
+	// RequiresPrescription is resolved from the product and not stored with the sale
+	RequiresPrescription bool `json:"-"`
+}
+
+// CreateSale records a sale at the counter. Line items are resolved by product
+// ID or barcode, stock is taken from the product batches (earliest expiry
+// first) at the batch selling price, and the receipt is returned.
+// Prescription-only products are refused unless the sale is linked to a
+// valid prescription that still covers them.
+//
+//encore:api public method=POST path=/api/sales
+func CreateSale(ctx context.Context, req *CreateSaleRequest) (SaleResponse, error) {
+	// Validate request
+	if err := req.Validate(); err != nil {
+		return SaleResponse{Message: "Validation failed"}, err
+	}
+
+	// Resolve products and merge lines scanned more than once
+	items, err := resolveSaleItems(ctx, req.Items)
+	if err != nil {
+		return SaleResponse{Message: err.Error()}, err
+	}
+
+	saleID, err := uuid.NewV4()
+	if err != nil {
+		return SaleResponse{Message: "Failed to generate sale ID"}, err
+	}
+
+	// Fill the prescription for prescription-only products
+	fillID, err := fillPrescription(ctx, saleID, req, items)
+	if err != nil {
+		return SaleResponse{Message: "Prescription check failed"}, err
+	}
+
+	// Take the stock out of the product batches
+	dispenseItems := make([]product.DispenseItem, 0, len(items))
+	for _, item := range items {
+		dispenseItems = append(dispenseItems, product.DispenseItem{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+	dispenseReq := &product.DispenseStockRequest{
+		Reason:      product.ReasonSale,
+		ReferenceID: &saleID,
+		CreatedBy:   req.CashierID,
+		Items:       dispenseItems,
+	}
+	// Cash must cover the batch prices before any stock leaves
+	if req.PaymentMethod == "cash" {
+		dispenseReq.MaxTotal = &req.AmountPaid
+	}
+	dispensed, err := product.DispenseStock(ctx, dispenseReq)
+	if err != nil {
+		reversePrescriptionFill(ctx, fillID)
+		return SaleResponse{Message: "Failed to dispense stock"}, err
+	}
+
+	receipt, err := saveSale(ctx, saleID, req, items, dispensed.Data, fillID)
+	if err != nil {
+		// The sale was not recorded, so put the stock back and undo the fill
+		returnDispensedStock(ctx, saleID, req.CashierID, dispensed.Data)
+		reversePrescriptionFill(ctx, fillID)
+		return SaleResponse{Message: "Failed to create sale"}, err
+	}
+
+	return SaleResponse{
+		Message: "Sale created successfully",
+		Data:    receipt,
+	}, nil
+}
+
+// GetAllSales retrieves all sales, most recent first
+//
+//encore:api public method=GET path=/api/sales
+func GetAllSales(ctx context.Context) (ListSalesResponse, error) {
+	rows, err := db.Query(ctx, `
+		SELECT
+			s.id,
+			s.sale_number,
+			s.sale_date,
+			s.total_amount,
+			s.payment_method,
+			COALESCE(COUNT(si.id), 0) as total_item,
+			s.cashier_id
+		FROM sales s
+		LEFT JOIN sale_items si ON s.id = si.sale_id
+		GROUP BY s.id, s.sale_number, s.sale_date, s.total_amount, s.payment_method, s.cashier_id
+		ORDER BY s.sale_date DESC
+	`)
+	if err != nil {
+		return ListSalesResponse{
+			Message: "Failed to retrieve sales",
+			Data:    []SaleListItem{},
+		}, errors.New("failed to retrieve sales")
+	}
+	defer rows.Close()
+
+	var sales []SaleListItem
+	for rows.Next() {
+		var sale SaleListItem
+		err = rows.Scan(
+			&sale.ID,
+			&sale.SaleNumber,
+			&sale.SaleDate,
+			&sale.TotalAmount,
+			&sale.PaymentMethod,
+			&sale.TotalItem,
+			&sale.CashierID,
+		)
+		if err != nil {
+			return ListSalesResponse{Message: "Failed to scan sale"}, errors.New("failed to scan sale")
+		}
+		sales = append(sales, sale)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ListSalesResponse{Message: "Error iterating sales"}, errors.New("error iterating sales: " + err.Error())
+	}
+
+	return ListSalesResponse{
+		Message: "Sales retrieved successfully",
+		Data:    sales,
+	}, nil
+}
+
+// GetSale retrieves a sale by ID as a receipt
+//
+//encore:api public method=GET path=/api/sales/:id
+func GetSale(ctx context.Context, id uuid.UUID) (SaleResponse, error) {
+	var receipt Receipt
+	var notes *string
+	err := db.QueryRow(ctx, `
+		SELECT id, sale_number, sale_date, total_amount, payment_method, amount_paid, change_amount, notes, prescription_id, cashier_id
+		FROM sales
+		WHERE id = $1
+	`, id).Scan(
+		&receipt.ID,
+		&receipt.SaleNumber,
+		&receipt.SaleDate,
+		&receipt.TotalAmount,
+		&receipt.PaymentMethod,
+		&receipt.AmountPaid,
+		&receipt.ChangeAmount,
+		&notes,
+		&receipt.PrescriptionID,
+		&receipt.CashierID,
+	)
+	if err != nil {
+		return SaleResponse{Message: "Sale not found"}, errors.New("sale not found")
+	}
+	if notes != nil {
+		receipt.Notes = *notes
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT
+			si.id,
+			si.product_id,
+			si.product_name,
+			COALESCE(si.barcode, ''),
+			si.quantity,
+			si.total_price,
+			sb.batch_id,
+			sb.batch_number,
+			sb.expiration_date,
+			sb.quantity,
+			sb.unit_price
+		FROM sale_items si
+		JOIN sale_item_batches sb ON sb.sale_item_id = si.id
+		WHERE si.sale_id = $1
+		ORDER BY si.created_at, si.id, sb.expiration_date
+	`, id)
+	if err != nil {
+		return SaleResponse{Message: "Failed to retrieve sale items"}, errors.New("failed to retrieve sale items")
+	}
+	defer rows.Close()
+
+	var lastItemID uuid.UUID
+	for rows.Next() {
+		var itemID uuid.UUID
+		var item ReceiptItem
+		var batch ReceiptBatch
+		err = rows.Scan(
+			&itemID,
+			&item.ProductID,
+			&item.ProductName,
+			&item.Barcode,
+			&item.Quantity,
+			&item.TotalPrice,
+			&batch.BatchID,
+			&batch.BatchNumber,
+			&batch.ExpirationDate,
+			&batch.Quantity,
+			&batch.UnitPrice,
+		)
+		if err != nil {
+			return SaleResponse{Message: "Failed to scan sale item"}, errors.New("failed to scan sale item")
+		}
+
+		// Rows are ordered by sale item, so group consecutive batches together
+		if len(receipt.Items) == 0 || itemID != lastItemID {
+			receipt.Items = append(receipt.Items, item)
+			lastItemID = itemID
+		}
+		last := &receipt.Items[len(receipt.Items)-1]
+		last.Batches = append(last.Batches, batch)
+	}
+
+	if err = rows.Err(); err != nil {
+		return SaleResponse{Message: "Error iterating sale items"}, errors.New("error iterating sale items: " + err.Error())
+	}
+
+	return SaleResponse{
+		Message: "Sale retrieved successfully",
+		Data:    &receipt,
+	}, nil
+}
+
+// resolveSaleItems looks up the product of every line by ID or barcode and
+// merges lines for the same product, keeping the order they were scanned in
+func resolveSaleItems(ctx context.Context, reqItems []SaleItemRequest) ([]SaleItem, error) {
+	var items []SaleItem
+	index := make(map[uuid.UUID]int)
+	for _, reqItem := range reqItems {
+		var productData *product.Product
+		var err error
+		if reqItem.ProductID != uuid.Nil {
+			productData, err = product.GetProduct(ctx, reqItem.ProductID)
+			if err != nil {
+				return nil, errors.New("product not found: " + reqItem.ProductID.String())
+			}
+		} else {
+			productData, err = product.GetProductByBarcode(ctx, reqItem.Barcode)
+			if err != nil {
+				return nil, errors.New("product not found for barcode: " + reqItem.Barcode)
+			}
+		}
+		if !productData.IsActive {
+			return nil, errors.New("product is not active: " + productData.Name)
+		}
+
+		if i, ok := index[productData.ID]; ok {
+			items[i].Quantity += reqItem.Quantity
+			continue
+		}
+		index[productData.ID] = len(items)
+		items = append(items, SaleItem{
+			ProductID:            productData.ID,
+			ProductName:          productData.Name,
+			Barcode:              productData.Barcode,
+			Quantity:             reqItem.Quantity,
+			RequiresPrescription: productData.RequiresPrescription,
+		})
+	}
+	return items, nil
+}
+
+// saveSale prices the sale from the dispensed batches, checks the payment and
+// stores the sale with its items in a single transaction
+func saveSale(ctx context.Context, saleID uuid.UUID, req *CreateSaleRequest, items []SaleItem, dispensed []product.DispensedItem, fillID *uuid.UUID) (*Receipt, error) {
+	allocations := make(map[uuid.UUID][]product.BatchAllocation)
+	for _, item := range dispensed {
+		allocations[item.ProductID] = item.Allocations
+	}
+
+	// Price every line from the selling price of the batches it was drawn from
+	receipt := &Receipt{
+		ID:             saleID,
+		SaleDate:       time.Now(),
+		PaymentMethod:  req.PaymentMethod,
+		Notes:          req.Notes,
+		PrescriptionID: req.PrescriptionID,
+		CashierID:      req.CashierID,
+	}
+	for i := range items {
+		line := ReceiptItem{
+			ProductID:   items[i].ProductID,
+			ProductName: items[i].ProductName,
+			Barcode:     items[i].Barcode,
+			Quantity:    items[i].Quantity,
+		}
+		for _, allocation := range allocations[items[i].ProductID] {
+			line.TotalPrice += allocation.SellingPrice.Mul(allocation.Quantity)
+			line.Batches = append(line.Batches, ReceiptBatch{
+				BatchID:        allocation.BatchID,
+				BatchNumber:    allocation.BatchNumber,
+				ExpirationDate: allocation.ExpirationDate,
+				Quantity:       allocation.Quantity,
+				UnitPrice:      allocation.SellingPrice,
+			})
+		}
+		items[i].TotalPrice = line.TotalPrice
+		receipt.TotalAmount += line.TotalPrice
+		receipt.Items = append(receipt.Items, line)
+	}
+
+	// Cash must cover the total and gets change; other methods are charged the exact total
+	receipt.AmountPaid = receipt.TotalAmount
+	if req.PaymentMethod == "cash" {
+		if req.AmountPaid < receipt.TotalAmount {
+			return nil, fmt.Errorf("amount_paid %s is less than total %s", req.AmountPaid, receipt.TotalAmount)
+		}
+		receipt.AmountPaid = req.AmountPaid
+		receipt.ChangeAmount = req.AmountPaid - receipt.TotalAmount
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Generate the sale number from the daily date and a sequence
+	var sequence int64
+	if err = tx.QueryRow(ctx, "SELECT nextval('sale_number_seq')").Scan(&sequence); err != nil {
+		return nil, errors.New("failed to generate sale number: " + err.Error())
+	}
+	receipt.SaleNumber = fmt.Sprintf("S-%s-%06d", receipt.SaleDate.Format("20060102"), sequence)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO sales (id, sale_number, sale_date, total_amount, payment_method, amount_paid, change_amount, notes, prescription_id, prescription_fill_id, cashier_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`, receipt.ID, receipt.SaleNumber, receipt.SaleDate, receipt.TotalAmount, receipt.PaymentMethod, receipt.AmountPaid, receipt.ChangeAmount, receipt.Notes, receipt.PrescriptionID, fillID, receipt.CashierID)
+	if err != nil {
+		return nil, errors.New("failed to create sale: " + err.Error())
+	}
+
+	for _, line := range receipt.Items {
+		var saleItemID uuid.UUID
+		err = tx.QueryRow(ctx, `
+			INSERT INTO sale_items (sale_id, product_id, product_name, barcode, quantity, total_price)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, receipt.ID, line.ProductID, line.ProductName, line.Barcode, line.Quantity, line.TotalPrice).Scan(&saleItemID)
+		if err != nil {
+			return nil, errors.New("failed to create sale item: " + err.Error())
+		}
+
+		for _, batch := range line.Batches {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO sale_item_batches (sale_item_id, batch_id, batch_number, expiration_date, quantity, unit_price)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, saleItemID, batch.BatchID, batch.BatchNumber, batch.ExpirationDate, batch.Quantity, batch.UnitPrice)
+			if err != nil {
+				return nil, errors.New("failed to create sale item batch: " + err.Error())
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// fillPrescription records the prescription-only items of a sale against the
+// linked prescription and returns the fill ID, or nil when nothing needed a prescription
+func fillPrescription(ctx context.Context, saleID uuid.UUID, req *CreateSaleRequest, items []SaleItem) (*uuid.UUID, error) {
+	var fillItems []prescriptions.FillItem
+	for _, item := range items {
+		if item.RequiresPrescription {
+			fillItems = append(fillItems, prescriptions.FillItem{
+				ProductID: item.ProductID,
+				Quantity:  item.Quantity,
+			})
+		}
+	}
+	if len(fillItems) == 0 {
+		return nil, nil
+	}
+	if req.PrescriptionID == nil {
+		return nil, errors.New("prescription_id is required for prescription-only products")
+	}
+
+	fill, err := prescriptions.FillPrescription(ctx, *req.PrescriptionID, &prescriptions.FillPrescriptionRequest{
+		SaleID:      &saleID,
+		DispensedBy: req.CashierID,
+		Items:       fillItems,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fill.FillID, nil
+}
+
+// reversePrescriptionFill undoes the prescription fill of a sale that could not be completed
+func reversePrescriptionFill(ctx context.Context, fillID *uuid.UUID) {
+	if fillID == nil {
+		return
+	}
+	if _, err := prescriptions.ReverseFill(ctx, *fillID); err != nil {
+		rlog.Error("failed to reverse prescription fill of incomplete sale", "fill_id", *fillID, "err", err)
+	}
+}
+
+// returnDispensedStock puts back stock taken for a sale that could not be recorded
+func returnDispensedStock(ctx context.Context, saleID, cashierID uuid.UUID, dispensed []product.DispensedItem) {
+	var items []product.ReturnStockItem
+	for _, item := range dispensed {
+		for _, allocation := range item.Allocations {
+			items = append(items, product.ReturnStockItem{
+				BatchID:  allocation.BatchID,
+				Quantity: allocation.Quantity,
+			})
+		}
+	}
+
+	_, err := product.ReturnStock(ctx, &product.ReturnStockRequest{
+		ReferenceID: &saleID,
+		Notes:       "Sale not completed",
+		CreatedBy:   cashierID,
+		Items:       items,
+	})
+	if err != nil {
+		rlog.Error("failed to return stock of incomplete sale", "sale_id", saleID, "err", err)
+	}
+}